@@ -180,6 +180,8 @@ def main():
         return
 
     shutil.rmtree("./updates", True)
+    # embed leaflet for the map pages
+    subprocess.run(["go", "generate", "./utils/leaflet"]).check_returncode()
     for build in builds:
         do_build(build)
     changelog = generate_changelog("v"+VER)
@@ -26,6 +26,8 @@ import (
 	_ "github.com/bedrock-tool/bedrocktool/subcommands/merge"
 	_ "github.com/bedrock-tool/bedrocktool/subcommands/render"
 	_ "github.com/bedrock-tool/bedrocktool/subcommands/skins"
+	_ "github.com/bedrock-tool/bedrocktool/subcommands/structure"
+	_ "github.com/bedrock-tool/bedrocktool/subcommands/timelapse"
 	_ "github.com/bedrock-tool/bedrocktool/subcommands/world"
 
 	"github.com/sirupsen/logrus"
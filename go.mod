@@ -13,6 +13,7 @@ require (
 	github.com/df-mc/dragonfly v0.9.15
 	github.com/df-mc/goleveldb v1.1.9
 	github.com/dop251/goja v0.0.0-20240610225006-393f6d42497b
+	github.com/dop251/goja_nodejs v0.0.0-20211022123610-8dd9abb0616d
 	github.com/fatih/color v1.17.0
 	github.com/flytam/filenamify v1.2.0
 	github.com/go-gl/mathgl v1.1.0
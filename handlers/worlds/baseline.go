@@ -0,0 +1,119 @@
+package worlds
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// openBaselineWorld returns the folder of a world to preload, mcworld files get extracted to a temporary folder
+func openBaselineWorld(name string) (folder string, cleanup func(), err error) {
+	st, err := os.Stat(name)
+	if err != nil {
+		return "", nil, err
+	}
+	if st.IsDir() {
+		if _, err := os.Stat(filepath.Join(name, "db")); err != nil {
+			return "", nil, fmt.Errorf("%s is not a world folder", name)
+		}
+		return name, func() {}, nil
+	}
+
+	folder, err = os.MkdirTemp("", "bedrocktool-baseline")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(folder) }
+	err = extractZip(name, folder)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return folder, cleanup, nil
+}
+
+func extractZip(filename, folder string) error {
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		name := filepath.Join(folder, filepath.FromSlash(f.Name))
+		if !strings.HasPrefix(name, filepath.Clean(folder)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path in zip %s", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			os.MkdirAll(name, 0o777)
+			continue
+		}
+		os.MkdirAll(filepath.Dir(name), 0o777)
+		err := extractZipFile(f, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipFile(f *zip.File, name string) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	_, err = io.Copy(w, r)
+	return err
+}
+
+type baselineWorld struct {
+	name    string
+	folder  string
+	cleanup func()
+}
+
+// openBaselines opens the preloaded worlds the first time they are needed,
+// mcworld files are only extracted once and kept until the session ends
+func (w *worldsHandler) openBaselines() {
+	w.baselinesOnce.Do(func() {
+		for _, name := range w.settings.PreloadWorlds {
+			folder, cleanup, err := openBaselineWorld(name)
+			if err != nil {
+				w.log.Errorf("preload world %s: %s", name, err)
+				continue
+			}
+			w.baselines = append(w.baselines, baselineWorld{name: name, folder: folder, cleanup: cleanup})
+		}
+	})
+}
+
+func (w *worldsHandler) closeBaselines() {
+	for _, b := range w.baselines {
+		b.cleanup()
+	}
+	w.baselines = nil
+}
+
+// loadBaselines copies the current dimension of the preloaded worlds into the current world, in order
+func (w *worldsHandler) loadBaselines() {
+	w.openBaselines()
+	for _, b := range w.baselines {
+		count, err := w.currentWorld.LoadBaseline(b.folder, w.mapUI.SetBaselineChunk)
+		if err != nil {
+			w.log.Errorf("preload world %s: %s", b.name, err)
+			continue
+		}
+		if count > 0 {
+			w.log.Infof("preloaded %d chunks from %s", count, b.name)
+		}
+	}
+}
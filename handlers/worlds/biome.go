@@ -1,22 +1,94 @@
 package worlds
 
+import (
+	"slices"
+
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+// firstCustomBiomeID is where ids for custom biomes without one start, above all vanilla biomes
+const firstCustomBiomeID = 1000
+
 type customBiome struct {
 	name string
+	id   int
 	data map[string]any
 }
 
 func (c *customBiome) EncodeBiome() int {
-	return 0
+	return c.id
 }
 
 func (c *customBiome) Temperature() float64 {
+	if v, ok := c.data["temperature"].(float32); ok {
+		return float64(v)
+	}
 	return 0.5
 }
 
 func (c *customBiome) Rainfall() float64 {
+	if v, ok := c.data["downfall"].(float32); ok {
+		return float64(v)
+	}
 	return 0.5
 }
 
 func (c *customBiome) String() string {
 	return c.name
 }
+
+// biomeID reads the id the server gave a custom biome
+func biomeID(data map[string]any) (int, bool) {
+	switch v := data["id"].(type) {
+	case int16:
+		return int(v), true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case uint8:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// registerCustomBiomes adds the biomes that arent in the registry yet and returns them
+func registerCustomBiomes(registry *world.BiomeRegistry, biomes map[string]any) map[string]any {
+	var names []string
+	for name := range biomes {
+		if _, ok := registry.BiomeByName(name); !ok {
+			names = append(names, name)
+		}
+	}
+	// sorted so the ids dont change between captures of the same server
+	slices.Sort(names)
+
+	used := make(map[int]bool)
+	for _, name := range names {
+		data, _ := biomes[name].(map[string]any)
+		if id, ok := biomeID(data); ok {
+			used[id] = true
+		}
+	}
+
+	custom := make(map[string]any, len(names))
+	nextID := firstCustomBiomeID
+	for _, name := range names {
+		data, _ := biomes[name].(map[string]any)
+		id, ok := biomeID(data)
+		if !ok {
+			for used[nextID] {
+				nextID++
+			}
+			id = nextID
+			used[id] = true
+		}
+		registry.Register(&customBiome{
+			name: name,
+			id:   id,
+			data: data,
+		})
+		custom[name] = data
+	}
+	return custom
+}
@@ -0,0 +1,61 @@
+package worlds
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds/worldstate"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+func (w *worldsHandler) setCaptureBounds(bounds *worldstate.CaptureBounds) {
+	w.worldStateLock.Lock()
+	w.settings.CaptureBounds = bounds
+	w.currentWorld.SetBounds(bounds)
+	// chunks outside of the old bounds are captured again when the server sends them
+	clear(w.currentWorld.IgnoredChunks)
+	w.worldStateLock.Unlock()
+	w.session.SendMessage(fmt.Sprintf("Capturing %s", bounds))
+}
+
+func (w *worldsHandler) addBoundsCommand() {
+	w.session.AddCommand(func(s []string) bool {
+		if len(s) == 0 {
+			w.session.SendMessage(fmt.Sprintf("Capturing %s", w.settings.CaptureBounds))
+			return true
+		}
+
+		switch s[0] {
+		case "clear":
+			w.setCaptureBounds(nil)
+		case "radius":
+			if len(s) < 2 {
+				w.session.SendMessage("usage: /capture-bounds radius <blocks>")
+				return true
+			}
+			radius, err := strconv.Atoi(s[1])
+			if err != nil || radius <= 0 {
+				w.session.SendMessage("radius needs to be a positive number")
+				return true
+			}
+			pos := w.playerBlockPos()
+			w.setCaptureBounds(&worldstate.CaptureBounds{
+				Center: [2]int32{int32(pos.X()), int32(pos.Z())},
+				Radius: int32(radius),
+			})
+		case "selection":
+			if w.selection.pos1 == nil || w.selection.pos2 == nil {
+				w.session.SendMessage("set both corners using /pos1 and /pos2 first")
+				return true
+			}
+			p1, p2 := *w.selection.pos1, *w.selection.pos2
+			w.setCaptureBounds(worldstate.NewBoxBounds(int32(p1.X()), int32(p1.Z()), int32(p2.X()), int32(p2.Z())))
+		default:
+			w.session.SendMessage("usage: /capture-bounds [clear|radius <blocks>|selection]")
+		}
+		return true
+	}, protocol.Command{
+		Name:        "capture-bounds",
+		Description: "only capture chunks and entities inside an area, usage: /capture-bounds [clear|radius <blocks>|selection]",
+	})
+}
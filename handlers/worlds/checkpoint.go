@@ -0,0 +1,51 @@
+package worlds
+
+import (
+	"time"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+)
+
+// checkpointLoop writes the current world to disk every CheckpointInterval so a crash doesnt lose the capture
+func (w *worldsHandler) checkpointLoop() {
+	t := time.NewTicker(w.settings.CheckpointInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-t.C:
+			w.checkpoint()
+		}
+	}
+}
+
+// checkpoint collects what the world needs from the handler under the lock and writes the checkpoint after releasing it
+func (w *worldsHandler) checkpoint() {
+	w.worldStateLock.Lock()
+	currentWorld := w.currentWorld
+	if currentWorld == nil || w.session.Server == nil {
+		w.worldStateLock.Unlock()
+		return
+	}
+	playerPos := w.session.Player.Position
+	spawnPos := cube.Pos{int(playerPos.X()), int(playerPos.Y()), int(playerPos.Z())}
+	playerData := w.playerData()
+	gd := w.session.Server.GameData()
+	experimental := w.serverState.behaviorPack.HasContent()
+	name := currentWorld.Name
+	// the behavior pack is changed by packets, so the packs are written with the lock held, only once per world
+	err := currentWorld.CheckpointPacks(w.addBehaviorPack)
+	w.worldStateLock.Unlock()
+	if err != nil {
+		w.log.WithError(err).Warn("checkpoint failed")
+		return
+	}
+
+	err = currentWorld.Checkpoint(playerData, spawnPos, gd, experimental)
+	if err != nil {
+		w.log.WithError(err).Warn("checkpoint failed")
+		return
+	}
+	w.log.Debugf("checkpoint of %s written", name)
+}
@@ -20,6 +20,22 @@ func (w *worldsHandler) processLevelChunk(pk *packet.LevelChunk, timeReceived ti
 		return
 	}
 
+	//os.WriteFile("chunk.bin", pk.RawPayload, 0777)
+
+	if pk.CacheEnabled {
+		return errors.New("cache is supposed to be handled in proxy")
+	}
+
+	w.chunkPool.Enqueue(world.ChunkPos(pk.Position), func() {
+		if err := w.decodeLevelChunk(pk, timeReceived); err != nil {
+			w.log.WithField("packet", "LevelChunk").Error(err)
+		}
+	})
+	return nil
+}
+
+// decodeLevelChunk runs in the chunk pool, the world state is only locked after decoding
+func (w *worldsHandler) decodeLevelChunk(pk *packet.LevelChunk, timeReceived time.Time) (err error) {
 	var subChunkCount int
 	switch pk.SubChunkCount {
 	case protocol.SubChunkRequestModeLimited, protocol.SubChunkRequestModeLimitless:
@@ -28,23 +44,30 @@ func (w *worldsHandler) processLevelChunk(pk *packet.LevelChunk, timeReceived ti
 		subChunkCount = int(pk.SubChunkCount)
 	}
 
+	pos := world.ChunkPos(pk.Position)
 	w.worldStateLock.Lock()
-	defer w.worldStateLock.Unlock()
-
-	//os.WriteFile("chunk.bin", pk.RawPayload, 0777)
-
-	if pk.CacheEnabled {
-		return errors.New("cache is supposed to be handled in proxy")
+	if !w.currentWorld.Bounds().ContainsChunk(pos) {
+		// outside of the capture area, dont bother decoding
+		w.currentWorld.IgnoredChunks[pos] = true
+		w.worldStateLock.Unlock()
+		return nil
 	}
+	r := w.currentWorld.Range()
+	w.worldStateLock.Unlock()
 
 	ch, blockNBTs, err := chunk.NetworkDecode(
 		w.serverState.blocks,
 		pk.RawPayload, subChunkCount,
 		w.serverState.useOldBiomes,
 		w.serverState.useHashedRids,
-		w.currentWorld.Range(),
+		r,
 	)
+
+	w.worldStateLock.Lock()
+	defer w.worldStateLock.Unlock()
+
 	if err != nil {
+		w.currentWorld.Coverage.DecodeFailed(pos)
 		return err
 	}
 
@@ -65,7 +88,6 @@ func (w *worldsHandler) processLevelChunk(pk *packet.LevelChunk, timeReceived ti
 		}
 	}
 
-	pos := world.ChunkPos(pk.Position)
 	if !w.scripting.OnChunkAdd(pos, timeReceived) {
 		w.currentWorld.IgnoredChunks[pos] = true
 		return
@@ -77,27 +99,31 @@ func (w *worldsHandler) processLevelChunk(pk *packet.LevelChunk, timeReceived ti
 		w.log.Error(err)
 	}
 
-	max := w.currentWorld.Dimension().Range().Height() / 16
+	max := w.currentWorld.Range().Height() / 16
 	switch pk.SubChunkCount {
 	case protocol.SubChunkRequestModeLimited:
 		max = int(pk.HighestSubChunk)
 		fallthrough
 	case protocol.SubChunkRequestModeLimitless:
 		var offsetTable []protocol.SubChunkOffset
-		r := w.currentWorld.Dimension().Range()
+		r := w.currentWorld.Range()
 		for y := int8(r.Min() / 16); y < int8(r.Max()/16)+1; y++ {
 			offsetTable = append(offsetTable, protocol.SubChunkOffset{0, y, 0})
 		}
 
+		offsets := offsetTable[:min(max+1, len(offsetTable))]
+		w.currentWorld.Coverage.ChunkReceived(pos, len(offsets))
+
 		dimId, _ := world.DimensionID(w.currentWorld.Dimension())
 		_ = w.session.Server.WritePacket(&packet.SubChunkRequest{
 			Dimension: int32(dimId),
 			Position: protocol.SubChunkPos{
 				pk.Position.X(), 0, pk.Position.Z(),
 			},
-			Offsets: offsetTable[:min(max+1, len(offsetTable))],
+			Offsets: offsets,
 		})
 	default:
+		w.currentWorld.Coverage.ChunkReceived(pos, 0)
 	}
 
 	w.session.SendPopup(locale.Locm("popup_chunk_count", locale.Strmap{
@@ -110,95 +136,127 @@ func (w *worldsHandler) processLevelChunk(pk *packet.LevelChunk, timeReceived ti
 }
 
 func (w *worldsHandler) processSubChunk(pk *packet.SubChunk) error {
-	w.worldStateLock.Lock()
-	defer w.worldStateLock.Unlock()
-
-	var columns = make(map[world.ChunkPos]*world.Column)
-
+	// split the entries by column, so each column is decoded in order with its LevelChunk
+	var order []world.ChunkPos
+	var columns = make(map[world.ChunkPos][]protocol.SubChunkEntry)
 	for _, ent := range pk.SubChunkEntries {
-		if ent.Result != protocol.SubChunkResultSuccess {
-			continue
+		pos := world.ChunkPos{
+			pk.Position[0] + int32(ent.Offset[0]),
+			pk.Position[2] + int32(ent.Offset[2]),
 		}
-		var (
-			absX = pk.Position[0] + int32(ent.Offset[0])
-			absZ = pk.Position[2] + int32(ent.Offset[2])
-			pos  = world.ChunkPos{absX, absZ}
-		)
-
-		if w.currentWorld.IgnoredChunks[pos] {
-			continue
+		if _, ok := columns[pos]; !ok {
+			order = append(order, pos)
 		}
+		columns[pos] = append(columns[pos], ent)
+	}
+
+	for _, pos := range order {
+		entries := columns[pos]
+		w.chunkPool.Enqueue(pos, func() {
+			if err := w.decodeSubChunks(pk.Position, pos, entries); err != nil {
+				w.log.WithField("packet", "SubChunk").Error(err)
+			}
+		})
+	}
+	return nil
+}
+
+type decodedSubChunk struct {
+	index     uint8
+	sub       *chunk.SubChunk
+	blockNBTs []map[string]any
+}
+
+// decodeSubChunks runs in the chunk pool, decodes the subchunks of one column and adds them to it
+func (w *worldsHandler) decodeSubChunks(base protocol.SubChunkPos, pos world.ChunkPos, entries []protocol.SubChunkEntry) error {
+	w.worldStateLock.Lock()
+	ignored := w.currentWorld.IgnoredChunks[pos]
+	r := w.currentWorld.Range()
+	w.worldStateLock.Unlock()
+	if ignored {
+		return nil
+	}
 
-		if _, ok := columns[pos]; ok {
+	decoded := make([]*decodedSubChunk, len(entries))
+	for i, ent := range entries {
+		if ent.Result != protocol.SubChunkResultSuccess {
 			continue
 		}
-		col, ok, err := w.currentWorld.LoadChunk(pos)
+		buf := bytes.NewBuffer(ent.RawPayload)
+		index := uint8(base[1] + int32(ent.Offset[1]))
+		sub, err := chunk.DecodeSubChunk(
+			buf,
+			w.serverState.blocks,
+			r,
+			&index,
+			chunk.NetworkEncoding,
+			w.serverState.useHashedRids,
+		)
 		if err != nil {
+			w.worldStateLock.Lock()
+			w.currentWorld.Coverage.DecodeFailed(pos)
+			w.worldStateLock.Unlock()
 			return err
 		}
-		if !ok {
-			return errors.New("bug check: subchunk received before chunk")
+		d := &decodedSubChunk{index: index, sub: sub}
+
+		if buf.Len() > 0 {
+			dec := nbt.NewDecoderWithEncoding(buf, nbt.NetworkLittleEndian)
+			for buf.Len() > 0 {
+				blockNBT := make(map[string]any, 0)
+				if err := dec.Decode(&blockNBT); err != nil {
+					w.worldStateLock.Lock()
+					w.currentWorld.Coverage.DecodeFailed(pos)
+					w.worldStateLock.Unlock()
+					return err
+				}
+				d.blockNBTs = append(d.blockNBTs, blockNBT)
+			}
 		}
-		columns[pos] = col
+		decoded[i] = d
 	}
 
-	for _, ent := range pk.SubChunkEntries {
-		var (
-			absX = pk.Position[0] + int32(ent.Offset[0])
-			absY = pk.Position[1] + int32(ent.Offset[1])
-			absZ = pk.Position[2] + int32(ent.Offset[2])
-			pos  = world.ChunkPos{absX, absZ}
-		)
+	w.worldStateLock.Lock()
+	defer w.worldStateLock.Unlock()
 
-		col, ok := columns[pos]
-		if !ok {
-			continue
+	col, ok, err := w.currentWorld.LoadChunk(pos)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// the LevelChunk failed to decode
+		for range entries {
+			w.currentWorld.Coverage.SubChunkResult(pos, false)
 		}
+		return nil
+	}
 
+	for i, ent := range entries {
 		switch ent.Result {
 		case protocol.SubChunkResultSuccessAllAir:
+			w.currentWorld.Coverage.SubChunkResult(pos, true)
 		case protocol.SubChunkResultSuccess:
-			buf := bytes.NewBuffer(ent.RawPayload)
-			index := uint8(absY)
-			sub, err := chunk.DecodeSubChunk(
-				buf,
-				w.serverState.blocks,
-				w.currentWorld.Dimension().Range(),
-				&index,
-				chunk.NetworkEncoding,
-				w.serverState.useHashedRids,
-			)
-			if err != nil {
-				return err
-			}
-			col.Chunk.Sub()[index] = sub
-
-			if buf.Len() > 0 {
-				dec := nbt.NewDecoderWithEncoding(buf, nbt.NetworkLittleEndian)
-				for buf.Len() > 0 {
-					blockNBT := make(map[string]any, 0)
-					if err := dec.Decode(&blockNBT); err != nil {
-						return err
-					}
-					col.BlockEntities[cube.Pos{
-						int(blockNBT["x"].(int32)),
-						int(blockNBT["y"].(int32)),
-						int(blockNBT["z"].(int32)),
-					}] = world.UnknownBlock{
-						BlockState: world.BlockState{
-							Name:       blockNBT["id"].(string),
-							Properties: blockNBT,
-						},
-					}
+			d := decoded[i]
+			col.Chunk.Sub()[d.index] = d.sub
+			w.currentWorld.Coverage.SubChunkResult(pos, true)
+			for _, blockNBT := range d.blockNBTs {
+				col.BlockEntities[cube.Pos{
+					int(blockNBT["x"].(int32)),
+					int(blockNBT["y"].(int32)),
+					int(blockNBT["z"].(int32)),
+				}] = world.UnknownBlock{
+					BlockState: world.BlockState{
+						Name:       blockNBT["id"].(string),
+						Properties: blockNBT,
+					},
 				}
 			}
+		default:
+			w.currentWorld.Coverage.SubChunkResult(pos, false)
 		}
 	}
 
-	for pos, col := range columns {
-		w.currentWorld.StoreChunk(pos, col)
-	}
-
+	w.currentWorld.StoreChunk(pos, col)
 	w.mapUI.SchedRedraw()
 	return nil
 }
@@ -0,0 +1,76 @@
+package worlds
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+// chunkPool runs chunk work off the proxy loop, work for the same chunk position
+// always goes to the same worker so it runs in the order it was added
+type chunkPool struct {
+	queues  []chan func()
+	pending sync.WaitGroup
+
+	// the first panic of a job, Wait panics with it so it reaches the recover of whoever waits
+	panicLock sync.Mutex
+	panicked  error
+}
+
+func newChunkPool(workers, queueSize int) *chunkPool {
+	p := &chunkPool{
+		queues: make([]chan func(), workers),
+	}
+	for i := range p.queues {
+		q := make(chan func(), queueSize)
+		p.queues[i] = q
+		go func() {
+			for job := range q {
+				p.run(job)
+			}
+		}()
+	}
+	return p
+}
+
+func (p *chunkPool) run(job func()) {
+	defer p.pending.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			p.panicLock.Lock()
+			if p.panicked == nil {
+				p.panicked = fmt.Errorf("chunk worker: %v\n%s", r, debug.Stack())
+			}
+			p.panicLock.Unlock()
+		}
+	}()
+	job()
+}
+
+// Enqueue adds work for a chunk, blocks when the workers queue is full
+func (p *chunkPool) Enqueue(pos world.ChunkPos, job func()) {
+	h := uint32(pos[0])*73856093 ^ uint32(pos[1])*19349663
+	p.pending.Add(1)
+	p.queues[h%uint32(len(p.queues))] <- job
+}
+
+// Wait blocks until all work that was added so far is done,
+// if a job panicked it panics with that in the calling goroutine
+func (p *chunkPool) Wait() {
+	p.pending.Wait()
+	p.panicLock.Lock()
+	err := p.panicked
+	p.panicked = nil
+	p.panicLock.Unlock()
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (p *chunkPool) Close() {
+	for _, q := range p.queues {
+		close(q)
+	}
+}
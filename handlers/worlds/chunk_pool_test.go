@@ -0,0 +1,106 @@
+package worlds
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+func TestChunkPoolOrder(t *testing.T) {
+	p := newChunkPool(4, 2)
+	defer p.Close()
+
+	positions := []world.ChunkPos{{0, 0}, {1, 0}, {0, 1}, {-1, -1}, {100, -7}, {5, 5}, {6, 5}, {-30, 2}}
+	var lock sync.Mutex
+	got := make(map[world.ChunkPos][]int)
+	const jobs = 200
+	for i := 0; i < jobs; i++ {
+		for _, pos := range positions {
+			p.Enqueue(pos, func() {
+				lock.Lock()
+				got[pos] = append(got[pos], i)
+				lock.Unlock()
+			})
+		}
+	}
+	p.Wait()
+
+	// Wait returned, so everything ran
+	for _, pos := range positions {
+		if len(got[pos]) != jobs {
+			t.Fatalf("%v: %d of %d jobs ran before Wait returned", pos, len(got[pos]), jobs)
+		}
+		for i, n := range got[pos] {
+			if n != i {
+				t.Errorf("%v: job %d ran as number %d", pos, n, i)
+				break
+			}
+		}
+	}
+}
+
+func TestChunkPoolWait(t *testing.T) {
+	p := newChunkPool(2, 1)
+	defer p.Close()
+
+	// waiting with nothing queued returns
+	p.Wait()
+
+	release := make(chan struct{})
+	var done atomic.Int32
+	p.Enqueue(world.ChunkPos{0, 0}, func() {
+		<-release
+		done.Add(1)
+	})
+	p.Enqueue(world.ChunkPos{0, 0}, func() { done.Add(1) })
+
+	waited := make(chan struct{})
+	go func() {
+		p.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("Wait returned while a job was running")
+	default:
+	}
+	close(release)
+	<-waited
+	if n := done.Load(); n != 2 {
+		t.Errorf("%d jobs done after Wait", n)
+	}
+}
+
+func TestChunkPoolPanic(t *testing.T) {
+	p := newChunkPool(2, 1)
+	defer p.Close()
+
+	var ran atomic.Int32
+	p.Enqueue(world.ChunkPos{0, 0}, func() { panic("broken chunk") })
+	p.Enqueue(world.ChunkPos{0, 0}, func() { ran.Add(1) })
+
+	func() {
+		defer func() {
+			err, ok := recover().(error)
+			if !ok || !strings.Contains(err.Error(), "broken chunk") {
+				t.Errorf("Wait panicked with %v", err)
+			}
+		}()
+		p.Wait()
+		t.Error("Wait didnt panic")
+	}()
+	// the worker keeps going after a panic
+	if ran.Load() != 1 {
+		t.Error("job after the panic didnt run")
+	}
+
+	// the panic is only reported once
+	p.Enqueue(world.ChunkPos{0, 0}, func() { ran.Add(1) })
+	p.Wait()
+	if ran.Load() != 2 {
+		t.Error("pool stopped after a panic")
+	}
+}
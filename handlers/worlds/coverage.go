@@ -0,0 +1,73 @@
+package worlds
+
+import (
+	"fmt"
+	"image/png"
+	"os"
+	"strconv"
+
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds/worldstate"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// writeCoverage writes the coverage report and image next to the mcworld
+func (w *worldsHandler) writeCoverage(worldState *worldstate.World) error {
+	f, err := os.Create(worldState.Folder + ".coverage.txt")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	err = worldState.Coverage.WriteReport(f)
+	if err != nil {
+		return err
+	}
+
+	img := worldState.Coverage.Image()
+	if img.Rect.Empty() {
+		return nil
+	}
+	f2, err := os.Create(worldState.Folder + ".coverage.png")
+	if err != nil {
+		return err
+	}
+	defer f2.Close()
+	return png.Encode(f2, img)
+}
+
+func (w *worldsHandler) addCoverageCommand() {
+	w.session.AddCommand(func(s []string) bool {
+		radius := 8
+		if len(s) > 0 {
+			r, err := strconv.Atoi(s[0])
+			if err != nil || r <= 0 {
+				w.session.SendMessage("usage: /coverage [radius in chunks]")
+				return true
+			}
+			radius = r
+		}
+
+		pos := w.playerBlockPos()
+		center := world.ChunkPos{int32(pos.X()) >> 4, int32(pos.Z()) >> 4}
+		w.worldStateLock.Lock()
+		incomplete := w.currentWorld.Coverage.Incomplete(center, int32(radius))
+		w.worldStateLock.Unlock()
+
+		if len(incomplete) == 0 {
+			w.session.SendMessage(fmt.Sprintf("all chunks within %d chunks are complete", radius))
+			return true
+		}
+		w.session.SendMessage(fmt.Sprintf("%d incomplete chunks within %d chunks, closest first:", len(incomplete), radius))
+		for i, ic := range incomplete {
+			if i == 10 {
+				w.session.SendMessage(fmt.Sprintf("... and %d more", len(incomplete)-i))
+				break
+			}
+			w.session.SendMessage(fmt.Sprintf("%d, %d: %s", ic.Pos[0]*16+8, ic.Pos[1]*16+8, ic.Status))
+		}
+		return true
+	}, protocol.Command{
+		Name:        "coverage",
+		Description: "list missing or incomplete chunks near you, usage: /coverage [radius in chunks]",
+	})
+}
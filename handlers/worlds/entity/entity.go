@@ -6,6 +6,7 @@ import (
 	"github.com/df-mc/dragonfly/server/block/cube"
 	"github.com/df-mc/dragonfly/server/world"
 	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
 	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
 )
@@ -38,6 +39,9 @@ type Entity struct {
 	Chestplate *protocol.ItemInstance
 	Leggings   *protocol.ItemInstance
 	Boots      *protocol.ItemInstance
+
+	// already encoded tags that are added to the saved entity
+	ExtraNBT map[string]any
 }
 
 type EntityProperty struct {
@@ -270,6 +274,28 @@ func (s *Entity) toNBT(nbt map[string]any) {
 	nbt["Attributes"] = attributes
 }
 
+// SetExtraNBT sets a tag that is added to the entity when it is saved
+func (s *Entity) SetExtraNBT(key string, value any) {
+	if s.ExtraNBT == nil {
+		s.ExtraNBT = make(map[string]any)
+	}
+	s.ExtraNBT[key] = value
+}
+
+// SetTradeOffers stores the serialised offers of an UpdateTrade so the villager still trades in the saved world
+func (s *Entity) SetTradeOffers(serialisedOffers []byte, tradeTier int32) error {
+	var offers map[string]any
+	if err := nbt.UnmarshalEncoding(serialisedOffers, &offers, nbt.NetworkLittleEndian); err != nil {
+		return err
+	}
+	s.SetExtraNBT("Offers", offers)
+	s.SetExtraNBT("TradeTier", tradeTier)
+	if xp, ok := s.Metadata[protocol.EntityDataKeyTradeExperience].(int32); ok {
+		s.SetExtraNBT("TradeExperience", xp)
+	}
+	return nil
+}
+
 func vec3float32(x mgl32.Vec3) []float32 {
 	return []float32{float32(x[0]), float32(x[1]), float32(x[2])}
 }
@@ -307,24 +333,9 @@ func (s *Entity) ToServerEntity(links []int64) serverEntity {
 		e.EntityType.NBT["LinksTag"] = linksTag
 	}
 
-	/*
-		if false {
-			armor := make([]map[string]any, 4)
-			if s.Helmet != nil {
-				armor[0] = nbtconv.WriteItem(utils.StackToItem(w.serverState.blocks, s.Helmet.Stack), true)
-			}
-			if s.Chestplate != nil {
-				armor[1] = nbtconv.WriteItem(utils.StackToItem(w.serverState.blocks, s.Chestplate.Stack), true)
-			}
-			if s.Leggings != nil {
-				armor[2] = nbtconv.WriteItem(utils.StackToItem(w.serverState.blocks, s.Leggings.Stack), true)
-			}
-			if s.Boots != nil {
-				armor[3] = nbtconv.WriteItem(utils.StackToItem(w.serverState.blocks, s.Boots.Stack), true)
-			}
-			e.EntityType.NBT["Armor"] = armor
-		}
-	*/
+	for k, v := range s.ExtraNBT {
+		e.EntityType.NBT[k] = v
+	}
 
 	return e
 }
@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+func TestSetTradeOffers(t *testing.T) {
+	offers := map[string]any{
+		"Recipes": []any{
+			map[string]any{
+				"buyA":    map[string]any{"Name": "minecraft:emerald", "Count": byte(1)},
+				"sell":    map[string]any{"Name": "minecraft:bread", "Count": byte(6)},
+				"maxUses": int32(16),
+			},
+		},
+	}
+	serialised, err := nbt.MarshalEncoding(offers, nbt.NetworkLittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		tier     int32 // TradeTier of the UpdateTrade packet
+		xp       any
+		wantErr  bool
+		wantXP   bool
+		wantTier int32
+	}{
+		{name: "offers", data: serialised, tier: 2, wantTier: 2},
+		{name: "lowest tier", data: serialised, tier: 0, wantTier: 0},
+		{name: "with experience", data: serialised, tier: 3, xp: int32(40), wantXP: true, wantTier: 3},
+		{name: "experience of the wrong type", data: serialised, tier: 1, xp: "40", wantTier: 1},
+		{name: "broken offers", data: serialised[:len(serialised)/2], tier: 4, wantErr: true},
+	}
+	for _, tt := range tests {
+		e := &Entity{EntityType: "minecraft:villager_v2", Metadata: protocol.NewEntityMetadata()}
+		if tt.xp != nil {
+			e.Metadata[protocol.EntityDataKeyTradeExperience] = tt.xp
+		}
+		err := e.SetTradeOffers(tt.data, tt.tier)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v", tt.name, err)
+			continue
+		}
+		if tt.wantErr {
+			if e.ExtraNBT != nil {
+				t.Errorf("%s: tags set for broken offers", tt.name)
+			}
+			continue
+		}
+		recipes, _ := e.ExtraNBT["Offers"].(map[string]any)["Recipes"].([]any)
+		if len(recipes) != 1 {
+			t.Errorf("%s: wrong offers %v", tt.name, e.ExtraNBT["Offers"])
+		}
+		// the tier is saved as an int32 like the game does
+		if tier, ok := e.ExtraNBT["TradeTier"].(int32); !ok || tier != tt.wantTier {
+			t.Errorf("%s: wrong tier %v", tt.name, e.ExtraNBT["TradeTier"])
+		}
+		if _, ok := e.ExtraNBT["TradeExperience"]; ok != tt.wantXP {
+			t.Errorf("%s: TradeExperience set = %v, want %v", tt.name, ok, tt.wantXP)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package entity
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// Rule matches entities by type and optionally by metadata.
+//
+// syntax: [+|-]pattern[conditions]
+//   - + makes it an include rule, - or nothing an exclude rule
+//   - the pattern is a glob like minecraft:*_golem, or a regex between slashes like /^minecraft:(cow|pig)$/
+//   - conditions are a comma separated list in brackets of named, baby and tamed, each can be negated with !
+//
+// examples: minecraft:bat, mycustom:*, +*[named], -*[baby]
+type Rule struct {
+	Include    bool
+	Pattern    string
+	regex      *regexp.Regexp
+	conditions map[string]bool
+	raw        string
+}
+
+// RuleSeparator separates rules in a single string, rules use commas themselves so it cant be a comma
+const RuleSeparator = ";"
+
+// SplitRules splits a list of rules separated by RuleSeparator
+func SplitRules(s string) []string {
+	return strings.Split(s, RuleSeparator)
+}
+
+var conditionNames = map[string]func(e *Entity) bool{
+	"named": func(e *Entity) bool {
+		name, _ := e.Metadata[protocol.EntityDataKeyName].(string)
+		return name != ""
+	},
+	"baby": func(e *Entity) bool {
+		return hasFlag(e, protocol.EntityDataFlagBaby)
+	},
+	"tamed": func(e *Entity) bool {
+		return hasFlag(e, protocol.EntityDataFlagTamed)
+	},
+}
+
+func hasFlag(e *Entity, flag uint8) bool {
+	if _, ok := e.Metadata[protocol.EntityDataKeyFlags]; !ok {
+		return false
+	}
+	return e.Metadata.Flag(protocol.EntityDataKeyFlags, flag)
+}
+
+func ParseRule(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	r := &Rule{raw: s}
+	switch {
+	case strings.HasPrefix(s, "+"):
+		r.Include = true
+		s = s[1:]
+	case strings.HasPrefix(s, "-"):
+		s = s[1:]
+	}
+
+	if strings.HasSuffix(s, "]") {
+		i := strings.LastIndex(s, "[")
+		if i < 0 {
+			return nil, fmt.Errorf("rule %q: missing [", r.raw)
+		}
+		r.conditions = make(map[string]bool)
+		for _, c := range strings.Split(s[i+1:len(s)-1], ",") {
+			c = strings.TrimSpace(c)
+			want := !strings.HasPrefix(c, "!")
+			c = strings.TrimPrefix(c, "!")
+			if _, ok := conditionNames[c]; !ok {
+				return nil, fmt.Errorf("rule %q: unknown condition %q", r.raw, c)
+			}
+			r.conditions[c] = want
+		}
+		s = s[:i]
+	}
+
+	if s == "" {
+		s = "*"
+	}
+	r.Pattern = s
+	if len(s) > 1 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
+		var err error
+		r.regex, err = regexp.Compile(s[1 : len(s)-1])
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.raw, err)
+		}
+	} else if _, err := path.Match(s, ""); err != nil {
+		return nil, fmt.Errorf("rule %q: %w", r.raw, err)
+	}
+	return r, nil
+}
+
+func (r *Rule) String() string {
+	return r.raw
+}
+
+// key is the rule in a normalised form, so -x and x or [baby,named] and [named,baby] are the same rule
+func (r *Rule) key() string {
+	var b strings.Builder
+	if r.Include {
+		b.WriteByte('+')
+	}
+	b.WriteString(r.Pattern)
+	if len(r.conditions) > 0 {
+		names := make([]string, 0, len(r.conditions))
+		for name, want := range r.conditions {
+			if !want {
+				name = "!" + name
+			}
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		b.WriteString("[" + strings.Join(names, ",") + "]")
+	}
+	return b.String()
+}
+
+func (r *Rule) Matches(e *Entity) bool {
+	if r.regex != nil {
+		if !r.regex.MatchString(e.EntityType) {
+			return false
+		}
+	} else if ok, _ := path.Match(r.Pattern, e.EntityType); !ok {
+		return false
+	}
+	for name, want := range r.conditions {
+		if conditionNames[name](e) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter decides which entities are saved,
+// if there are include rules only entities matching one of them are kept,
+// entities matching any exclude rule are dropped
+type Filter struct {
+	lock  sync.RWMutex
+	rules []*Rule
+}
+
+func NewFilter(rules []string) (*Filter, error) {
+	f := &Filter{}
+	for _, s := range rules {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		if err := f.Add(s); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (f *Filter) Add(s string) error {
+	r, err := ParseRule(s)
+	if err != nil {
+		return err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, r2 := range f.rules {
+		if r2.key() == r.key() {
+			return nil
+		}
+	}
+	f.rules = append(f.rules, r)
+	return nil
+}
+
+// Remove removes a rule by its text, returns false if there was no such rule
+func (f *Filter) Remove(s string) bool {
+	rule, err := ParseRule(s)
+	if err != nil {
+		return false
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for i, r := range f.rules {
+		if r.key() == rule.key() {
+			f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) Clear() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.rules = nil
+}
+
+func (f *Filter) Rules() []string {
+	if f == nil {
+		return nil
+	}
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	out := make([]string, len(f.rules))
+	for i, r := range f.rules {
+		out[i] = r.raw
+	}
+	return out
+}
+
+// Keep returns true if the entity should be saved, a nil filter keeps everything
+func (f *Filter) Keep(e *Entity) bool {
+	if f == nil {
+		return true
+	}
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	var haveInclude, included bool
+	for _, r := range f.rules {
+		if r.Include {
+			haveInclude = true
+			if !included && r.Matches(e) {
+				included = true
+			}
+		} else if r.Matches(e) {
+			return false
+		}
+	}
+	return !haveInclude || included
+}
@@ -0,0 +1,56 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+func TestFilter(t *testing.T) {
+	golem := &Entity{EntityType: "minecraft:iron_golem", Metadata: protocol.NewEntityMetadata()}
+	cow := &Entity{EntityType: "minecraft:cow", Metadata: protocol.NewEntityMetadata()}
+	custom := &Entity{EntityType: "mycustom:thing", Metadata: protocol.NewEntityMetadata()}
+	namedCow := &Entity{EntityType: "minecraft:cow", Metadata: protocol.NewEntityMetadata()}
+	namedCow.Metadata[protocol.EntityDataKeyName] = "bob"
+
+	f, err := NewFilter([]string{"minecraft:*_golem", "mycustom:*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Keep(golem) || f.Keep(custom) || !f.Keep(cow) {
+		t.Error("glob exclude rules dont match")
+	}
+
+	f, err = NewFilter([]string{"+/^minecraft:(cow|pig)$/[named]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Keep(cow) || !f.Keep(namedCow) || f.Keep(golem) {
+		t.Error("include rule with condition doesnt match")
+	}
+
+	if !f.Remove("+/^minecraft:(cow|pig)$/[named]") || !f.Keep(golem) {
+		t.Error("rule not removed")
+	}
+
+	f, err = NewFilter(SplitRules("-minecraft:cow[named,!baby]; +/^minecraft:(cow|pig){1,2}$/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules := f.Rules(); len(rules) != 2 {
+		t.Fatalf("rules split wrong: %q", rules)
+	}
+	if f.Keep(namedCow) || !f.Keep(cow) || f.Keep(golem) {
+		t.Error("split rules dont match")
+	}
+	if err := f.Add("minecraft:cow[!baby, named]"); err != nil || len(f.Rules()) != 2 {
+		t.Error("same rule added twice")
+	}
+	if !f.Remove("minecraft:cow[!baby,named]") || !f.Keep(namedCow) {
+		t.Error("rule not removed by its normalised form")
+	}
+
+	if _, err := ParseRule("*[unknown]"); err == nil {
+		t.Error("expected error for unknown condition")
+	}
+}
@@ -0,0 +1,78 @@
+package entity
+
+import "github.com/sandertv/gophertunnel/minecraft/protocol"
+
+// ItemEncoder turns a network item into the nbt it is saved as, nil for an empty item
+type ItemEncoder func(stack protocol.ItemStack) map[string]any
+
+// emptyItem is what the game saves for an empty slot in lists where the position is the slot
+func emptyItem() map[string]any {
+	return map[string]any{
+		"Name":        "",
+		"Count":       byte(0),
+		"Damage":      int16(0),
+		"WasPickedUp": false,
+	}
+}
+
+// FixedItemList encodes items where the position in the list is the slot, empty slots are kept
+func FixedItemList(items []protocol.ItemInstance, n int, encode ItemEncoder) []map[string]any {
+	out := make([]map[string]any, n)
+	for i := range out {
+		if i < len(items) {
+			out[i] = encode(items[i].Stack)
+		}
+		if out[i] == nil {
+			out[i] = emptyItem()
+		}
+	}
+	return out
+}
+
+// slotItemList encodes the items that arent empty with their slot
+func slotItemList(items []protocol.ItemInstance, encode ItemEncoder) []map[string]any {
+	var out []map[string]any
+	for i, ii := range items {
+		data := encode(ii.Stack)
+		if data == nil {
+			continue
+		}
+		data["Slot"] = byte(i)
+		out = append(out, data)
+	}
+	return out
+}
+
+// SetHeldItem stores the item of a MobEquipment, the offhand window is saved as Offhand and all others as Mainhand
+func (s *Entity) SetHeldItem(windowID, slot byte, item protocol.ItemInstance, encode ItemEncoder) {
+	if s.Inventory == nil {
+		s.Inventory = make(map[byte]map[byte]protocol.ItemInstance)
+	}
+	inv, ok := s.Inventory[windowID]
+	if !ok {
+		inv = make(map[byte]protocol.ItemInstance)
+		s.Inventory[windowID] = inv
+	}
+	inv[slot] = item
+
+	held := FixedItemList([]protocol.ItemInstance{item}, 1, encode)
+	if windowID == protocol.WindowIDOffHand {
+		s.SetExtraNBT("Offhand", held)
+	} else {
+		s.SetExtraNBT("Mainhand", held)
+	}
+}
+
+// SetArmour stores the armour of a MobArmourEquipment, also for armour stands
+func (s *Entity) SetArmour(helmet, chestplate, leggings, boots protocol.ItemInstance, encode ItemEncoder) {
+	s.Helmet = &helmet
+	s.Chestplate = &chestplate
+	s.Leggings = &leggings
+	s.Boots = &boots
+	s.SetExtraNBT("Armor", FixedItemList([]protocol.ItemInstance{helmet, chestplate, leggings, boots}, 4, encode))
+}
+
+// SetChestItems stores the contents of a container opened on the entity, like a chest boat or donkey
+func (s *Entity) SetChestItems(items []protocol.ItemInstance, encode ItemEncoder) {
+	s.SetExtraNBT("ChestItems", slotItemList(items, encode))
+}
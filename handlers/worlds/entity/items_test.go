@@ -0,0 +1,116 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+var testItemNames = map[int32]string{1: "minecraft:diamond_sword", 2: "minecraft:shield", 3: "minecraft:iron_helmet", 4: "minecraft:iron_boots", 5: "minecraft:dirt"}
+
+// testEncoder encodes items by their network id, 0 is air
+func testEncoder(stack protocol.ItemStack) map[string]any {
+	if stack.NetworkID == 0 {
+		return nil
+	}
+	return map[string]any{"Name": testItemNames[stack.NetworkID], "Count": byte(stack.Count)}
+}
+
+func testItem(id int32, count uint16) protocol.ItemInstance {
+	return protocol.ItemInstance{Stack: protocol.ItemStack{ItemType: protocol.ItemType{NetworkID: id}, Count: count}}
+}
+
+func names(items []map[string]any) []string {
+	var out []string
+	for _, it := range items {
+		out = append(out, it["Name"].(string))
+	}
+	return out
+}
+
+func TestFixedItemList(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []protocol.ItemInstance
+		n     int
+		want  []string
+	}{
+		{"full", []protocol.ItemInstance{testItem(3, 1), testItem(4, 1)}, 2, []string{"minecraft:iron_helmet", "minecraft:iron_boots"}},
+		{"empty slots stay in place", []protocol.ItemInstance{testItem(0, 0), testItem(4, 1)}, 2, []string{"", "minecraft:iron_boots"}},
+		{"short list is padded", []protocol.ItemInstance{testItem(3, 1)}, 4, []string{"minecraft:iron_helmet", "", "", ""}},
+		{"long list is cut", []protocol.ItemInstance{testItem(3, 1), testItem(4, 1)}, 1, []string{"minecraft:iron_helmet"}},
+	}
+	for _, tt := range tests {
+		got := FixedItemList(tt.items, tt.n, testEncoder)
+		if !reflect.DeepEqual(names(got), tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, names(got), tt.want)
+		}
+		for i, it := range got {
+			if it["Name"] == "" && !reflect.DeepEqual(it, emptyItem()) {
+				t.Errorf("%s: slot %d is %v, not an empty item", tt.name, i, it)
+			}
+		}
+	}
+}
+
+func TestSetHeldItem(t *testing.T) {
+	e := &Entity{}
+	e.SetHeldItem(protocol.WindowIDInventory, 3, testItem(1, 1), testEncoder)
+	e.SetHeldItem(protocol.WindowIDOffHand, 0, testItem(2, 1), testEncoder)
+	if got := names(e.ExtraNBT["Mainhand"].([]map[string]any)); !reflect.DeepEqual(got, []string{"minecraft:diamond_sword"}) {
+		t.Errorf("Mainhand = %v", got)
+	}
+	if got := names(e.ExtraNBT["Offhand"].([]map[string]any)); !reflect.DeepEqual(got, []string{"minecraft:shield"}) {
+		t.Errorf("Offhand = %v", got)
+	}
+	if e.Inventory[protocol.WindowIDInventory][3].Stack.NetworkID != 1 || e.Inventory[protocol.WindowIDOffHand][0].Stack.NetworkID != 2 {
+		t.Errorf("inventory not kept %v", e.Inventory)
+	}
+
+	// putting the item away leaves an empty hand, not the old item
+	e.SetHeldItem(protocol.WindowIDInventory, 3, testItem(0, 0), testEncoder)
+	if got := e.ExtraNBT["Mainhand"].([]map[string]any); len(got) != 1 || !reflect.DeepEqual(got[0], emptyItem()) {
+		t.Errorf("Mainhand after putting it away = %v", got)
+	}
+}
+
+func TestSetArmour(t *testing.T) {
+	e := &Entity{EntityType: "minecraft:armor_stand"}
+	e.SetArmour(testItem(3, 1), testItem(0, 0), testItem(0, 0), testItem(4, 1), testEncoder)
+	want := []string{"minecraft:iron_helmet", "", "", "minecraft:iron_boots"}
+	if got := names(e.ExtraNBT["Armor"].([]map[string]any)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Armor = %v, want %v", got, want)
+	}
+	if e.Helmet.Stack.NetworkID != 3 || e.Leggings.Stack.NetworkID != 0 || e.Boots.Stack.NetworkID != 4 {
+		t.Errorf("armour fields %v %v %v", e.Helmet, e.Leggings, e.Boots)
+	}
+
+	// the armour is written into the saved entity
+	nbt := e.ToServerEntity(nil).EntityType.NBT
+	if got := names(nbt["Armor"].([]map[string]any)); !reflect.DeepEqual(got, want) {
+		t.Errorf("saved Armor = %v", got)
+	}
+}
+
+func TestSetChestItems(t *testing.T) {
+	e := &Entity{EntityType: "minecraft:chest_boat"}
+	e.SetChestItems([]protocol.ItemInstance{testItem(5, 64), testItem(0, 0), testItem(1, 1)}, testEncoder)
+	want := []map[string]any{
+		{"Name": "minecraft:dirt", "Count": byte(64), "Slot": byte(0)},
+		{"Name": "minecraft:diamond_sword", "Count": byte(1), "Slot": byte(2)},
+	}
+	if got := e.ExtraNBT["ChestItems"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("ChestItems = %v, want %v", got, want)
+	}
+	nbt := e.ToServerEntity(nil).EntityType.NBT
+	if !reflect.DeepEqual(nbt["ChestItems"], want) {
+		t.Errorf("saved ChestItems = %v", nbt["ChestItems"])
+	}
+
+	// an emptied chest saves no items
+	e.SetChestItems([]protocol.ItemInstance{testItem(0, 0)}, testEncoder)
+	if items := e.ExtraNBT["ChestItems"].([]map[string]any); len(items) != 0 {
+		t.Errorf("ChestItems after emptying = %v", items)
+	}
+}
@@ -0,0 +1,75 @@
+package worlds
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+func (w *worldsHandler) addEntityFilterCommands() {
+	filter := w.settings.EntityFilter
+
+	w.session.AddCommand(func(s []string) bool {
+		if len(s) == 0 {
+			s = []string{"list"}
+		}
+		switch s[0] {
+		case "list":
+			rules := filter.Rules()
+			if len(rules) == 0 {
+				w.session.SendMessage("no entity filter rules, saving all entities")
+				return true
+			}
+			w.session.SendMessage("entity filter rules:")
+			for _, r := range rules {
+				w.session.SendMessage("  " + r)
+			}
+		case "add":
+			if len(s) < 2 {
+				w.session.SendMessage("usage: /entity-filter add <rule>")
+				return true
+			}
+			rule := strings.Join(s[1:], " ")
+			if err := filter.Add(rule); err != nil {
+				w.session.SendMessage(err.Error())
+				return true
+			}
+			w.session.SendMessage(fmt.Sprintf("added %s", rule))
+		case "remove":
+			if len(s) < 2 {
+				w.session.SendMessage("usage: /entity-filter remove <rule>")
+				return true
+			}
+			rule := strings.Join(s[1:], " ")
+			if !filter.Remove(rule) {
+				w.session.SendMessage(fmt.Sprintf("no rule %s", rule))
+				return true
+			}
+			w.session.SendMessage(fmt.Sprintf("removed %s", rule))
+		case "clear":
+			filter.Clear()
+			w.session.SendMessage("removed all entity filter rules")
+		default:
+			w.session.SendMessage("usage: /entity-filter [list|add <rule>|remove <rule>|clear]")
+		}
+		return true
+	}, protocol.Command{
+		Name:        "entity-filter",
+		Description: "change which entities are saved, rules look like minecraft:*_golem, +*[named] or -*[baby]",
+	})
+
+	w.session.AddCommand(func(s []string) bool {
+		for _, mob := range s {
+			if err := filter.Add(mob); err != nil {
+				w.session.SendMessage(err.Error())
+				return true
+			}
+		}
+		w.session.SendMessage(fmt.Sprintf("Excluding: %s", strings.Join(filter.Rules(), ", ")))
+		return true
+	}, protocol.Command{
+		Name:        "exclude-mob",
+		Description: "add a mob to the list of mobs to ignore",
+	})
+}
@@ -1,6 +1,7 @@
 package worlds
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"image"
@@ -8,6 +9,7 @@ import (
 	"image/draw"
 	"math"
 	"net"
+	"slices"
 	"sync"
 	"time"
 
@@ -20,6 +22,7 @@ import (
 	"github.com/df-mc/dragonfly/server/world/chunk"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
 	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"github.com/sandertv/gophertunnel/minecraft/resource"
 	"github.com/sirupsen/logrus"
 )
 
@@ -64,21 +67,30 @@ func (m *MapUI) GetBounds() (Min, Max protocol.ChunkPos) {
 
 type renderElem struct {
 	ch  *chunk.Chunk
+	img *image.RGBA // already rendered ch
 	pos protocol.ChunkPos
 
 	isDeferredState bool
+	isBaseline      bool
 }
 
 type MapUI struct {
-	log            *logrus.Entry
-	img            *image.RGBA // rendered image
-	renderQueue    *lockfree.Queue
+	log         *logrus.Entry
+	img         *image.RGBA // rendered image
+	renderQueue *lockfree.Queue
+	// chunks waiting to be rendered by the render goroutine, once the colors are known
+	pendingRender  *lockfree.Queue
+	renderWake     chan struct{}
 	renderedChunks map[protocol.ChunkPos]*image.RGBA // prerendered chunks
 	oldRendered    map[protocol.ChunkPos]*image.RGBA
+	textured       map[protocol.ChunkPos]struct{} // rendered chunks that have more than a pixel per block
 	ticker         *time.Ticker
 	w              *worldsHandler
 
-	ChunkRenderer *utils.ChunkRenderer
+	// replaced as a whole under l when the mode changes, read it with renderer
+	chunkRenderer *utils.ChunkRenderer
+	markers       *mapMarkers
+	web           *webMap // nil unless the web map is enabled
 
 	l          sync.Mutex
 	haveColors chan struct{}
@@ -94,12 +106,16 @@ func NewMapUI(w *worldsHandler) *MapUI {
 		img:            image.NewRGBA(image.Rect(0, 0, 128, 128)),
 		zoomLevel:      16,
 		renderQueue:    lockfree.NewQueue(),
+		pendingRender:  lockfree.NewQueue(),
+		renderWake:     make(chan struct{}, 1),
 		renderedChunks: make(map[protocol.ChunkPos]*image.RGBA),
 		oldRendered:    make(map[protocol.ChunkPos]*image.RGBA),
+		textured:       make(map[protocol.ChunkPos]struct{}),
 		needRedraw:     true,
 		w:              w,
 		haveColors:     make(chan struct{}),
-		ChunkRenderer:  &utils.ChunkRenderer{},
+		chunkRenderer:  &utils.ChunkRenderer{Textured: w.settings.MapTextures},
+		markers:        newMapMarkers(),
 	}
 	return m
 }
@@ -131,13 +147,33 @@ func (m *MapUI) Start(ctx context.Context) {
 		return
 	}
 
+	messages.Router.AddHandler("mapui", m.HandleMessage)
+
 	m.ticker = time.NewTicker(33 * time.Millisecond)
 	go func() {
-		m.ChunkRenderer.ResolveColors(m.w.serverState.customBlocks, m.w.session.Server.ResourcePacks())
+		packs := m.w.session.Server.ResourcePacks()
+		if m.w.settings.VanillaPack != "" {
+			pack, err := resource.ReadPath(m.w.settings.VanillaPack)
+			if err != nil {
+				m.log.Error(err)
+			} else {
+				packs = append(packs, pack)
+			}
+		}
+		cr := *m.renderer()
+		cr.Biomes = m.w.serverState.biomes
+		cr.ResolveColors(m.w.serverState.customBlocks, packs)
+		m.l.Lock()
+		// keep a mode that was set while resolving
+		cr.Mode, cr.SliceY = m.chunkRenderer.Mode, m.chunkRenderer.SliceY
+		m.chunkRenderer = &cr
+		m.l.Unlock()
 		close(m.haveColors)
 	}()
+	go m.renderLoop(ctx)
 	go func() {
 		var oldPos mgl32.Vec3
+		var lastMarkers time.Time
 		for range m.ticker.C {
 			if ctx.Err() != nil {
 				return
@@ -147,10 +183,16 @@ func (m *MapUI) Start(ctx context.Context) {
 				m.needRedraw = true
 				oldPos = newPos
 			}
+			// other players and entities move on their own
+			if time.Since(lastMarkers) > 500*time.Millisecond && m.markers.moving() {
+				m.needRedraw = true
+			}
 
 			if m.needRedraw {
 				m.needRedraw = false
-				m.redraw()
+				lastMarkers = time.Now()
+				markers := m.collectMarkers()
+				decorations := m.redraw(markers)
 
 				if err := m.w.session.ClientWritePacket(&packet.ClientBoundMapItemData{
 					MapID:       ViewMapID,
@@ -158,7 +200,8 @@ func (m *MapUI) Start(ctx context.Context) {
 					Width:       128,
 					Height:      128,
 					Pixels:      utils.Img2rgba(m.img),
-					UpdateFlags: packet.MapUpdateFlagTexture,
+					Decorations: decorations,
+					UpdateFlags: packet.MapUpdateFlagTexture | packet.MapUpdateFlagDecoration,
 				}); err != nil {
 					if errors.Is(err, net.ErrClosed) {
 						return
@@ -188,6 +231,7 @@ func (m *MapUI) Start(ctx context.Context) {
 }
 
 func (m *MapUI) Stop() {
+	messages.Router.RemoveHandler("mapui")
 	if m.ticker != nil {
 		m.ticker.Stop()
 	}
@@ -198,6 +242,7 @@ func (m *MapUI) Reset() {
 	m.l.Lock()
 	m.renderedChunks = make(map[protocol.ChunkPos]*image.RGBA)
 	m.oldRendered = make(map[protocol.ChunkPos]*image.RGBA)
+	m.textured = make(map[protocol.ChunkPos]struct{})
 	messages.Router.Handle(&messages.Message{
 		Source: "mapui",
 		Target: "ui",
@@ -205,6 +250,9 @@ func (m *MapUI) Reset() {
 			ChunkCount: -1,
 		},
 	})
+	if m.web != nil {
+		m.web.broadcast(&webMapUpdate{Reset: true})
+	}
 	m.l.Unlock()
 	m.SchedRedraw()
 }
@@ -225,6 +273,48 @@ func (m *MapUI) SchedRedraw() {
 
 var red = image.NewUniform(color.RGBA{R: 0xff, G: 0, B: 0, A: 128})
 
+// blue tints chunks that came from a preloaded world and werent seen in this session yet
+var blue = image.NewUniform(color.RGBA{R: 0, G: 0x60, B: 0xff, A: 64})
+
+// a textured chunk is 256KB, only this many closest to the player are kept, the rest are scaled down to a pixel per block
+const maxTexturedChunks = 512
+
+// downscaleChunk returns a textured chunk image at a pixel per block
+func downscaleChunk(img *image.RGBA) *image.RGBA {
+	if img.Rect.Dx() == 16 {
+		return img
+	}
+	small := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	utils.DrawImgScaledPos(small, img, image.Point{}, 16)
+	return small
+}
+
+// evictTextured scales down the textured chunks furthest from the player until at most maxTexturedChunks are left
+func (m *MapUI) evictTextured(updatedChunks []protocol.ChunkPos) []protocol.ChunkPos {
+	if len(m.textured) <= maxTexturedChunks {
+		return updatedChunks
+	}
+	pos := m.w.session.Player.Position
+	middle := protocol.ChunkPos{int32(pos.X()) >> 4, int32(pos.Z()) >> 4}
+	dist := func(p protocol.ChunkPos) int64 {
+		dx, dz := int64(p.X()-middle.X()), int64(p.Z()-middle.Z())
+		return dx*dx + dz*dz
+	}
+	far := make([]protocol.ChunkPos, 0, len(m.textured))
+	for p := range m.textured {
+		far = append(far, p)
+	}
+	slices.SortFunc(far, func(a, b protocol.ChunkPos) int {
+		return cmp.Compare(dist(b), dist(a))
+	})
+	for _, p := range far[:len(far)-maxTexturedChunks] {
+		m.renderedChunks[p] = downscaleChunk(m.renderedChunks[p])
+		delete(m.textured, p)
+		updatedChunks = append(updatedChunks, p)
+	}
+	return updatedChunks
+}
+
 func (m *MapUI) processQueue() []protocol.ChunkPos {
 	<-m.haveColors
 
@@ -235,17 +325,28 @@ func (m *MapUI) processQueue() []protocol.ChunkPos {
 			break
 		}
 		if r.ch != nil {
-			img := m.ChunkRenderer.Chunk2Img(r.ch)
+			img := r.img
+			if img == nil {
+				img = m.chunkRenderer.Chunk2Img(r.ch)
+			}
 			if r.isDeferredState {
 				if old, ok := m.renderedChunks[r.pos]; ok {
-					m.oldRendered[r.pos] = old
+					m.oldRendered[r.pos] = downscaleChunk(old)
 				}
 				draw.Draw(img, img.Rect, red, image.Point{}, draw.Over)
+			} else if r.isBaseline {
+				draw.Draw(img, img.Rect, blue, image.Point{}, draw.Over)
 			}
 
 			m.renderedChunks[r.pos] = img
+			if img.Rect.Dx() != 16 {
+				m.textured[r.pos] = struct{}{}
+			} else {
+				delete(m.textured, r.pos)
+			}
 			updatedChunks = append(updatedChunks, r.pos)
 		} else {
+			delete(m.textured, r.pos)
 			if img, ok := m.oldRendered[r.pos]; ok {
 				m.renderedChunks[r.pos] = img
 			} else {
@@ -253,11 +354,11 @@ func (m *MapUI) processQueue() []protocol.ChunkPos {
 			}
 		}
 	}
-	return updatedChunks
+	return m.evictTextured(updatedChunks)
 }
 
-// redraw draws chunk images to the map image
-func (m *MapUI) redraw() {
+// redraw draws chunk images to the map image and returns the markers as decorations for it
+func (m *MapUI) redraw(markers []messages.MapMarker) []protocol.MapDecoration {
 	m.l.Lock()
 	defer m.l.Unlock()
 	updatedChunks := m.processQueue()
@@ -297,9 +398,14 @@ func (m *MapUI) redraw() {
 				Rotation:      m.w.session.Player.Yaw,
 				UpdatedChunks: updatedChunks,
 				Chunks:        m.renderedChunks,
+				Markers:       markers,
 			},
 		})
 	}
+	if m.web != nil {
+		m.web.update(updatedChunks, markers, m.w.session.Player.Position, m.w.session.Player.Yaw)
+	}
+	return markersToDecorations(markers, m.w.session.Player.Position, pxPerBlock)
 }
 
 func (m *MapUI) ToImage() *image.RGBA {
@@ -318,6 +424,11 @@ func (m *MapUI) ToImage() *image.RGBA {
 			int((pos.X()-min.X())*16),
 			int((pos.Z()-min.Z())*16),
 		)
+		if tile.Rect.Dx() != 16 {
+			// textured chunks are scaled down to a pixel per block
+			utils.DrawImgScaledPos(img, tile, px, 16)
+			continue
+		}
 		draw.Draw(img, image.Rect(
 			px.X, px.Y,
 			px.X+16, px.Y+16,
@@ -326,11 +437,66 @@ func (m *MapUI) ToImage() *image.RGBA {
 	return img
 }
 
+// enqueue adds a chunk to be drawn. it is called with the world locks held,
+// so once the colors are known rendering is left to the render goroutine and the map ticker only has to place it
+func (m *MapUI) enqueue(r *renderElem) {
+	select {
+	case <-m.haveColors:
+		// removals go the same way so they stay in order with the renders
+		m.pendingRender.Enqueue(r)
+		select {
+		case m.renderWake <- struct{}{}:
+		default:
+		}
+	default:
+		m.renderQueue.Enqueue(r)
+		m.SchedRedraw()
+	}
+}
+
+// renderer returns the current chunk renderer, it is not changed after being returned
+func (m *MapUI) renderer() *utils.ChunkRenderer {
+	m.l.Lock()
+	defer m.l.Unlock()
+	return m.chunkRenderer
+}
+
+// renderLoop renders the chunks added by enqueue
+func (m *MapUI) renderLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.renderWake:
+		}
+		cr := m.renderer()
+		for {
+			r, ok := m.pendingRender.Dequeue().(*renderElem)
+			if !ok {
+				break
+			}
+			if r.ch != nil {
+				r.img = cr.Chunk2Img(r.ch)
+			}
+			m.renderQueue.Enqueue(r)
+			m.SchedRedraw()
+		}
+	}
+}
+
 func (m *MapUI) SetChunk(pos world.ChunkPos, ch *chunk.Chunk, isDeferredState bool) {
-	m.renderQueue.Enqueue(&renderElem{
+	m.enqueue(&renderElem{
 		ch:              ch,
 		pos:             (protocol.ChunkPos)(pos),
 		isDeferredState: isDeferredState,
 	})
-	m.SchedRedraw()
+}
+
+// SetBaselineChunk shows a chunk that was loaded from a preloaded world
+func (m *MapUI) SetBaselineChunk(pos world.ChunkPos, ch *chunk.Chunk) {
+	m.enqueue(&renderElem{
+		ch:         ch,
+		pos:        (protocol.ChunkPos)(pos),
+		isBaseline: true,
+	})
 }
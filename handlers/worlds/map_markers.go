@@ -0,0 +1,262 @@
+package worlds
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds/entity"
+	"github.com/bedrock-tool/bedrocktool/ui/messages"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+const (
+	markerSelf      = "self"
+	markerPlayers   = "players"
+	markerEntities  = "entities"
+	markerWaypoints = "waypoints"
+)
+
+var markerLayers = []string{markerSelf, markerPlayers, markerEntities, markerWaypoints}
+
+// decoration type of each layer on the ingame map
+var markerDecorations = map[string]byte{
+	markerSelf:      protocol.MapDecorationTypeMarkerWhite,
+	markerPlayers:   protocol.MapDecorationTypeMarkerBlue,
+	markerEntities:  protocol.MapDecorationTypeMarkerRed,
+	markerWaypoints: protocol.MapDecorationTypeMarkerYellow,
+}
+
+type waypoint struct {
+	name      string
+	pos       mgl32.Vec3
+	dimension world.Dimension
+}
+
+type mapMarkers struct {
+	l           sync.Mutex
+	layers      map[string]bool
+	entityTypes []string
+	waypoints   []waypoint
+}
+
+func newMapMarkers() *mapMarkers {
+	return &mapMarkers{
+		layers: map[string]bool{
+			markerSelf:      true,
+			markerPlayers:   true,
+			markerEntities:  true,
+			markerWaypoints: true,
+		},
+	}
+}
+
+// moving is true when a layer is shown that changes without the local player moving
+func (m *mapMarkers) moving() bool {
+	m.l.Lock()
+	defer m.l.Unlock()
+	return m.layers[markerPlayers] || (m.layers[markerEntities] && len(m.entityTypes) > 0)
+}
+
+// collectMarkers gets the markers of all shown layers, has to be called without the map lock held
+func (m *MapUI) collectMarkers() (markers []messages.MapMarker) {
+	mm := m.markers
+	mm.l.Lock()
+	defer mm.l.Unlock()
+
+	w := m.w
+	if mm.layers[markerSelf] {
+		markers = append(markers, messages.MapMarker{
+			Layer:    markerSelf,
+			Position: w.session.Player.Position,
+			Yaw:      w.session.Player.Yaw,
+		})
+	}
+
+	w.worldStateLock.Lock()
+	defer w.worldStateLock.Unlock()
+	if w.currentWorld == nil {
+		return markers
+	}
+
+	if mm.layers[markerPlayers] {
+		w.currentWorld.RangePlayers(func(name string, pos mgl32.Vec3, yaw float32) {
+			markers = append(markers, messages.MapMarker{
+				Layer:    markerPlayers,
+				Label:    name,
+				Position: pos,
+				Yaw:      yaw,
+			})
+		})
+	}
+	if mm.layers[markerEntities] && len(mm.entityTypes) > 0 {
+		w.currentWorld.RangeEntities(func(es *entity.Entity) {
+			if !slices.Contains(mm.entityTypes, es.EntityType) {
+				return
+			}
+			markers = append(markers, messages.MapMarker{
+				Layer:    markerEntities,
+				Label:    es.EntityType,
+				Position: es.Position,
+				Yaw:      es.Yaw,
+			})
+		})
+	}
+	if mm.layers[markerWaypoints] {
+		dim := w.currentWorld.Dimension()
+		for _, wp := range mm.waypoints {
+			if wp.dimension != dim {
+				continue
+			}
+			markers = append(markers, messages.MapMarker{
+				Layer:    markerWaypoints,
+				Label:    wp.name,
+				Position: wp.pos,
+			})
+		}
+	}
+	return markers
+}
+
+// markersToDecorations converts markers to decorations on the ingame map centered on middle
+func markersToDecorations(markers []messages.MapMarker, middle mgl32.Vec3, pxPerBlock float64) (decorations []protocol.MapDecoration) {
+	for _, marker := range markers {
+		// decoration offsets are in half pixels from the center
+		x := math.Floor(float64(marker.Position.X()-middle.X()) * pxPerBlock * 2)
+		y := math.Floor(float64(marker.Position.Z()-middle.Z()) * pxPerBlock * 2)
+		if x < -128 || x > 127 || y < -128 || y > 127 {
+			continue
+		}
+		yaw := math.Mod(float64(marker.Yaw)+360, 360)
+		decorations = append(decorations, protocol.MapDecoration{
+			Type:     markerDecorations[marker.Layer],
+			Rotation: byte(int(math.Round(yaw*16/360)) & 15),
+			X:        byte(int8(x)),
+			Y:        byte(int8(y)),
+			Label:    marker.Label,
+			Colour:   color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		})
+	}
+	return decorations
+}
+
+func (w *worldsHandler) addMarkerCommands() {
+	markers := w.mapUI.markers
+
+	w.session.AddCommand(func(s []string) bool {
+		markers.l.Lock()
+		defer markers.l.Unlock()
+		if len(s) == 0 {
+			var states []string
+			for _, layer := range markerLayers {
+				state := "off"
+				if markers.layers[layer] {
+					state = "on"
+				}
+				states = append(states, layer+": "+state)
+			}
+			w.session.SendMessage("map markers " + strings.Join(states, ", "))
+			return true
+		}
+		layer := s[0]
+		if !slices.Contains(markerLayers, layer) {
+			w.session.SendMessage("usage: /markers [self|players|entities|waypoints]")
+			return true
+		}
+		markers.layers[layer] = !markers.layers[layer]
+		if markers.layers[layer] {
+			w.session.SendMessage(fmt.Sprintf("showing %s on the map", layer))
+		} else {
+			w.session.SendMessage(fmt.Sprintf("hiding %s on the map", layer))
+		}
+		w.mapUI.SchedRedraw()
+		return true
+	}, protocol.Command{
+		Name:        "markers",
+		Description: "toggle a layer of map markers, usage: /markers [self|players|entities|waypoints]",
+	})
+
+	w.session.AddCommand(func(s []string) bool {
+		markers.l.Lock()
+		defer markers.l.Unlock()
+		for _, entityType := range s {
+			if !strings.Contains(entityType, ":") {
+				entityType = "minecraft:" + entityType
+			}
+			if i := slices.Index(markers.entityTypes, entityType); i >= 0 {
+				markers.entityTypes = slices.Delete(markers.entityTypes, i, i+1)
+			} else {
+				markers.entityTypes = append(markers.entityTypes, entityType)
+			}
+		}
+		if len(markers.entityTypes) == 0 {
+			w.session.SendMessage("no entities are marked on the map")
+		} else {
+			w.session.SendMessage("marking " + strings.Join(markers.entityTypes, ", "))
+		}
+		w.mapUI.SchedRedraw()
+		return true
+	}, protocol.Command{
+		Name:        "mark-entity",
+		Description: "toggle showing entities of a type on the map, usage: /mark-entity <type> [type...]",
+	})
+
+	w.session.AddCommand(func(s []string) bool {
+		markers.l.Lock()
+		defer markers.l.Unlock()
+		if len(s) == 0 {
+			s = []string{"list"}
+		}
+		switch s[0] {
+		case "list":
+			if len(markers.waypoints) == 0 {
+				w.session.SendMessage("no waypoints")
+				return true
+			}
+			for _, wp := range markers.waypoints {
+				w.session.SendMessage(fmt.Sprintf("%s: %.0f %.0f %.0f", wp.name, wp.pos.X(), wp.pos.Y(), wp.pos.Z()))
+			}
+		case "add":
+			if len(s) < 2 {
+				w.session.SendMessage("usage: /waypoint add <name>")
+				return true
+			}
+			name := strings.Join(s[1:], " ")
+			w.worldStateLock.Lock()
+			dim := w.currentWorld.Dimension()
+			w.worldStateLock.Unlock()
+			markers.waypoints = append(markers.waypoints, waypoint{
+				name:      name,
+				pos:       w.session.Player.Position,
+				dimension: dim,
+			})
+			w.session.SendMessage(fmt.Sprintf("added waypoint %s", name))
+		case "remove":
+			if len(s) < 2 {
+				w.session.SendMessage("usage: /waypoint remove <name>")
+				return true
+			}
+			name := strings.Join(s[1:], " ")
+			i := slices.IndexFunc(markers.waypoints, func(wp waypoint) bool { return wp.name == name })
+			if i < 0 {
+				w.session.SendMessage(fmt.Sprintf("no waypoint %s", name))
+				return true
+			}
+			markers.waypoints = slices.Delete(markers.waypoints, i, i+1)
+			w.session.SendMessage(fmt.Sprintf("removed waypoint %s", name))
+		default:
+			w.session.SendMessage("usage: /waypoint [list|add <name>|remove <name>]")
+			return true
+		}
+		w.mapUI.SchedRedraw()
+		return true
+	}, protocol.Command{
+		Name:        "waypoint",
+		Description: "mark places on the map, usage: /waypoint [list|add <name>|remove <name>]",
+	})
+}
@@ -0,0 +1,79 @@
+package worlds
+
+import (
+	"testing"
+
+	"github.com/bedrock-tool/bedrocktool/ui/messages"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+func TestMarkersToDecorations(t *testing.T) {
+	middle := mgl32.Vec3{100, 64, -100}
+	tests := []struct {
+		name       string
+		marker     messages.MapMarker
+		pxPerBlock float64
+		want       *protocol.MapDecoration
+	}{
+		{
+			name:       "center",
+			marker:     messages.MapMarker{Layer: markerSelf, Position: middle},
+			pxPerBlock: 1,
+			want:       &protocol.MapDecoration{Type: protocol.MapDecorationTypeMarkerWhite},
+		},
+		{
+			name:       "offset and rotated",
+			marker:     messages.MapMarker{Layer: markerPlayers, Label: "steve", Position: mgl32.Vec3{110, 64, -120}, Yaw: 90},
+			pxPerBlock: 1,
+			want:       &protocol.MapDecoration{Type: protocol.MapDecorationTypeMarkerBlue, Rotation: 4, X: 20, Y: byte(0x100 - 40), Label: "steve"},
+		},
+		{
+			name:       "negative yaw",
+			marker:     messages.MapMarker{Layer: markerEntities, Position: middle, Yaw: -90},
+			pxPerBlock: 1,
+			want:       &protocol.MapDecoration{Type: protocol.MapDecorationTypeMarkerRed, Rotation: 12},
+		},
+		{
+			name:       "yaw rounds around to 0",
+			marker:     messages.MapMarker{Layer: markerWaypoints, Position: middle, Yaw: 359},
+			pxPerBlock: 1,
+			want:       &protocol.MapDecoration{Type: protocol.MapDecorationTypeMarkerYellow},
+		},
+		{
+			name:       "left edge",
+			marker:     messages.MapMarker{Layer: markerSelf, Position: mgl32.Vec3{36, 64, -100}},
+			pxPerBlock: 1,
+			want:       &protocol.MapDecoration{Type: protocol.MapDecorationTypeMarkerWhite, X: 0x80},
+		},
+		{
+			name:       "past the right edge",
+			marker:     messages.MapMarker{Layer: markerSelf, Position: mgl32.Vec3{164, 64, -100}},
+			pxPerBlock: 1,
+		},
+		{
+			name:       "zoomed out",
+			marker:     messages.MapMarker{Layer: markerSelf, Position: mgl32.Vec3{164, 64, -100}},
+			pxPerBlock: 0.5,
+			want:       &protocol.MapDecoration{Type: protocol.MapDecorationTypeMarkerWhite, X: 64},
+		},
+	}
+	for _, tt := range tests {
+		got := markersToDecorations([]messages.MapMarker{tt.marker}, middle, tt.pxPerBlock)
+		if tt.want == nil {
+			if len(got) != 0 {
+				t.Errorf("%s: got %+v, want no decoration", tt.name, got)
+			}
+			continue
+		}
+		if len(got) != 1 {
+			t.Errorf("%s: got %d decorations, want 1", tt.name, len(got))
+			continue
+		}
+		d := got[0]
+		want := *tt.want
+		if d.Type != want.Type || d.Rotation != want.Rotation || d.X != want.X || d.Y != want.Y || d.Label != want.Label {
+			t.Errorf("%s: got %+v, want %+v", tt.name, d, want)
+		}
+	}
+}
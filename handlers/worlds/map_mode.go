@@ -0,0 +1,96 @@
+package worlds
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bedrock-tool/bedrocktool/ui/messages"
+	"github.com/bedrock-tool/bedrocktool/utils"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// SetMode switches how chunks are drawn and draws the chunks on the map again
+func (m *MapUI) SetMode(mode utils.RenderMode, sliceY int) {
+	m.l.Lock()
+	positions := make([]protocol.ChunkPos, 0, len(m.renderedChunks))
+	for pos := range m.renderedChunks {
+		positions = append(positions, pos)
+	}
+	// the render goroutine may be using the old renderer, so a changed copy replaces it
+	cr := *m.chunkRenderer
+	cr.Mode = mode
+	cr.SliceY = sliceY
+	m.chunkRenderer = &cr
+	m.l.Unlock()
+
+	m.Reset()
+
+	go func() {
+		for _, pos := range positions {
+			m.w.worldStateLock.Lock()
+			if m.w.currentWorld == nil {
+				m.w.worldStateLock.Unlock()
+				return
+			}
+			col, ok, err := m.w.currentWorld.LoadChunk(world.ChunkPos(pos))
+			m.w.worldStateLock.Unlock()
+			if err != nil {
+				m.log.Error(err)
+				continue
+			}
+			if ok {
+				m.SetChunk(world.ChunkPos(pos), col.Chunk, false)
+			}
+		}
+	}()
+}
+
+// HandleMessage takes map settings from the ui
+func (m *MapUI) HandleMessage(msg *messages.Message) *messages.Message {
+	switch data := msg.Data.(type) {
+	case messages.SetMapMode:
+		mode, err := utils.ParseRenderMode(data.Mode)
+		if err != nil {
+			m.log.Warn(err)
+			return nil
+		}
+		m.SetMode(mode, int(m.w.session.Player.Position.Y()))
+	}
+	return nil
+}
+
+func (w *worldsHandler) addMapModeCommand() {
+	usage := "usage: /map-mode <" + strings.Join(utils.RenderModeNames(), "|") + "> [y for cave]"
+	w.session.AddCommand(func(s []string) bool {
+		if len(s) == 0 {
+			w.session.SendMessage(fmt.Sprintf("map mode is %s", w.mapUI.renderer().Mode))
+			w.session.SendMessage(usage)
+			return true
+		}
+		mode, err := utils.ParseRenderMode(s[0])
+		if err != nil {
+			w.session.SendMessage(err.Error())
+			return true
+		}
+		sliceY := int(w.session.Player.Position.Y())
+		if len(s) > 1 {
+			sliceY, err = strconv.Atoi(s[1])
+			if err != nil {
+				w.session.SendMessage(usage)
+				return true
+			}
+		}
+		w.mapUI.SetMode(mode, sliceY)
+		if mode == utils.RenderCave {
+			w.session.SendMessage(fmt.Sprintf("map shows caves below y %d", sliceY))
+		} else {
+			w.session.SendMessage(fmt.Sprintf("map mode set to %s", mode))
+		}
+		return true
+	}, protocol.Command{
+		Name:        "map-mode",
+		Description: "change what the map shows, " + usage,
+	})
+}
@@ -0,0 +1,59 @@
+package worlds
+
+import (
+	"fmt"
+
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds/worldstate"
+	"github.com/bedrock-tool/bedrocktool/utils"
+)
+
+// OutputMode is what gets left on disk after saving a world
+type OutputMode int
+
+const (
+	// the world folder and the mcworld file
+	OutputBoth OutputMode = iota
+	// only the mcworld file, the folder is removed once the zip is written
+	OutputMcworld
+	// only the world folder
+	OutputFolder
+)
+
+func ParseOutputMode(s string) (OutputMode, error) {
+	switch s {
+	case "both", "":
+		return OutputBoth, nil
+	case "mcworld":
+		return OutputMcworld, nil
+	case "folder":
+		return OutputFolder, nil
+	}
+	return 0, fmt.Errorf("unknown output %q, use both, mcworld or folder", s)
+}
+
+// writeMcworld zips the closed world folder into filename, then removes the folder if asked.
+// leveldb needs a folder to write to, so the zip is made from the folder of the closed provider
+func writeMcworld(filename, folder string, removeFolder bool) error {
+	err := utils.WriteMcworld(filename, folder)
+	if err != nil {
+		return err
+	}
+	if removeFolder {
+		return utils.RemoveTree(folder)
+	}
+	return nil
+}
+
+// RecoverCapture turns the folder of a crashed capture into a finished world and keeps it like output says,
+// it returns the mcworld file or the folder if there is none
+func RecoverCapture(folder string, output OutputMode) (string, error) {
+	err := worldstate.RecoverFolder(folder)
+	if err != nil {
+		return "", err
+	}
+	if output == OutputFolder {
+		return folder, nil
+	}
+	filename := folder + ".mcworld"
+	return filename, writeMcworld(filename, folder, output == OutputMcworld)
+}
@@ -10,11 +10,10 @@ import (
 	"time"
 
 	"github.com/bedrock-tool/bedrocktool/handlers/worlds/entity"
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds/worldstate"
 	"github.com/bedrock-tool/bedrocktool/locale"
 	"github.com/bedrock-tool/bedrocktool/utils"
-	"github.com/bedrock-tool/bedrocktool/utils/nbtconv"
 	"github.com/df-mc/dragonfly/server/block/cube"
-	"github.com/df-mc/dragonfly/server/item/inventory"
 	"github.com/df-mc/dragonfly/server/world"
 	"github.com/go-gl/mathgl/mgl32"
 	"github.com/sandertv/gophertunnel/minecraft/nbt"
@@ -23,10 +22,6 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func (w *worldsHandler) getEntity(id entity.RuntimeID) *entity.Entity {
-	return w.currentWorld.GetEntity(id)
-}
-
 func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived time.Time, preLogin bool) (packet.Packet, error) {
 	drop := w.scripting.OnPacket(_pk, toServer, timeReceived)
 	if drop {
@@ -115,7 +110,7 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 						w.log.Info(locale.Loc("using_under_118", nil))
 						w.serverState.dimensions[0] = protocol.DimensionDefinition{
 							Name:      "minecraft:overworld",
-							Range:     [2]int32{0, 256},
+							Range:     [2]int32{256, 0},
 							Generator: 1,
 						}
 					}
@@ -127,10 +122,24 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 
 		case *packet.DimensionData:
 			for _, dd := range pk.Definitions {
-				if dd.Name == "minecraft:overworld" {
-					w.serverState.dimensions[0] = dd
+				id, ok := worldstate.DimensionIDByName(dd.Name)
+				if !ok {
+					w.log.Warnf("unknown dimension %s", dd.Name)
+					continue
 				}
+				w.serverState.dimensions[id] = dd
+				dim, _ := world.DimensionByID(id)
+				if r := worldstate.DimensionRange(dd); r != dim.Range() {
+					w.log.Infof("%s has a custom height %d to %d", dd.Name, r.Min(), r.Max())
+					w.serverState.behaviorPack.AddDimension(dd.Name, r.Min(), r.Max()+1)
+				}
+			}
+			// the range may have changed, chunk workers read it under the lock
+			w.worldStateLock.Lock()
+			if dim := w.currentWorld.Dimension(); dim != nil {
+				w.currentWorld.SetDimension(dim)
 			}
+			w.worldStateLock.Unlock()
 
 		case *packet.ItemComponent:
 			w.serverState.behaviorPack.ApplyComponentEntries(pk.Items)
@@ -142,16 +151,8 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 				w.log.WithField("packet", "BiomeDefinitionList").Error(err)
 			}
 
-			for k, v := range biomes {
-				_, ok := w.serverState.biomes.BiomeByName(k)
-				if !ok {
-					w.serverState.biomes.Register(&customBiome{
-						name: k,
-						data: v.(map[string]any),
-					})
-				}
-			}
-			w.serverState.behaviorPack.AddBiomes(biomes)
+			customBiomes := registerCustomBiomes(w.serverState.biomes, biomes)
+			w.serverState.behaviorPack.AddBiomes(customBiomes)
 		}
 
 		return _pk, nil
@@ -189,7 +190,12 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 	case *packet.BlockActorData:
 		p := pk.Position
 		pos := cube.Pos{int(p.X()), int(p.Y()), int(p.Z())}
-		w.currentWorld.SetBlockNBT(pos, pk.NBTData, false)
+		// goes through the chunk pool so it lands after the chunk it is in
+		w.chunkPool.Enqueue(world.ChunkPos{p.X() >> 4, p.Z() >> 4}, func() {
+			w.worldStateLock.Lock()
+			defer w.worldStateLock.Unlock()
+			w.currentWorld.SetBlockNBT(pos, pk.NBTData, false)
+		})
 
 	case *packet.UpdateBlock:
 		if w.settings.BlockUpdates {
@@ -199,7 +205,7 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 			}
 			apply := w.scripting.OnBlockUpdate(name, properties, pk.Position, timeReceived)
 			if apply {
-				w.currentWorld.QueueBlockUpdate(pk.Position, rid, uint8(pk.Layer))
+				w.queueBlockUpdate(pk.Position, rid, uint8(pk.Layer), timeReceived)
 			}
 		}
 
@@ -211,7 +217,7 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 			}
 			apply := w.scripting.OnBlockUpdate(name, properties, pk.Position, timeReceived)
 			if apply {
-				w.currentWorld.QueueBlockUpdate(pk.Position, rid, uint8(pk.Layer))
+				w.queueBlockUpdate(pk.Position, rid, uint8(pk.Layer), timeReceived)
 			}
 		}
 
@@ -224,7 +230,7 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 				}
 				apply := w.scripting.OnBlockUpdate(name, properties, block.BlockPos, timeReceived)
 				if apply {
-					w.currentWorld.QueueBlockUpdate(block.BlockPos, rid, uint8(0))
+					w.queueBlockUpdate(block.BlockPos, rid, uint8(0), timeReceived)
 				}
 			}
 
@@ -238,8 +244,12 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 
 		// player
 	case *packet.AddPlayer:
-		//w.currentWorld.AddPlayer(pk)
+		w.currentWorld.AddPlayer(pk)
 		//w.addPlayer(pk)
+	case *packet.MovePlayer:
+		w.currentWorld.MovePlayer(pk.EntityRuntimeID, pk.Position, pk.Pitch, pk.Yaw, pk.HeadYaw)
+	case *packet.RemoveActor:
+		w.currentWorld.RemovePlayer(pk.EntityUniqueID)
 	case *packet.PlayerList:
 		if pk.ActionType == packet.PlayerListActionAdd { // remove
 			for _, player := range pk.Entries {
@@ -256,24 +266,26 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 		w.syncActorProperty(pk)
 
 	case *packet.AddActor:
-		ent := w.currentWorld.GetEntity(pk.EntityRuntimeID)
-		if ent == nil {
-			ent = &entity.Entity{
+		var ent *entity.Entity
+		apply := func(e *entity.Entity) {
+			e.Position = pk.Position
+			e.Pitch = pk.Pitch
+			e.Yaw = pk.Yaw
+			e.HeadYaw = pk.HeadYaw
+			e.Velocity = pk.Velocity
+			w.applyEntityData(e, pk.EntityMetadata, pk.EntityProperties, timeReceived)
+			ent = e
+		}
+		if !w.currentWorld.UpdateEntity(pk.EntityRuntimeID, apply) {
+			apply(&entity.Entity{
 				RuntimeID:  pk.EntityRuntimeID,
 				UniqueID:   pk.EntityUniqueID,
 				EntityType: pk.EntityType,
 				Inventory:  make(map[byte]map[byte]protocol.ItemInstance),
 				Metadata:   make(map[uint32]any),
 				Properties: make(map[string]*entity.EntityProperty),
-			}
+			})
 		}
-		ent.Position = pk.Position
-		ent.Pitch = pk.Pitch
-		ent.Yaw = pk.Yaw
-		ent.HeadYaw = pk.HeadYaw
-		ent.Velocity = pk.Velocity
-
-		w.applyEntityData(ent, pk.EntityMetadata, pk.EntityProperties, timeReceived)
 
 		if !w.scripting.OnEntityAdd(ent, timeReceived) {
 			logrus.Infof("Ignoring Entity: %s %d", ent.EntityType, ent.UniqueID)
@@ -299,18 +311,18 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 		*/
 
 	case *packet.SetActorData:
-		if entity := w.getEntity(pk.EntityRuntimeID); entity != nil {
-			w.applyEntityData(entity, pk.EntityMetadata, pk.EntityProperties, timeReceived)
-			w.serverState.behaviorPack.AddEntity(entity.EntityType, nil, entity.Metadata, entity.Properties)
-		}
+		w.currentWorld.UpdateEntity(pk.EntityRuntimeID, func(e *entity.Entity) {
+			w.applyEntityData(e, pk.EntityMetadata, pk.EntityProperties, timeReceived)
+			w.serverState.behaviorPack.AddEntity(e.EntityType, nil, e.Metadata, e.Properties)
+		})
 
 	case *packet.SetActorMotion:
-		if e := w.getEntity(pk.EntityRuntimeID); e != nil {
+		w.currentWorld.UpdateEntity(pk.EntityRuntimeID, func(e *entity.Entity) {
 			e.Velocity = pk.Velocity
-		}
+		})
 
 	case *packet.MoveActorDelta:
-		if e := w.getEntity(pk.EntityRuntimeID); e != nil {
+		w.currentWorld.UpdateEntity(pk.EntityRuntimeID, func(e *entity.Entity) {
 			if pk.Flags&packet.MoveActorDeltaFlagHasX != 0 {
 				e.Position[0] = pk.Position[0]
 			}
@@ -329,38 +341,52 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 			if !e.Velocity.ApproxEqual(mgl32.Vec3{}) {
 				e.HasMoved = true
 			}
-		}
+		})
 
 	case *packet.MoveActorAbsolute:
-		if e := w.getEntity(pk.EntityRuntimeID); e != nil {
+		w.currentWorld.UpdateEntity(pk.EntityRuntimeID, func(e *entity.Entity) {
 			e.Position = pk.Position
 			e.Pitch = pk.Rotation.X()
 			e.Yaw = pk.Rotation.Y()
 			if !e.Velocity.ApproxEqual(mgl32.Vec3{}) {
 				e.HasMoved = true
 			}
-		}
+		})
 
 	case *packet.MobEquipment:
+		if pk.EntityRuntimeID == w.session.Player.RuntimeID && pk.WindowID == protocol.WindowIDInventory {
+			w.serverState.playerSelectedSlot = pk.HotBarSlot
+		}
 		if pk.NewItem.Stack.NBTData["map_uuid"] == int64(ViewMapID) {
 			_pk = nil
 		} else {
-			if e := w.getEntity(pk.EntityRuntimeID); e != nil {
-				w, ok := e.Inventory[pk.WindowID]
-				if !ok {
-					w = make(map[byte]protocol.ItemInstance)
-					e.Inventory[pk.WindowID] = w
-				}
-				w[pk.HotBarSlot] = pk.NewItem
-			}
+			w.currentWorld.UpdateEntity(pk.EntityRuntimeID, func(e *entity.Entity) {
+				e.SetHeldItem(pk.WindowID, pk.HotBarSlot, pk.NewItem, w.itemNBT)
+			})
 		}
 
 	case *packet.MobArmourEquipment:
-		if e := w.getEntity(pk.EntityRuntimeID); e != nil {
-			e.Helmet = &pk.Helmet
-			e.Chestplate = &pk.Chestplate
-			e.Leggings = &pk.Chestplate
-			e.Boots = &pk.Boots
+		w.currentWorld.UpdateEntity(pk.EntityRuntimeID, func(e *entity.Entity) {
+			e.SetArmour(pk.Helmet, pk.Chestplate, pk.Leggings, pk.Boots, w.itemNBT)
+		})
+
+	case *packet.UpdateTrade:
+		w.updateTrade(pk)
+
+	case *packet.UpdateAttributes:
+		if pk.EntityRuntimeID == w.session.Player.RuntimeID {
+			for _, a := range pk.Attributes {
+				w.serverState.playerAttributes[a.Name] = a
+			}
+		}
+
+	case *packet.MobEffect:
+		if pk.EntityRuntimeID == w.session.Player.RuntimeID {
+			if pk.Operation == packet.MobEffectRemove {
+				delete(w.serverState.playerEffects, pk.EffectType)
+			} else {
+				w.serverState.playerEffects[pk.EffectType] = pk
+			}
 		}
 
 	case *packet.SetActorLink:
@@ -432,8 +458,8 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 		}
 
 	case *packet.InventoryContent:
-		if pk.WindowID == 0x0 { // inventory
-			w.serverState.playerInventory = pk.Content
+		if inv := w.playerWindow(pk.WindowID); inv != nil {
+			*inv = pk.Content
 		} else {
 			// save content
 			existing, ok := w.serverState.openItemContainers[byte(pk.WindowID)]
@@ -446,15 +472,10 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 		}
 
 	case *packet.InventorySlot:
-		if pk.WindowID == 0x0 {
-			if w.serverState.playerInventory == nil {
-				w.serverState.playerInventory = make([]protocol.ItemInstance, 36)
-			}
-			w.serverState.playerInventory[pk.Slot] = pk.NewItem
-		} else {
-			// save content
+		if !w.setPlayerSlot(pk.WindowID, pk.Slot, pk.NewItem) {
+			// save content, containers are as big as their InventoryContent
 			existing, ok := w.serverState.openItemContainers[byte(pk.WindowID)]
-			if ok {
+			if ok && existing.Content != nil && pk.Slot < uint32(len(existing.Content.Content)) {
 				existing.Content.Content[pk.Slot] = pk.NewItem
 			}
 		}
@@ -464,13 +485,11 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 		existing, ok := w.serverState.openItemContainers[byte(pk.WindowID)]
 
 		switch pk.WindowID {
-		case protocol.WindowIDArmour: // todo handle
-		case protocol.WindowIDOffHand: // todo handle
+		// the players own windows are kept up to date from InventoryContent and InventorySlot
+		case protocol.WindowIDArmour:
+		case protocol.WindowIDOffHand:
 		case protocol.WindowIDUI:
-		case protocol.WindowIDInventory: // todo handle
-			if !ok {
-				break
-			}
+		case protocol.WindowIDInventory:
 
 		default:
 			if !ok {
@@ -482,19 +501,35 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 				break
 			}
 
-			// create inventory
-			inv := inventory.New(len(existing.Content.Content), nil)
-			for i, c := range existing.Content.Content {
-				item := utils.StackToItem(w.serverState.blocks, c.Stack)
-				inv.SetItem(i, item)
+			if uid := existing.OpenPacket.ContainerEntityUniqueID; uid != -1 {
+				// container of an entity, like a chest boat or donkey
+				content := existing.Content.Content
+				if w.currentWorld.UpdateEntityUniqueID(uid, func(e *entity.Entity) {
+					e.SetChestItems(content, w.itemNBT)
+				}) {
+					w.session.SendMessage(locale.Loc("saved_block_inv", nil))
+				}
+				delete(w.serverState.openItemContainers, byte(pk.WindowID))
+				break
 			}
 
-			// put into subchunk
 			p := existing.OpenPacket.ContainerPosition
 			pos := cube.Pos{int(p.X()), int(p.Y()), int(p.Z())}
-			w.currentWorld.SetBlockNBT(pos, map[string]any{
-				"Items": nbtconv.InvToNBT(inv),
-			}, true)
+			content := existing.Content.Content
+			// goes through the chunk pool so the block is looked up after the chunk it is in arrived
+			w.chunkPool.Enqueue(world.ChunkPos{p.X() >> 4, p.Z() >> 4}, func() {
+				w.worldStateLock.Lock()
+				defer w.worldStateLock.Unlock()
+				if name, _ := w.currentWorld.BlockName(pos); name == "minecraft:ender_chest" {
+					// ender chest contents belong to the player
+					w.serverState.playerEnderChest = content
+				} else {
+					// put into subchunk
+					w.currentWorld.SetBlockNBT(pos, map[string]any{
+						"Items": utils.ItemsToNBT(w.serverState.blocks, content),
+					}, true)
+				}
+			})
 
 			w.session.SendMessage(locale.Loc("saved_block_inv", nil))
 
@@ -506,6 +541,24 @@ func (w *worldsHandler) packetCB(_pk packet.Packet, toServer bool, timeReceived
 	return _pk, nil
 }
 
+// queueBlockUpdate goes through the chunk pool so the update lands after the chunk it is in
+func (w *worldsHandler) queueBlockUpdate(pos protocol.BlockPos, rid uint32, layer uint8, timeReceived time.Time) {
+	w.chunkPool.Enqueue(world.ChunkPos{pos.X() >> 4, pos.Z() >> 4}, func() {
+		w.worldStateLock.Lock()
+		defer w.worldStateLock.Unlock()
+		w.currentWorld.QueueBlockUpdate(pos, rid, layer, timeReceived)
+	})
+}
+
+// updateTrade stores the offers a villager has so they still trade in the saved world
+func (w *worldsHandler) updateTrade(pk *packet.UpdateTrade) {
+	w.currentWorld.UpdateEntityUniqueID(pk.VillagerUniqueID, func(e *entity.Entity) {
+		if err := e.SetTradeOffers(pk.SerialisedOffers, pk.TradeTier); err != nil {
+			w.log.WithField("entity", e.EntityType).Warnf("failed to read trade offers: %s", err)
+		}
+	})
+}
+
 func (w *worldsHandler) syncActorProperty(pk *packet.SyncActorProperty) {
 	entityType, ok := pk.PropertyData["type"].(string)
 	if !ok {
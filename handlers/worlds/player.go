@@ -1,23 +1,88 @@
 package worlds
 
 import (
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds/entity"
 	"github.com/bedrock-tool/bedrocktool/utils"
-	"github.com/bedrock-tool/bedrocktool/utils/nbtconv"
-	"github.com/df-mc/dragonfly/server/item/inventory"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
 )
 
+// playerWindow returns the stored contents of one of the players own windows
+func (w *worldsHandler) playerWindow(windowID uint32) *[]protocol.ItemInstance {
+	switch windowID {
+	case protocol.WindowIDInventory:
+		return &w.serverState.playerInventory
+	case protocol.WindowIDArmour:
+		return &w.serverState.playerArmour
+	case protocol.WindowIDOffHand:
+		return &w.serverState.playerOffhand
+	case protocol.WindowIDUI:
+		return &w.serverState.playerUI
+	}
+	return nil
+}
+
+// playerWindowSize is how many slots the players own windows have, slots past it are ignored
+func playerWindowSize(windowID uint32) int {
+	switch windowID {
+	case protocol.WindowIDInventory:
+		return 36
+	case protocol.WindowIDArmour:
+		return 4
+	case protocol.WindowIDOffHand:
+		return 1
+	case protocol.WindowIDUI:
+		return 54
+	}
+	return 0
+}
+
+// setPlayerSlot stores an InventorySlot of one of the players own windows, false if the window isnt one of them
+func (w *worldsHandler) setPlayerSlot(windowID, slot uint32, item protocol.ItemInstance) bool {
+	inv := w.playerWindow(windowID)
+	if inv == nil {
+		return false
+	}
+	if slot >= uint32(playerWindowSize(windowID)) {
+		w.log.Debugf("ignoring slot %d of window %d", slot, windowID)
+		return true
+	}
+	if int(slot) >= len(*inv) {
+		*inv = append(*inv, make([]protocol.ItemInstance, int(slot)-len(*inv)+1)...)
+	}
+	(*inv)[slot] = item
+	return true
+}
+
+// itemNBT encodes an item the way it is saved in the world
+func (w *worldsHandler) itemNBT(stack protocol.ItemStack) map[string]any {
+	return utils.ItemToNBT(w.serverState.blocks, stack)
+}
+
 func (w *worldsHandler) playerData() (ret map[string]any) {
 	ret = map[string]any{
 		"format_version": "1.12.0",
 		"identifier":     "minecraft:player",
 	}
 
-	if len(w.serverState.playerInventory) > 0 && w.settings.SaveInventories {
-		inv := inventory.New(len(w.serverState.playerInventory), nil)
-		for i, ii := range w.serverState.playerInventory {
-			inv.SetItem(i, utils.StackToItem(w.serverState.blocks, ii.Stack))
+	if w.settings.SaveInventories {
+		if len(w.serverState.playerInventory) > 0 {
+			ret["Inventory"] = utils.ItemsToNBT(w.serverState.blocks, w.serverState.playerInventory)
 		}
-		ret["Inventory"] = nbtconv.InvToNBT(inv)
+		if len(w.serverState.playerArmour) > 0 {
+			ret["Armor"] = entity.FixedItemList(w.serverState.playerArmour, 4, w.itemNBT)
+		}
+		if len(w.serverState.playerOffhand) > 0 {
+			ret["Offhand"] = entity.FixedItemList(w.serverState.playerOffhand, 1, w.itemNBT)
+		}
+		if len(w.serverState.playerEnderChest) > 0 {
+			ret["EnderChestInventory"] = utils.ItemsToNBT(w.serverState.blocks, w.serverState.playerEnderChest)
+		}
+		if len(w.serverState.playerUI) > 0 {
+			// slot 0 is the item held by the cursor
+			ret["PlayerUIItems"] = utils.ItemsToNBT(w.serverState.blocks, w.serverState.playerUI[:1])
+		}
+		ret["SelectedInventorySlot"] = int32(w.serverState.playerSelectedSlot)
+		ret["SelectedContainerId"] = int32(0)
 	}
 
 	ret["abilities"] = map[string]any{
@@ -50,7 +115,7 @@ func (w *worldsHandler) playerData() (ret map[string]any) {
 		Min        float32
 	}
 
-	ret["Attributes"] = []attribute{
+	attributes := []attribute{
 		{
 			Base:       0,
 			Current:    0,
@@ -179,6 +244,42 @@ func (w *worldsHandler) playerData() (ret map[string]any) {
 		},
 	}
 
+	// use the values the server sent where there are any
+	for i, a := range attributes {
+		if sa, ok := w.serverState.playerAttributes[a.Name]; ok {
+			attributes[i].Base = sa.Default
+			attributes[i].Current = sa.Value
+			attributes[i].Min = sa.Min
+			attributes[i].Max = sa.Max
+		}
+	}
+	ret["Attributes"] = attributes
+
+	if a, ok := w.serverState.playerAttributes["minecraft:player.level"]; ok {
+		ret["PlayerLevel"] = int32(a.Value)
+	}
+	if a, ok := w.serverState.playerAttributes["minecraft:player.experience"]; ok {
+		ret["PlayerLevelProgress"] = a.Value
+	}
+
+	if len(w.serverState.playerEffects) > 0 {
+		var effects []map[string]any
+		for _, e := range w.serverState.playerEffects {
+			effects = append(effects, map[string]any{
+				"Id":                              byte(e.EffectType),
+				"Amplifier":                       byte(e.Amplifier),
+				"Duration":                        e.Duration,
+				"DurationEasy":                    e.Duration,
+				"DurationNormal":                  e.Duration,
+				"DurationHard":                    e.Duration,
+				"Ambient":                         false,
+				"ShowParticles":                   e.Particles,
+				"DisplayOnScreenTextureAnimation": false,
+			})
+		}
+		ret["ActiveEffects"] = effects
+	}
+
 	ret["Tags"] = []string{}
 	ret["OnGround"] = true
 
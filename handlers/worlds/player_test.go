@@ -0,0 +1,170 @@
+package worlds
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bedrock-tool/bedrocktool/utils/proxy"
+	"github.com/df-mc/dragonfly/server/item"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"github.com/sirupsen/logrus"
+)
+
+func testPlayerItem(t *testing.T, it world.Item, count uint16) protocol.ItemInstance {
+	rid, meta, ok := world.ItemRuntimeID(it)
+	if !ok {
+		t.Fatalf("no runtime id for %T", it)
+	}
+	return protocol.ItemInstance{Stack: protocol.ItemStack{
+		ItemType: protocol.ItemType{NetworkID: rid, MetadataValue: uint32(meta)},
+		Count:    count,
+	}}
+}
+
+func newTestPlayerHandler(saveInventories bool) *worldsHandler {
+	return &worldsHandler{
+		log:     logrus.WithField("part", "test"),
+		session: &proxy.Session{Player: proxy.Player{Position: mgl32.Vec3{10.5, 64, -3.5}, Pitch: 5, Yaw: 90}},
+		serverState: serverState{
+			playerAttributes: make(map[string]protocol.Attribute),
+			playerEffects:    make(map[int32]*packet.MobEffect),
+		},
+		settings: WorldSettings{SaveInventories: saveInventories},
+	}
+}
+
+// itemNames is the name of every item in a list, "" for empty slots
+func itemNames(items any) []string {
+	var out []string
+	for _, it := range items.([]map[string]any) {
+		out = append(out, it["Name"].(string))
+	}
+	return out
+}
+
+func itemSlots(items any) []byte {
+	var out []byte
+	for _, it := range items.([]map[string]any) {
+		out = append(out, it["Slot"].(byte))
+	}
+	return out
+}
+
+func TestSetPlayerSlot(t *testing.T) {
+	w := newTestPlayerHandler(true)
+	diamond := testPlayerItem(t, item.Diamond{}, 1)
+
+	tests := []struct {
+		name     string
+		windowID uint32
+		slot     uint32
+		player   bool
+		wantLen  int
+	}{
+		{"inventory", protocol.WindowIDInventory, 8, true, 9},
+		{"last inventory slot", protocol.WindowIDInventory, 35, true, 36},
+		{"past the inventory", protocol.WindowIDInventory, 36, true, 36},
+		{"armour", protocol.WindowIDArmour, 3, true, 4},
+		{"past the armour", protocol.WindowIDArmour, 4, true, 4},
+		{"offhand", protocol.WindowIDOffHand, 0, true, 1},
+		{"past the offhand", protocol.WindowIDOffHand, 1, true, 1},
+		{"container", 5, 0, false, 0},
+	}
+	for _, tt := range tests {
+		if got := w.setPlayerSlot(tt.windowID, tt.slot, diamond); got != tt.player {
+			t.Errorf("%s: setPlayerSlot = %v, want %v", tt.name, got, tt.player)
+		}
+		if !tt.player {
+			continue
+		}
+		if got := len(*w.playerWindow(tt.windowID)); got != tt.wantLen {
+			t.Errorf("%s: window has %d slots, want %d", tt.name, got, tt.wantLen)
+		}
+	}
+}
+
+func TestPlayerData(t *testing.T) {
+	w := newTestPlayerHandler(true)
+	diamond := testPlayerItem(t, item.Diamond{}, 3)
+	stick := testPlayerItem(t, item.Stick{}, 1)
+	emerald := testPlayerItem(t, item.Emerald{}, 64)
+
+	// a full InventoryContent, then slot updates
+	inventory := make([]protocol.ItemInstance, 36)
+	inventory[0] = diamond
+	inventory[35] = stick
+	*w.playerWindow(protocol.WindowIDInventory) = inventory
+	w.setPlayerSlot(protocol.WindowIDArmour, 0, stick)
+	w.setPlayerSlot(protocol.WindowIDArmour, 3, diamond)
+	w.setPlayerSlot(protocol.WindowIDArmour, 4, emerald)
+	w.setPlayerSlot(protocol.WindowIDOffHand, 0, emerald)
+	w.setPlayerSlot(protocol.WindowIDOffHand, 1, diamond)
+	w.setPlayerSlot(protocol.WindowIDUI, 0, stick)
+	w.setPlayerSlot(protocol.WindowIDUI, 5, diamond)
+	w.serverState.playerEnderChest = []protocol.ItemInstance{{}, emerald}
+	w.serverState.playerSelectedSlot = 4
+	w.serverState.playerAttributes["minecraft:player.level"] = protocol.Attribute{
+		AttributeValue: protocol.AttributeValue{Name: "minecraft:player.level", Value: 12, Max: 24791},
+	}
+	w.serverState.playerEffects[packet.EffectSpeed] = &packet.MobEffect{EffectType: packet.EffectSpeed, Amplifier: 1, Duration: 600, Particles: true}
+
+	data := w.playerData()
+
+	if got := itemNames(data["Inventory"]); !reflect.DeepEqual(got, []string{"minecraft:diamond", "minecraft:stick"}) {
+		t.Errorf("Inventory = %v", got)
+	}
+	if got := itemSlots(data["Inventory"]); !reflect.DeepEqual(got, []byte{0, 35}) {
+		t.Errorf("Inventory slots = %v", got)
+	}
+	if got := itemNames(data["Armor"]); !reflect.DeepEqual(got, []string{"minecraft:stick", "", "", "minecraft:diamond"}) {
+		t.Errorf("Armor = %v", got)
+	}
+	if got := itemNames(data["Offhand"]); !reflect.DeepEqual(got, []string{"minecraft:emerald"}) {
+		t.Errorf("Offhand = %v", got)
+	}
+	if got := itemSlots(data["EnderChestInventory"]); !reflect.DeepEqual(got, []byte{1}) {
+		t.Errorf("EnderChestInventory slots = %v", got)
+	}
+	// only the item on the cursor is kept
+	if got := itemNames(data["PlayerUIItems"]); !reflect.DeepEqual(got, []string{"minecraft:stick"}) {
+		t.Errorf("PlayerUIItems = %v", got)
+	}
+	if data["SelectedInventorySlot"] != int32(4) {
+		t.Errorf("SelectedInventorySlot = %v", data["SelectedInventorySlot"])
+	}
+	if data["PlayerLevel"] != int32(12) {
+		t.Errorf("PlayerLevel = %v", data["PlayerLevel"])
+	}
+
+	effects := data["ActiveEffects"].([]map[string]any)
+	if len(effects) != 1 {
+		t.Fatalf("ActiveEffects = %v", effects)
+	}
+	e := effects[0]
+	if e["Id"] != byte(packet.EffectSpeed) || e["Amplifier"] != byte(1) || e["Duration"] != int32(600) || e["ShowParticles"] != true {
+		t.Errorf("effect = %v", e)
+	}
+
+	if !reflect.DeepEqual(data["Pos"], []float32{10.5, 64, -3.5}) || data["SpawnX"] != int32(10) {
+		t.Errorf("Pos = %v, SpawnX = %v", data["Pos"], data["SpawnX"])
+	}
+}
+
+func TestPlayerDataWithoutInventories(t *testing.T) {
+	w := newTestPlayerHandler(false)
+	w.setPlayerSlot(protocol.WindowIDInventory, 0, testPlayerItem(t, item.Diamond{}, 1))
+	w.serverState.playerEnderChest = []protocol.ItemInstance{testPlayerItem(t, item.Diamond{}, 1)}
+
+	data := w.playerData()
+	for _, key := range []string{"Inventory", "Armor", "Offhand", "EnderChestInventory", "PlayerUIItems", "SelectedInventorySlot"} {
+		if _, ok := data[key]; ok {
+			t.Errorf("%s saved without SaveInventories", key)
+		}
+	}
+	if _, ok := data["abilities"]; !ok {
+		t.Error("no abilities")
+	}
+}
@@ -0,0 +1,16 @@
+package worlds
+
+import "github.com/bedrock-tool/bedrocktool/handlers/worlds/worldstate"
+
+// writeStats writes stats.json and a readable summary next to the mcworld
+func (w *worldsHandler) writeStats(worldState *worldstate.World) error {
+	stats := worldState.Stats()
+	if stats == nil {
+		return nil
+	}
+	err := writeFile(worldState.Folder+".stats.json", stats.WriteJSON)
+	if err != nil {
+		return err
+	}
+	return writeFile(worldState.Folder+".stats.txt", stats.WriteSummary)
+}
@@ -0,0 +1,126 @@
+package worlds
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds/entity"
+	"github.com/bedrock-tool/bedrocktool/utils/structure"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/flytam/filenamify"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+type structureSelection struct {
+	pos1, pos2 *cube.Pos
+}
+
+func (w *worldsHandler) playerBlockPos() cube.Pos {
+	return cube.Pos{
+		int(math.Floor(float64(w.session.Player.Position[0]))),
+		int(math.Floor(float64(w.session.Player.Position[1] - 1.62))),
+		int(math.Floor(float64(w.session.Player.Position[2]))),
+	}
+}
+
+func (w *worldsHandler) addStructureCommands() {
+	w.session.AddCommand(func(s []string) bool {
+		pos := w.playerBlockPos()
+		w.selection.pos1 = &pos
+		w.session.SendMessage(fmt.Sprintf("pos1 set to %d %d %d", pos[0], pos[1], pos[2]))
+		return true
+	}, protocol.Command{
+		Name:        "pos1",
+		Description: "set the first corner of the structure to export",
+	})
+
+	w.session.AddCommand(func(s []string) bool {
+		pos := w.playerBlockPos()
+		w.selection.pos2 = &pos
+		w.session.SendMessage(fmt.Sprintf("pos2 set to %d %d %d", pos[0], pos[1], pos[2]))
+		return true
+	}, protocol.Command{
+		Name:        "pos2",
+		Description: "set the second corner of the structure to export",
+	})
+
+	w.session.AddCommand(func(s []string) bool {
+		var name string
+		var schem bool
+		for _, arg := range s {
+			if arg == "schem" {
+				schem = true
+			} else if name == "" {
+				name = arg
+			}
+		}
+		if name == "" {
+			name = "structure"
+		}
+		if err := w.exportStructure(name, schem); err != nil {
+			w.log.Error(err)
+			w.session.SendMessage(fmt.Sprintf("Failed to export structure: %s", err))
+		}
+		return true
+	}, protocol.Command{
+		Name:        "export-structure",
+		Description: "export the area between pos1 and pos2, usage: /export-structure <name> [schem]",
+	})
+}
+
+func (w *worldsHandler) exportStructure(name string, schem bool) error {
+	if w.selection.pos1 == nil || w.selection.pos2 == nil {
+		return fmt.Errorf("set both corners using /pos1 and /pos2 first")
+	}
+
+	w.worldStateLock.Lock()
+	w.currentWorld.ApplyBlockUpdates()
+	s, err := structure.Read(w.currentWorld, w.serverState.blocks, *w.selection.pos1, *w.selection.pos2)
+	if err != nil {
+		w.worldStateLock.Unlock()
+		return err
+	}
+	w.currentWorld.RangeEntities(func(es *entity.Entity) {
+		es2 := *es
+		data := es2.ToServerEntity(nil).EntityType.NBT
+		data["identifier"] = es2.EntityType
+		s.AddEntity(data)
+	})
+	w.worldStateLock.Unlock()
+
+	serverName, _ := filenamify.FilenamifyV2(w.serverState.Name)
+	fileName, _ := filenamify.FilenamifyV2(name)
+	folder := path.Join("worlds", serverName, "structures")
+	err = os.MkdirAll(folder, 0o777)
+	if err != nil {
+		return err
+	}
+
+	filename := path.Join(folder, fileName+".mcstructure")
+	err = writeFile(filename, s.WriteMCStructure)
+	if err != nil {
+		return err
+	}
+	if schem {
+		err = writeFile(path.Join(folder, fileName+".schem"), s.WriteSchematic)
+		if err != nil {
+			return err
+		}
+	}
+
+	w.log.Infof("Exported %d blocks to %s", s.BlockCount(), filename)
+	w.session.SendMessage(fmt.Sprintf("Exported %dx%dx%d structure to %s", s.Size[0], s.Size[1], s.Size[2], filename))
+	return nil
+}
+
+func writeFile(filename string, write func(w io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return write(f)
+}
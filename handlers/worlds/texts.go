@@ -0,0 +1,59 @@
+package worlds
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds/worldstate"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// writeTexts writes the text of signs and books next to the mcworld
+func (w *worldsHandler) writeTexts(worldState *worldstate.World) error {
+	if worldState.Texts.Len() == 0 {
+		return nil
+	}
+	err := writeFile(worldState.Folder+".texts.json", worldState.Texts.WriteJSON)
+	if err != nil {
+		return err
+	}
+	return writeFile(worldState.Folder+".texts.csv", worldState.Texts.WriteCSV)
+}
+
+func (w *worldsHandler) addFindTextCommand() {
+	w.session.AddCommand(func(s []string) bool {
+		query := strings.Join(s, " ")
+		if query == "" {
+			w.session.SendMessage("usage: /find-text <text>")
+			return true
+		}
+
+		w.worldStateLock.Lock()
+		found := w.currentWorld.Texts.Find(query)
+		w.worldStateLock.Unlock()
+
+		if len(found) == 0 {
+			w.session.SendMessage(fmt.Sprintf("no signs or books contain %q", query))
+			return true
+		}
+		w.session.SendMessage(fmt.Sprintf("found %q %d times:", query, len(found)))
+		for i, e := range found {
+			if i == 10 {
+				w.session.SendMessage(fmt.Sprintf("... and %d more", len(found)-i))
+				break
+			}
+			text := strings.ReplaceAll(e.Text, "\n", " ")
+			if r := []rune(text); len(r) > 60 {
+				text = string(r[:60]) + "..."
+			}
+			if e.Title != "" {
+				text = e.Title + ": " + text
+			}
+			w.session.SendMessage(fmt.Sprintf("%d %d %d %s: %s", e.Pos[0], e.Pos[1], e.Pos[2], e.Kind, text))
+		}
+		return true
+	}, protocol.Command{
+		Name:        "find-text",
+		Description: "search the text of signs and books captured so far, usage: /find-text <text>",
+	})
+}
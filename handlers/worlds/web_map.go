@@ -0,0 +1,231 @@
+package worlds
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/draw"
+	"image/png"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bedrock-tool/bedrocktool/ui/messages"
+	"github.com/bedrock-tool/bedrocktool/utils"
+	"github.com/bedrock-tool/bedrocktool/utils/leaflet"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+//go:embed web_map.html
+var webMapHTML []byte
+
+// at zoom 0 a tile is 256 blocks, every level out halves the resolution down to one pixel per chunk
+const (
+	webTileSize   = 256
+	webMinZoom    = -4
+	webTileChunks = webTileSize / 16
+)
+
+type webMapPlayer struct {
+	X   float32 `json:"x"`
+	Z   float32 `json:"z"`
+	Yaw float32 `json:"yaw"`
+}
+
+type webMapUpdate struct {
+	Reset   bool                 `json:"reset,omitempty"`
+	Tiles   [][2]int32           `json:"tiles,omitempty"` // zoom 0 tiles that changed
+	Player  *webMapPlayer        `json:"player,omitempty"`
+	Markers []messages.MapMarker `json:"markers"`
+}
+
+type webMapClient struct {
+	conn *utils.WebsocketConn
+	send chan []byte
+}
+
+// webMap serves the chunks of the MapUI as a slippy map and pushes updates over a websocket
+type webMap struct {
+	log     *logrus.Entry
+	mapUI   *MapUI
+	server  *http.Server
+	l       sync.Mutex
+	clients map[*webMapClient]struct{}
+}
+
+// startWebMap serves the map on addr, without a host like :8080 it only listens on localhost
+func startWebMap(addr string, mapUI *MapUI) (*webMap, error) {
+	if host, port, err := net.SplitHostPort(addr); err == nil && host == "" {
+		addr = net.JoinHostPort("localhost", port)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	wm := &webMap{
+		log:     logrus.WithField("part", "WebMap"),
+		mapUI:   mapUI,
+		clients: make(map[*webMapClient]struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", wm.handleIndex)
+	mux.HandleFunc("GET /tiles/{z}/{x}/{y}", wm.handleTile)
+	mux.HandleFunc("GET /ws", wm.handleWebsocket)
+	mux.Handle("GET /leaflet/", http.StripPrefix("/leaflet/", leaflet.Handler()))
+	wm.server = &http.Server{Handler: mux}
+
+	go func() {
+		err := wm.server.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			wm.log.Error(err)
+		}
+	}()
+	wm.log.Infof("serving the map on http://%s", ln.Addr())
+	return wm, nil
+}
+
+// setMapUI switches to the map of a new session
+func (wm *webMap) setMapUI(mapUI *MapUI) {
+	wm.l.Lock()
+	defer wm.l.Unlock()
+	wm.mapUI = mapUI
+	mapUI.web = wm
+}
+
+func (wm *webMap) Close() {
+	wm.server.Shutdown(context.Background())
+	wm.l.Lock()
+	defer wm.l.Unlock()
+	for c := range wm.clients {
+		c.conn.Close()
+		close(c.send)
+	}
+	wm.clients = make(map[*webMapClient]struct{})
+}
+
+func (wm *webMap) handleIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(webMapHTML)
+}
+
+func (wm *webMap) handleTile(w http.ResponseWriter, r *http.Request) {
+	z, err1 := strconv.Atoi(r.PathValue("z"))
+	x, err2 := strconv.Atoi(r.PathValue("x"))
+	y, err3 := strconv.Atoi(strings.TrimSuffix(r.PathValue("y"), ".png"))
+	if err := errors.Join(err1, err2, err3); err != nil || z > 0 || z < webMinZoom {
+		http.NotFound(w, r)
+		return
+	}
+
+	wm.l.Lock()
+	mapUI := wm.mapUI
+	wm.l.Unlock()
+	img := mapUI.RenderTile(z, x, y)
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	png.Encode(w, img)
+}
+
+func (wm *webMap) handleWebsocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := utils.UpgradeWebsocket(w, r)
+	if err != nil {
+		wm.log.Debug(err)
+		return
+	}
+	c := &webMapClient{conn: conn, send: make(chan []byte, 16)}
+	wm.l.Lock()
+	wm.clients[c] = struct{}{}
+	wm.l.Unlock()
+
+	go func() {
+		for data := range c.send {
+			if err := conn.WriteText(data); err != nil {
+				return
+			}
+		}
+	}()
+	conn.ReadLoop()
+
+	wm.l.Lock()
+	if _, ok := wm.clients[c]; ok {
+		delete(wm.clients, c)
+		close(c.send)
+	}
+	wm.l.Unlock()
+}
+
+// broadcast sends an update to all clients, clients that dont keep up miss it
+func (wm *webMap) broadcast(u *webMapUpdate) {
+	data, err := json.Marshal(u)
+	if err != nil {
+		wm.log.Error(err)
+		return
+	}
+	wm.l.Lock()
+	defer wm.l.Unlock()
+	for c := range wm.clients {
+		select {
+		case c.send <- data:
+		default:
+		}
+	}
+}
+
+// update tells the clients which tiles changed and where the player is
+func (wm *webMap) update(updatedChunks []protocol.ChunkPos, markers []messages.MapMarker, pos mgl32.Vec3, yaw float32) {
+	seen := make(map[[2]int32]bool)
+	var tiles [][2]int32
+	for _, cp := range updatedChunks {
+		tile := [2]int32{floorDiv(cp.X(), webTileChunks), floorDiv(cp.Z(), webTileChunks)}
+		if !seen[tile] {
+			seen[tile] = true
+			tiles = append(tiles, tile)
+		}
+	}
+	wm.broadcast(&webMapUpdate{
+		Tiles:   tiles,
+		Player:  &webMapPlayer{X: pos.X(), Z: pos.Z(), Yaw: yaw},
+		Markers: markers,
+	})
+}
+
+func floorDiv(a, b int32) int32 {
+	if a < 0 {
+		return (a - b + 1) / b
+	}
+	return a / b
+}
+
+// RenderTile draws tile x, y at zoom z from the rendered chunks
+func (m *MapUI) RenderTile(z, x, y int) *image.RGBA {
+	shift := -z
+	chunksPerSide := webTileChunks << shift
+	pxPerChunk := 16 >> shift
+	img := image.NewRGBA(image.Rect(0, 0, webTileSize, webTileSize))
+
+	m.l.Lock()
+	defer m.l.Unlock()
+	baseX, baseZ := x*chunksPerSide, y*chunksPerSide
+	for cz := 0; cz < chunksPerSide; cz++ {
+		for cx := 0; cx < chunksPerSide; cx++ {
+			tile, ok := m.renderedChunks[protocol.ChunkPos{int32(baseX + cx), int32(baseZ + cz)}]
+			if !ok {
+				continue
+			}
+			px := image.Pt(cx*pxPerChunk, cz*pxPerChunk)
+			if tile.Rect.Dx() == pxPerChunk {
+				draw.Draw(img, image.Rectangle{Min: px, Max: px.Add(image.Pt(pxPerChunk, pxPerChunk))}, tile, image.Point{}, draw.Src)
+			} else {
+				utils.DrawImgScaledPos(img, tile, px, pxPerChunk)
+			}
+		}
+	}
+	return img
+}
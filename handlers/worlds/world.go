@@ -1,7 +1,6 @@
 package worlds
 
 import (
-	"archive/zip"
 	"context"
 	"fmt"
 	"image/png"
@@ -10,7 +9,7 @@ import (
 	"net"
 	"os"
 	"path"
-	"slices"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -38,17 +37,31 @@ import (
 )
 
 type WorldSettings struct {
-	VoidGen         bool
+	Generator       worldstate.Generator
 	SaveImage       bool
 	SaveEntities    bool
 	SaveInventories bool
-	ExcludedMobs    []string
+	EntityFilter    *entity.Filter
 	StartPaused     bool
-	PreloadReplay   string
+	PreloadReplays  []string
+	PreloadWorlds   []string // world folders or mcworld files
+	Output          OutputMode
 	ChunkRadius     int32
 	Script          string
 	Players         bool
 	BlockUpdates    bool
+	BlockHistory    bool
+	CaptureBounds   *worldstate.CaptureBounds
+	// 0 disables checkpoints
+	CheckpointInterval time.Duration
+	// estimated bytes of chunks and entities to keep in memory before spilling to disk, 0 for no limit
+	MemoryBudget int64
+	// address to serve a live map in the browser on, empty to disable
+	WebMapAddress string
+	// draw the map with block textures
+	MapTextures bool
+	// path of the vanilla resource pack, used after the server packs for map colors and textures
+	VanillaPack string
 }
 
 type serverState struct {
@@ -68,6 +81,13 @@ type serverState struct {
 	customBlocks       []protocol.BlockEntry
 	openItemContainers map[byte]*itemContainer
 	playerInventory    []protocol.ItemInstance
+	playerArmour       []protocol.ItemInstance
+	playerOffhand      []protocol.ItemInstance
+	playerEnderChest   []protocol.ItemInstance
+	playerUI           []protocol.ItemInstance
+	playerSelectedSlot byte
+	playerAttributes   map[string]protocol.Attribute
+	playerEffects      map[int32]*packet.MobEffect
 	dimensions         map[int]protocol.DimensionDefinition
 	playerSkins        map[uuid.UUID]*protocol.Skin
 	entityProperties   map[string][]entity.EntityProperty
@@ -87,9 +107,18 @@ type worldsHandler struct {
 	// lock used for when the worldState gets swapped
 	currentWorld   *worldstate.World
 	worldStateLock sync.Mutex
+	// decodes chunks off the proxy loop
+	chunkPool *chunkPool
+	webMap    *webMap
 
 	serverState serverState
 	settings    WorldSettings
+	selection   structureSelection
+
+	checkpointOnce sync.Once
+	// preloaded worlds, opened once per capture
+	baselines     []baselineWorld
+	baselinesOnce sync.Once
 }
 
 type itemContainer struct {
@@ -98,9 +127,9 @@ type itemContainer struct {
 }
 
 func NewWorldsHandler(settings WorldSettings) *proxy.Handler {
-	settings.ExcludedMobs = slices.DeleteFunc(settings.ExcludedMobs, func(mob string) bool {
-		return mob == ""
-	})
+	if settings.EntityFilter == nil {
+		settings.EntityFilter, _ = entity.NewFilter(nil)
+	}
 
 	if settings.ChunkRadius == 0 {
 		settings.ChunkRadius = 76
@@ -109,9 +138,10 @@ func NewWorldsHandler(settings WorldSettings) *proxy.Handler {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	w := &worldsHandler{
-		ctx:      ctx,
-		log:      logrus.WithField("part", "WorldsHandler"),
-		settings: settings,
+		ctx:       ctx,
+		log:       logrus.WithField("part", "WorldsHandler"),
+		settings:  settings,
+		chunkPool: newChunkPool(runtime.NumCPU(), 64),
 	}
 
 	h := &proxy.Handler{
@@ -124,6 +154,8 @@ func NewWorldsHandler(settings WorldSettings) *proxy.Handler {
 				useOldBiomes:       false,
 				worldCounter:       0,
 				openItemContainers: make(map[byte]*itemContainer),
+				playerAttributes:   make(map[string]protocol.Attribute),
+				playerEffects:      make(map[int32]*packet.MobEffect),
 				dimensions:         make(map[int]protocol.DimensionDefinition),
 				playerSkins:        make(map[uuid.UUID]*protocol.Skin),
 				biomes:             world.DefaultBiomes.Clone(),
@@ -131,6 +163,15 @@ func NewWorldsHandler(settings WorldSettings) *proxy.Handler {
 			}
 
 			w.mapUI = NewMapUI(w)
+			if w.settings.WebMapAddress != "" {
+				if w.webMap == nil {
+					w.webMap, err = startWebMap(w.settings.WebMapAddress, w.mapUI)
+					if err != nil {
+						return err
+					}
+				}
+				w.webMap.setMapUI(w.mapUI)
+			}
 			w.scripting = scripting.New()
 
 			w.session.AddCommand(func(cmdline []string) bool {
@@ -141,19 +182,36 @@ func NewWorldsHandler(settings WorldSettings) *proxy.Handler {
 			})
 
 			w.session.AddCommand(func(cmdline []string) bool {
-				return w.setVoidGen(!w.currentWorld.VoidGen)
+				if w.currentWorld.Generator.Type == worldstate.GeneratorVoid {
+					if w.settings.Generator.Type != worldstate.GeneratorVoid {
+						return w.setGenerator(w.settings.Generator)
+					}
+					return w.setGenerator(worldstate.Generator{Type: worldstate.GeneratorDefault})
+				}
+				return w.setGenerator(worldstate.Generator{Type: worldstate.GeneratorVoid})
 			}, protocol.Command{
 				Name:        "void",
 				Description: locale.Loc("void_desc", nil),
 			})
 
-			w.session.AddCommand(func(s []string) bool {
-				w.settings.ExcludedMobs = append(w.settings.ExcludedMobs, s...)
-				w.session.SendMessage(fmt.Sprintf("Exluding: %s", strings.Join(w.settings.ExcludedMobs, ", ")))
-				return true
+			w.session.AddCommand(func(cmdline []string) bool {
+				if len(cmdline) == 0 {
+					w.session.SendMessage("Generator: " + w.currentWorld.Generator.String())
+					return true
+				}
+				var layers string
+				if len(cmdline) > 1 {
+					layers = strings.Join(cmdline[1:], ",")
+				}
+				generator, err := worldstate.ParseGenerator(cmdline[0], layers)
+				if err != nil {
+					w.session.SendMessage(err.Error())
+					return true
+				}
+				return w.setGenerator(generator)
 			}, protocol.Command{
-				Name:        "exclude-mob",
-				Description: "add a mob to the list of mobs to ignore",
+				Name:        "generator",
+				Description: "set what generates outside of the captured area, usage: /generator void|flat [layers]|default",
 			})
 
 			w.session.AddCommand(func(s []string) bool {
@@ -187,6 +245,14 @@ func NewWorldsHandler(settings WorldSettings) *proxy.Handler {
 				Description: "immediately save and reset the world state",
 			})
 
+			w.addStructureCommands()
+			w.addBoundsCommand()
+			w.addCoverageCommand()
+			w.addFindTextCommand()
+			w.addMarkerCommands()
+			w.addMapModeCommand()
+			w.addEntityFilterCommands()
+
 			w.serverState.behaviorPack = behaviourpack.New(serverName)
 			w.serverState.resourcePack = resourcepack.New()
 			w.serverState.Name = serverName
@@ -196,7 +262,11 @@ func NewWorldsHandler(settings WorldSettings) *proxy.Handler {
 			if err != nil {
 				return err
 			}
-			w.currentWorld.VoidGen = w.settings.VoidGen
+			w.currentWorld.Generator = w.settings.Generator
+			w.currentWorld.SetBounds(w.settings.CaptureBounds)
+			w.currentWorld.RecordHistory = w.settings.BlockHistory
+			w.currentWorld.MemoryBudget = w.settings.MemoryBudget
+			w.currentWorld.EntityFilter = w.settings.EntityFilter
 			if settings.StartPaused {
 				w.currentWorld.PauseCapture()
 			}
@@ -253,6 +323,11 @@ func NewWorldsHandler(settings WorldSettings) *proxy.Handler {
 
 			w.session.SendMessage(locale.Loc("use_setname", nil))
 			w.mapUI.Start(ctx)
+			if w.settings.CheckpointInterval > 0 {
+				w.checkpointOnce.Do(func() {
+					go w.checkpointLoop()
+				})
+			}
 			return false
 		},
 
@@ -260,21 +335,36 @@ func NewWorldsHandler(settings WorldSettings) *proxy.Handler {
 		OnSessionEnd: func() {
 			w.SaveAndReset(true, nil)
 			w.wg.Wait()
+			w.mapUI.Stop()
+			w.closeBaselines()
+		},
+		OnProxyEnd: func() {
+			cancel()
+			w.chunkPool.Close()
+			if w.webMap != nil {
+				w.webMap.Close()
+			}
 		},
-		OnProxyEnd: cancel,
 	}
 
 	return h
 }
 
 func (w *worldsHandler) preloadReplay() error {
-	if w.settings.PreloadReplay == "" {
-		return nil
+	for _, filename := range w.settings.PreloadReplays {
+		err := w.preloadReplayFile(filename)
+		if err != nil {
+			return fmt.Errorf("preload %s: %w", filename, err)
+		}
 	}
+	return nil
+}
+
+func (w *worldsHandler) preloadReplayFile(filename string) error {
 	log := w.log.WithField("func", "preloadReplay")
 	var conn *proxy.ReplayConnector
 	var err error
-	conn, err = proxy.CreateReplayConnector(context.Background(), w.settings.PreloadReplay, func(header packet.Header, payload []byte, src, dst net.Addr, timeReceived time.Time) {
+	conn, err = proxy.CreateReplayConnector(context.Background(), filename, func(header packet.Header, payload []byte, src, dst net.Addr, timeReceived time.Time) {
 		pk, ok := proxy.DecodePacket(header, payload, conn.ShieldID())
 		if !ok {
 			log.Error("unknown packet", header)
@@ -307,23 +397,25 @@ func (w *worldsHandler) preloadReplay() error {
 			break
 		}
 	}
+	w.chunkPool.Wait()
 	w.session.Server = nil
 
-	log.Info("finished preload")
+	log.Infof("finished preload of %s", filename)
 	w.serverState.blocks = nil
 	return nil
 }
 
-func (w *worldsHandler) setVoidGen(val bool) bool {
-	w.currentWorld.VoidGen = val
-	var s = locale.Loc("void_generator_false", nil)
-	if w.currentWorld.VoidGen {
-		s = locale.Loc("void_generator_true", nil)
+func (w *worldsHandler) setGenerator(generator worldstate.Generator) bool {
+	w.currentWorld.Generator = generator
+	isVoid := generator.Type == worldstate.GeneratorVoid
+	if isVoid {
+		w.session.SendMessage(locale.Loc("void_generator_true", nil))
+	} else {
+		w.session.SendMessage(locale.Loc("void_generator_false", nil) + " (" + generator.String() + ")")
 	}
-	w.session.SendMessage(s)
 
 	var voidGen = "false"
-	if w.currentWorld.VoidGen {
+	if isVoid {
 		voidGen = "true"
 	}
 
@@ -360,6 +452,9 @@ func (w *worldsHandler) setWorldName(val string) bool {
 }
 
 func (w *worldsHandler) SaveAndReset(end bool, dim world.Dimension) {
+	// finish decoding the chunks of this world first
+	w.chunkPool.Wait()
+
 	// replacing the current world state if it needs to be reset
 	w.worldStateLock.Lock()
 	if dim == nil {
@@ -407,6 +502,29 @@ func (w *worldsHandler) SaveAndReset(end bool, dim world.Dimension) {
 	}()
 }
 
+// addBehaviorPack writes the behavior pack with the blocks, items and entities of the server into the world
+func (w *worldsHandler) addBehaviorPack(fs utils.WriterFS) (*resource.Header, error) {
+	if !w.serverState.behaviorPack.HasContent() {
+		return nil, nil
+	}
+	name, err := filenamify.FilenamifyV2(w.serverState.Name)
+	if err != nil {
+		return nil, err
+	}
+	packFolder := path.Join("behavior_packs", name)
+
+	for _, p := range w.session.Server.ResourcePacks() {
+		w.serverState.behaviorPack.CheckAddLink(p)
+	}
+
+	err = w.serverState.behaviorPack.Save(fs, packFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	return &w.serverState.behaviorPack.Manifest.Header, nil
+}
+
 func (w *worldsHandler) saveWorldState(worldState *worldstate.World) error {
 	playerPos := w.session.Player.Position
 	spawnPos := cube.Pos{int(playerPos.X()), int(playerPos.Y()), int(playerPos.Z())}
@@ -425,61 +543,48 @@ func (w *worldsHandler) saveWorldState(worldState *worldstate.World) error {
 			State: "Saving",
 		},
 	})
-	err := worldState.Finish(w.playerData(), w.settings.ExcludedMobs, w.settings.Players, spawnPos, w.session.Server.GameData(), w.serverState.behaviorPack.HasContent())
+	err := worldState.Finish(w.playerData(), w.settings.Players, spawnPos, w.session.Server.GameData(), w.serverState.behaviorPack.HasContent())
 	if err != nil {
 		return err
 	}
 
-	err = worldState.FinalizePacks(func(fs utils.WriterFS) (*resource.Header, error) {
-		if w.serverState.behaviorPack.HasContent() {
-			name, err := filenamify.FilenamifyV2(w.serverState.Name)
-			if err != nil {
-				return nil, err
-			}
-			packFolder := path.Join("behavior_packs", name)
-
-			for _, p := range w.session.Server.ResourcePacks() {
-				w.serverState.behaviorPack.CheckAddLink(p)
-			}
-
-			err = w.serverState.behaviorPack.Save(fs, packFolder)
-			if err != nil {
-				return nil, err
-			}
-
-			return &w.serverState.behaviorPack.Manifest.Header, nil
-		}
-		return nil, nil
-	})
+	err = w.writeCoverage(worldState)
 	if err != nil {
-		return err
+		w.log.WithError(err).Warn("failed to write coverage report")
 	}
 
-	messages.Router.Handle(&messages.Message{
-		Source: "subcommand",
-		Target: "ui",
-		Data: messages.ProcessingWorldUpdate{
-			Name:  worldState.Name,
-			State: "Writing mcworld file",
-		},
-	})
-
-	f, err := os.Create(filename)
+	err = w.writeStats(worldState)
 	if err != nil {
-		return err
+		w.log.WithError(err).Warn("failed to write world stats")
 	}
-	defer f.Close()
-	zw := zip.NewWriter(f)
-	utils.ZipCompressPool(zw)
-	err = zw.AddFS(os.DirFS(worldState.Folder))
+
+	err = w.writeTexts(worldState)
 	if err != nil {
-		return err
+		w.log.WithError(err).Warn("failed to write text export")
 	}
-	err = zw.Close()
+
+	err = worldState.FinalizePacks(w.addBehaviorPack)
 	if err != nil {
 		return err
 	}
 
+	if w.settings.Output == OutputFolder {
+		filename = worldState.Folder
+	} else {
+		messages.Router.Handle(&messages.Message{
+			Source: "subcommand",
+			Target: "ui",
+			Data: messages.ProcessingWorldUpdate{
+				Name:  worldState.Name,
+				State: "Writing mcworld file",
+			},
+		})
+		err = writeMcworld(filename, worldState.Folder, w.settings.Output == OutputMcworld)
+		if err != nil {
+			return err
+		}
+	}
+
 	w.log.Info(locale.Loc("saved", locale.Strmap{"Name": filename}))
 
 	messages.Router.Handle(&messages.Message{
@@ -504,7 +609,11 @@ func (w *worldsHandler) reset(dim world.Dimension) (err error) {
 	if err != nil {
 		return err
 	}
-	w.currentWorld.VoidGen = w.settings.VoidGen
+	w.currentWorld.Generator = w.settings.Generator
+	w.currentWorld.SetBounds(w.settings.CaptureBounds)
+	w.currentWorld.RecordHistory = w.settings.BlockHistory
+	w.currentWorld.MemoryBudget = w.settings.MemoryBudget
+	w.currentWorld.EntityFilter = w.settings.EntityFilter
 	w.currentWorld.SetDimension(dim)
 
 	w.openWorldState(false)
@@ -530,6 +639,7 @@ func (w *worldsHandler) openWorldState(deferred bool) {
 	w.currentWorld.ResourcePacks = w.session.Server.ResourcePacks()
 	w.currentWorld.UseHashedRids = w.serverState.useHashedRids
 	w.currentWorld.Open(name, folder, deferred)
+	w.loadBaselines()
 }
 
 func (w *worldsHandler) renameWorldState(name string) error {
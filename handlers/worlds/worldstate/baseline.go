@@ -0,0 +1,56 @@
+package worldstate
+
+import (
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+)
+
+// LoadBaseline copies the chunks of this dimension from an existing world folder as the starting state,
+// chunks that were already captured are kept. onChunk is called for every chunk that got loaded.
+func (w *World) LoadBaseline(folder string, onChunk func(world.ChunkPos, *chunk.Chunk)) (int, error) {
+	db, err := mcdb.Config{
+		Log:      w.log,
+		Blocks:   w.BlockRegistry,
+		Biomes:   w.BiomeRegistry,
+		ReadOnly: true,
+	}.Open(folder)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	var count int
+	// the range keeps the iterator from decoding the columns of other dimensions
+	it := db.NewColumnIterator(&mcdb.IteratorRange{Dimension: w.dimension})
+	defer it.Release()
+	for it.Next() {
+		pos := it.Position()
+		if w.StoredChunks[pos] || !w.Bounds().ContainsChunk(pos) {
+			continue
+		}
+		col := it.Column()
+		if col == nil {
+			continue
+		}
+
+		// baseline chunks always go into the saved world, even when capturing is paused
+		w.memState.StoreChunk(pos, col)
+		w.StoredChunks[pos] = true
+		w.Coverage.ChunkReceived(pos, 0)
+		for bp, be := range col.BlockEntities {
+			w.Texts.Update(w.dimension, bp, blockEntityNBT(be))
+		}
+		if onChunk != nil {
+			onChunk(pos, col.Chunk)
+		}
+		count++
+	}
+	if count > 0 {
+		w.startFlushing()
+	}
+	return count, it.Error()
+}
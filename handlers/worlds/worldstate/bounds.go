@@ -0,0 +1,104 @@
+package worldstate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+// CaptureBounds limits what area of the world is captured,
+// either a box in block coordinates or a radius around a center when Radius is set
+type CaptureBounds struct {
+	Min, Max [2]int32
+	Center   [2]int32
+	Radius   int32
+}
+
+func parseInts(s string, n int) ([]int32, error) {
+	sp := strings.Split(s, ",")
+	if len(sp) != n {
+		return nil, fmt.Errorf("invalid %q, expected %d comma separated numbers", s, n)
+	}
+	out := make([]int32, n)
+	for i, v := range sp {
+		x, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		out[i] = int32(x)
+	}
+	return out, nil
+}
+
+// ParseCaptureBounds parses a box formatted as x1,z1,x2,z2 or a center x,z with a radius in blocks,
+// returns nil if neither is set
+func ParseCaptureBounds(box string, center string, radius int) (*CaptureBounds, error) {
+	if box != "" {
+		v, err := parseInts(box, 4)
+		if err != nil {
+			return nil, err
+		}
+		return NewBoxBounds(v[0], v[1], v[2], v[3]), nil
+	}
+	if radius > 0 {
+		var c [2]int32
+		if center != "" {
+			v, err := parseInts(center, 2)
+			if err != nil {
+				return nil, err
+			}
+			c = [2]int32{v[0], v[1]}
+		}
+		return &CaptureBounds{Center: c, Radius: int32(radius)}, nil
+	}
+	return nil, nil
+}
+
+// NewBoxBounds creates bounds of the box spanned by two corners
+func NewBoxBounds(x1, z1, x2, z2 int32) *CaptureBounds {
+	return &CaptureBounds{
+		Min: [2]int32{min(x1, x2), min(z1, z2)},
+		Max: [2]int32{max(x1, x2), max(z1, z2)},
+	}
+}
+
+// ContainsChunk returns true if any part of the chunk is inside of the bounds
+func (b *CaptureBounds) ContainsChunk(pos world.ChunkPos) bool {
+	if b == nil {
+		return true
+	}
+	minX, minZ := pos[0]<<4, pos[1]<<4
+	maxX, maxZ := minX+15, minZ+15
+	if b.Radius > 0 {
+		// closest point of the chunk to the center
+		x := min(max(b.Center[0], minX), maxX) - b.Center[0]
+		z := min(max(b.Center[1], minZ), maxZ) - b.Center[1]
+		return int64(x)*int64(x)+int64(z)*int64(z) <= int64(b.Radius)*int64(b.Radius)
+	}
+	return maxX >= b.Min[0] && minX <= b.Max[0] && maxZ >= b.Min[1] && minZ <= b.Max[1]
+}
+
+// ContainsPos returns true if the block position is inside of the bounds
+func (b *CaptureBounds) ContainsPos(x, z float32) bool {
+	if b == nil {
+		return true
+	}
+	if b.Radius > 0 {
+		dx := float64(x) - float64(b.Center[0])
+		dz := float64(z) - float64(b.Center[1])
+		return dx*dx+dz*dz <= float64(b.Radius)*float64(b.Radius)
+	}
+	return x >= float32(b.Min[0]) && x < float32(b.Max[0]+1) && z >= float32(b.Min[1]) && z < float32(b.Max[1]+1)
+}
+
+func (b *CaptureBounds) String() string {
+	if b == nil {
+		return "everything"
+	}
+	if b.Radius > 0 {
+		return fmt.Sprintf("radius %d around %d,%d", b.Radius, b.Center[0], b.Center[1])
+	}
+	return fmt.Sprintf("%d,%d to %d,%d", b.Min[0], b.Min[1], b.Max[0], b.Max[1])
+}
@@ -0,0 +1,68 @@
+package worldstate
+
+import (
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+func TestParseCaptureBounds(t *testing.T) {
+	tests := []struct {
+		box, center string
+		radius      int
+		want        *CaptureBounds
+		err         bool
+	}{
+		{want: nil},
+		{box: "10, -5,-20,30", want: &CaptureBounds{Min: [2]int32{-20, -5}, Max: [2]int32{10, 30}}},
+		{box: "1,2,3", err: true},
+		{box: "1,2,x,4", err: true},
+		{center: "100,-50", radius: 64, want: &CaptureBounds{Center: [2]int32{100, -50}, Radius: 64}},
+		{radius: 32, want: &CaptureBounds{Radius: 32}},
+		{center: "100", radius: 32, err: true},
+		// a center without a radius captures everything
+		{center: "100,-50", want: nil},
+	}
+	for _, tt := range tests {
+		got, err := ParseCaptureBounds(tt.box, tt.center, tt.radius)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseCaptureBounds(%q, %q, %d) error = %v", tt.box, tt.center, tt.radius, err)
+			continue
+		}
+		if tt.err {
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("ParseCaptureBounds(%q, %q, %d) = %v, want %v", tt.box, tt.center, tt.radius, got, tt.want)
+		}
+	}
+}
+
+func TestContainsChunk(t *testing.T) {
+	box := NewBoxBounds(0, 0, 31, 16)
+	radius := &CaptureBounds{Center: [2]int32{8, 8}, Radius: 20}
+	tests := []struct {
+		bounds *CaptureBounds
+		pos    world.ChunkPos
+		want   bool
+	}{
+		{nil, world.ChunkPos{1000, -1000}, true},
+		{box, world.ChunkPos{0, 0}, true},
+		{box, world.ChunkPos{1, 1}, true},
+		{box, world.ChunkPos{2, 0}, false},
+		{box, world.ChunkPos{0, 2}, false},
+		{box, world.ChunkPos{-1, 0}, false},
+		{radius, world.ChunkPos{0, 0}, true},
+		{radius, world.ChunkPos{-1, 0}, true},
+		// the corner of 1,1 is 8 blocks away on both axes, less than 20
+		{radius, world.ChunkPos{1, 1}, true},
+		// the closest corner of 2,2 is 24 blocks away on both axes
+		{radius, world.ChunkPos{2, 2}, false},
+		{radius, world.ChunkPos{-2, 0}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.bounds.ContainsChunk(tt.pos); got != tt.want {
+			t.Errorf("%s ContainsChunk(%v) = %v, want %v", tt.bounds, tt.pos, got, tt.want)
+		}
+	}
+}
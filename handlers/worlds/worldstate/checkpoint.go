@@ -0,0 +1,167 @@
+package worldstate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/bedrock-tool/bedrocktool/utils"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"github.com/df-mc/dragonfly/server/world/mcdb/leveldat"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/resource"
+	"github.com/sirupsen/logrus"
+)
+
+// checkpointMarker is in the world folder while a capture is in progress,
+// if it is still there on the next start the capture crashed
+const checkpointMarker = "bedrocktool_checkpoint.json"
+
+type checkpointInfo struct {
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+}
+
+// hasCheckpoint returns true if folder has a capture in it that didnt finish
+func hasCheckpoint(folder string) bool {
+	_, err := os.Stat(path.Join(folder, checkpointMarker))
+	return err == nil
+}
+
+// freeFolder returns folder, or folder with a number after it if folder has a crashed capture in it that isnt recovered yet
+func freeFolder(folder string) string {
+	if !hasCheckpoint(folder) {
+		return folder
+	}
+	for i := 2; ; i++ {
+		f := fmt.Sprintf("%s-%d", folder, i)
+		if !hasCheckpoint(f) {
+			logrus.Warnf("%s has a capture that wasnt recovered, using %s", folder, f)
+			return f
+		}
+	}
+}
+
+// Checkpoint writes everything captured so far to the world folder, so it can be recovered after a crash.
+// chunks, entities and maps go to the world db under the lock, the files are written after it is released
+func (w *World) Checkpoint(playerData map[string]any, spawn cube.Pos, gd minecraft.GameData, experimental bool) error {
+	w.checkpointLock.Lock()
+	defer w.checkpointLock.Unlock()
+
+	w.stateLock.Lock()
+	select {
+	case <-w.finish:
+		w.stateLock.Unlock()
+		return nil
+	default:
+	}
+	w.applyBlockUpdates()
+	err := w.storeMemToProvider()
+	if err != nil || w.provider == nil {
+		// nothing stored yet
+		w.stateLock.Unlock()
+		return err
+	}
+
+	w.entityLock.Lock()
+	w.storeEntities()
+	err = w.storeMaps()
+	w.entityLock.Unlock()
+	if err != nil {
+		w.stateLock.Unlock()
+		return err
+	}
+
+	w.writeMetadata(spawn, gd, experimental)
+	var ldat leveldat.LevelDat
+	err = ldat.Marshal(*w.provider.LevelDat())
+	name, folder := w.Name, w.Folder
+	w.stateLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Finish and Rename wait for checkpointLock, so the provider and folder stay the same until here
+	err = w.provider.SaveLocalPlayerData(playerData)
+	if err != nil {
+		return err
+	}
+	err = writeLevelDat(folder, &ldat)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(checkpointInfo{Name: name, Time: time.Now()})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(folder, checkpointMarker), data, 0o666)
+}
+
+// CheckpointPacks writes the behavior pack and the pack lists for a checkpoint,
+// this is only done once the resource packs are copied and not again after that
+func (w *World) CheckpointPacks(addBehaviorPack func(fs utils.WriterFS) (*resource.Header, error)) error {
+	w.checkpointLock.Lock()
+	defer w.checkpointLock.Unlock()
+	if w.packsCheckpointed || w.resourcePacksDone == nil {
+		return nil
+	}
+	select {
+	case <-w.resourcePacksDone:
+	default:
+		return nil
+	}
+	err := w.writePacks(addBehaviorPack, w.resourcePacksErr == nil)
+	if err != nil {
+		return err
+	}
+	w.packsCheckpointed = true
+	return nil
+}
+
+// writeLevelDat writes level.dat next to the old one first so a crash while writing doesnt break it
+func writeLevelDat(folder string, ldat *leveldat.LevelDat) error {
+	filename := path.Join(folder, "level.dat")
+	err := ldat.WriteFile(filename + ".tmp")
+	if err != nil {
+		return err
+	}
+	return os.Rename(filename+".tmp", filename)
+}
+
+// FindCrashedCaptures returns the world folders inside of worldsFolder that still have a checkpoint marker
+func FindCrashedCaptures(worldsFolder string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(worldsFolder, "*", "*", checkpointMarker))
+	if err != nil {
+		return nil, err
+	}
+	var folders []string
+	for _, m := range matches {
+		folders = append(folders, filepath.Dir(m))
+	}
+	return folders, nil
+}
+
+// RecoverFolder makes the folder of a crashed capture a normal world folder
+func RecoverFolder(folder string) error {
+	// opening and closing replays the leveldb journal
+	db, err := mcdb.Config{Log: logrus.StandardLogger()}.Open(folder)
+	if err != nil {
+		return err
+	}
+	err = db.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(folder, checkpointMarker))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
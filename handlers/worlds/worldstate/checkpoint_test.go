@@ -0,0 +1,124 @@
+package worldstate
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bedrock-tool/bedrocktool/utils"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/sandertv/gophertunnel/minecraft"
+)
+
+func TestCheckpointAndRecover(t *testing.T) {
+	worlds := t.TempDir()
+	w, err := New(nil, func(world.ChunkPos, *chunk.Chunk, bool) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.BlockRegistry = world.DefaultBlockRegistry
+	w.BiomeRegistry = world.DefaultBiomes
+	w.SetDimension(world.Overworld)
+	w.Name = "test"
+	w.Folder = filepath.Join(worlds, "server", "test")
+	w.log = w.log.WithField("test", t.Name())
+
+	// nothing stored yet writes nothing
+	if err := w.Checkpoint(nil, cube.Pos{}, minecraft.GameData{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if hasCheckpoint(w.Folder) {
+		t.Fatal("checkpoint written without chunks")
+	}
+
+	stone := testRID(t, w, "minecraft:stone")
+	w.memState.StoreChunk(world.ChunkPos{0, 0}, newTestColumn(w, map[[3]int]uint32{{0, 64, 0}: stone}))
+	playerData := map[string]any{"identifier": "minecraft:player"}
+	if err := w.Checkpoint(playerData, cube.Pos{0, 65, 0}, minecraft.GameData{}, false); err != nil {
+		t.Fatal(err)
+	}
+	<-w.resourcePacksDone
+	if !hasCheckpoint(w.Folder) {
+		t.Fatal("no checkpoint marker")
+	}
+	if _, err := os.Stat(filepath.Join(w.Folder, "level.dat")); err != nil {
+		t.Fatal(err)
+	}
+	// the chunk is in the db and not in memory anymore
+	if len(w.memState.chunks) != 0 {
+		t.Errorf("%d chunks left in memory", len(w.memState.chunks))
+	}
+
+	// the process crashes here, the provider is closed so the folder can be opened again
+	if err := w.provider.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	folders, err := FindCrashedCaptures(worlds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(folders) != 1 || folders[0] != w.Folder {
+		t.Fatalf("FindCrashedCaptures = %v, want [%s]", folders, w.Folder)
+	}
+	if got, want := freeFolder(w.Folder), w.Folder+"-2"; got != want {
+		t.Errorf("freeFolder = %s, want %s", got, want)
+	}
+
+	if err := RecoverFolder(w.Folder); err != nil {
+		t.Fatal(err)
+	}
+	if hasCheckpoint(w.Folder) {
+		t.Error("marker left after recovery")
+	}
+	if got := freeFolder(w.Folder); got != w.Folder {
+		t.Errorf("freeFolder after recovery = %s", got)
+	}
+
+	filename := w.Folder + ".mcworld"
+	if err := utils.WriteMcworld(filename, w.Folder); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	files := make(map[string]bool)
+	for _, f := range zr.File {
+		files[f.Name] = true
+	}
+	for _, name := range []string{"level.dat", "db/CURRENT"} {
+		if !files[name] {
+			t.Errorf("%s missing from the mcworld", name)
+		}
+	}
+	if files[checkpointMarker] {
+		t.Error("checkpoint marker in the mcworld")
+	}
+	if _, err := os.Stat(filename + ".tmp"); err == nil {
+		t.Error("temporary zip left behind")
+	}
+}
+
+func TestFreeFolder(t *testing.T) {
+	dir := t.TempDir()
+	folder := filepath.Join(dir, "world")
+	if got := freeFolder(folder); got != folder {
+		t.Errorf("freeFolder of a new folder = %s", got)
+	}
+	for _, f := range []string{folder, folder + "-2"} {
+		if err := os.MkdirAll(f, 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(f, checkpointMarker), []byte("{}"), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := freeFolder(folder), folder+"-3"; got != want {
+		t.Errorf("freeFolder = %s, want %s", got, want)
+	}
+}
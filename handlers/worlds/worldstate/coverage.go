@@ -0,0 +1,242 @@
+package worldstate
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+type CoverageStatus int
+
+const (
+	CoverageMissing CoverageStatus = iota
+	CoverageComplete
+	CoverageMissingSubChunks
+	CoverageErrors
+	CoveragePartial
+)
+
+func (s CoverageStatus) String() string {
+	switch s {
+	case CoverageComplete:
+		return "complete"
+	case CoverageMissingSubChunks:
+		return "unanswered subchunks"
+	case CoverageErrors:
+		return "subchunk errors"
+	case CoveragePartial:
+		return "partly decoded"
+	default:
+		return "missing"
+	}
+}
+
+var coverageColors = map[CoverageStatus]color.RGBA{
+	CoverageMissing:          {0, 0, 0, 0},
+	CoverageComplete:         {0x3c, 0xb0, 0x43, 0xff},
+	CoverageMissingSubChunks: {0xe8, 0xc5, 0x2a, 0xff},
+	CoverageErrors:           {0xd6, 0x3a, 0x2f, 0xff},
+	CoveragePartial:          {0xe0, 0x7b, 0x24, 0xff},
+}
+
+type chunkCoverage struct {
+	requested int // subchunks requested, 0 when the chunk came complete
+	received  int
+	failed    int
+	partial   bool
+}
+
+func (c *chunkCoverage) status() CoverageStatus {
+	switch {
+	case c.partial:
+		return CoveragePartial
+	case c.failed > 0:
+		return CoverageErrors
+	case c.received < c.requested:
+		return CoverageMissingSubChunks
+	}
+	return CoverageComplete
+}
+
+// Coverage tracks if every part of a chunk arrived
+type Coverage struct {
+	lock   sync.Mutex
+	chunks map[world.ChunkPos]*chunkCoverage
+}
+
+// IncompleteChunk is a chunk that is missing or didnt arrive fully
+type IncompleteChunk struct {
+	Pos    world.ChunkPos
+	Status CoverageStatus
+}
+
+func newCoverage() *Coverage {
+	return &Coverage{chunks: make(map[world.ChunkPos]*chunkCoverage)}
+}
+
+func (c *Coverage) chunk(pos world.ChunkPos) *chunkCoverage {
+	cc, ok := c.chunks[pos]
+	if !ok {
+		cc = &chunkCoverage{}
+		c.chunks[pos] = cc
+	}
+	return cc
+}
+
+// ChunkReceived marks a chunk as received, requestedSubChunks is how many subchunks still have to arrive for it
+func (c *Coverage) ChunkReceived(pos world.ChunkPos, requestedSubChunks int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.chunks[pos] = &chunkCoverage{requested: requestedSubChunks}
+}
+
+func (c *Coverage) SubChunkResult(pos world.ChunkPos, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cc := c.chunk(pos)
+	if ok {
+		cc.received++
+	} else {
+		cc.failed++
+	}
+}
+
+func (c *Coverage) DecodeFailed(pos world.ChunkPos) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.chunk(pos).partial = true
+}
+
+func (c *Coverage) Status(pos world.ChunkPos) CoverageStatus {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cc, ok := c.chunks[pos]
+	if !ok {
+		return CoverageMissing
+	}
+	return cc.status()
+}
+
+// Incomplete returns the chunks within radius of center that are missing or incomplete, closest first
+func (c *Coverage) Incomplete(center world.ChunkPos, radius int32) (out []IncompleteChunk) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for x := center[0] - radius; x <= center[0]+radius; x++ {
+		for z := center[1] - radius; z <= center[1]+radius; z++ {
+			dx, dz := x-center[0], z-center[1]
+			if dx*dx+dz*dz > radius*radius {
+				continue
+			}
+			pos := world.ChunkPos{x, z}
+			status := CoverageMissing
+			if cc, ok := c.chunks[pos]; ok {
+				status = cc.status()
+			}
+			if status != CoverageComplete {
+				out = append(out, IncompleteChunk{Pos: pos, Status: status})
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return chunkDist(out[i].Pos, center) < chunkDist(out[j].Pos, center)
+	})
+	return out
+}
+
+func chunkDist(a, b world.ChunkPos) int32 {
+	dx, dz := a[0]-b[0], a[1]-b[1]
+	return dx*dx + dz*dz
+}
+
+func (c *Coverage) bounds() (minPos, maxPos world.ChunkPos) {
+	minPos = world.ChunkPos{math.MaxInt32, math.MaxInt32}
+	maxPos = world.ChunkPos{math.MinInt32, math.MinInt32}
+	for pos := range c.chunks {
+		minPos[0] = min(minPos[0], pos[0])
+		minPos[1] = min(minPos[1], pos[1])
+		maxPos[0] = max(maxPos[0], pos[0])
+		maxPos[1] = max(maxPos[1], pos[1])
+	}
+	return
+}
+
+// WriteReport writes a summary and every incomplete chunk
+func (c *Coverage) WriteReport(w io.Writer) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	counts := make(map[CoverageStatus]int)
+	var incomplete []IncompleteChunk
+	for pos, cc := range c.chunks {
+		status := cc.status()
+		counts[status]++
+		if status != CoverageComplete {
+			incomplete = append(incomplete, IncompleteChunk{Pos: pos, Status: status})
+		}
+	}
+	sort.Slice(incomplete, func(i, j int) bool {
+		a, b := incomplete[i].Pos, incomplete[j].Pos
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		return a[1] < b[1]
+	})
+
+	fmt.Fprintf(w, "chunks: %d\n", len(c.chunks))
+	for _, s := range []CoverageStatus{CoverageComplete, CoverageMissingSubChunks, CoverageErrors, CoveragePartial} {
+		fmt.Fprintf(w, "%s: %d\n", s, counts[s])
+	}
+	if len(c.chunks) > 0 {
+		minPos, maxPos := c.bounds()
+		fmt.Fprintf(w, "area: chunks %d,%d to %d,%d (blocks %d,%d to %d,%d)\n",
+			minPos[0], minPos[1], maxPos[0], maxPos[1],
+			minPos[0]*16, minPos[1]*16, maxPos[0]*16+15, maxPos[1]*16+15,
+		)
+	}
+	if len(incomplete) > 0 {
+		fmt.Fprintf(w, "\nincomplete chunks:\n")
+		for _, ic := range incomplete {
+			cc := c.chunks[ic.Pos]
+			_, err := fmt.Fprintf(w, "%d,%d (blocks %d,%d): %s, %d/%d subchunks, %d errors\n",
+				ic.Pos[0], ic.Pos[1], ic.Pos[0]*16, ic.Pos[1]*16,
+				ic.Status, cc.received, cc.requested, cc.failed,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Image draws every received chunk as a 4x4 square colored by its status,
+// chunks that never arrived stay transparent
+func (c *Coverage) Image() *image.RGBA {
+	const scale = 4
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.chunks) == 0 {
+		return image.NewRGBA(image.Rect(0, 0, 0, 0))
+	}
+	minPos, maxPos := c.bounds()
+	img := image.NewRGBA(image.Rect(0, 0,
+		int(maxPos[0]-minPos[0]+1)*scale,
+		int(maxPos[1]-minPos[1]+1)*scale,
+	))
+	for pos, cc := range c.chunks {
+		col := coverageColors[cc.status()]
+		px, pz := int(pos[0]-minPos[0])*scale, int(pos[1]-minPos[1])*scale
+		for x := 0; x < scale; x++ {
+			for z := 0; z < scale; z++ {
+				img.SetRGBA(px+x, pz+z, col)
+			}
+		}
+	}
+	return img
+}
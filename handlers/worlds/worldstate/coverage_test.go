@@ -0,0 +1,76 @@
+package worldstate
+
+import (
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+func TestCoverageStatus(t *testing.T) {
+	pos := world.ChunkPos{3, -2}
+	tests := []struct {
+		name   string
+		events func(c *Coverage)
+		want   CoverageStatus
+	}{
+		{"never received", func(c *Coverage) {}, CoverageMissing},
+		{"full chunk", func(c *Coverage) { c.ChunkReceived(pos, 0) }, CoverageComplete},
+		{"waiting for subchunks", func(c *Coverage) {
+			c.ChunkReceived(pos, 3)
+			c.SubChunkResult(pos, true)
+		}, CoverageMissingSubChunks},
+		{"all subchunks", func(c *Coverage) {
+			c.ChunkReceived(pos, 2)
+			c.SubChunkResult(pos, true)
+			c.SubChunkResult(pos, true)
+		}, CoverageComplete},
+		{"subchunk error", func(c *Coverage) {
+			c.ChunkReceived(pos, 2)
+			c.SubChunkResult(pos, true)
+			c.SubChunkResult(pos, false)
+		}, CoverageErrors},
+		{"decode failed", func(c *Coverage) {
+			c.ChunkReceived(pos, 2)
+			c.SubChunkResult(pos, false)
+			c.DecodeFailed(pos)
+		}, CoveragePartial},
+		{"received again", func(c *Coverage) {
+			c.ChunkReceived(pos, 2)
+			c.DecodeFailed(pos)
+			c.ChunkReceived(pos, 0)
+		}, CoverageComplete},
+	}
+	for _, tt := range tests {
+		c := newCoverage()
+		tt.events(c)
+		if got := c.Status(pos); got != tt.want {
+			t.Errorf("%s: status %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCoverageIncomplete(t *testing.T) {
+	c := newCoverage()
+	center := world.ChunkPos{0, 0}
+	for x := int32(-2); x <= 2; x++ {
+		for z := int32(-2); z <= 2; z++ {
+			c.ChunkReceived(world.ChunkPos{x, z}, 0)
+		}
+	}
+	c.ChunkReceived(world.ChunkPos{1, 0}, 4)
+	delete(c.chunks, world.ChunkPos{0, 2})
+
+	got := c.Incomplete(center, 2)
+	want := []IncompleteChunk{
+		{Pos: world.ChunkPos{1, 0}, Status: CoverageMissingSubChunks},
+		{Pos: world.ChunkPos{0, 2}, Status: CoverageMissing},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package worldstate
+
+import (
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// dimensionIDs maps the names in DimensionData to the ids of the builtin dimensions
+var dimensionIDs = map[string]int{
+	"minecraft:overworld": 0,
+	"minecraft:nether":    1,
+	"minecraft:the_end":   2,
+}
+
+// DimensionIDByName returns the id of the dimension a DimensionDefinition is for
+func DimensionIDByName(name string) (int, bool) {
+	id, ok := dimensionIDs[name]
+	return id, ok
+}
+
+// DimensionRange returns the block range of a DimensionDefinition, the range is sent as max, min with max exclusive
+func DimensionRange(d protocol.DimensionDefinition) cube.Range {
+	return cube.Range{int(d.Range[1]), int(d.Range[0]) - 1}
+}
+
+// customRange is true when the server sent a different height for this dimension than the builtin one.
+// mcdb reads columns back with the height of the dimension it is given, so these chunks are read back from the spill store
+func (w *World) customRange() bool {
+	return w.dimRange != w.dimension.Range()
+}
+
+// customDimension is a builtin dimension with the height the server sent.
+// mcdb cant find its id so it is only used for the spill store, which only holds one dimension
+type customDimension struct {
+	world.Dimension
+	r cube.Range
+}
+
+func (d customDimension) Range() cube.Range {
+	return d.r
+}
+
+// storageDimension is the dimension chunks are written to and read back from the spill store with
+func (w *World) storageDimension() world.Dimension {
+	if w.customRange() {
+		return customDimension{Dimension: w.dimension, r: w.dimRange}
+	}
+	return w.dimension
+}
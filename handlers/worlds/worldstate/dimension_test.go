@@ -0,0 +1,77 @@
+package worldstate
+
+import (
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+func TestDimensionRange(t *testing.T) {
+	tests := []struct {
+		name string
+		def  protocol.DimensionDefinition
+		want cube.Range
+	}{
+		{"overworld", protocol.DimensionDefinition{Name: "minecraft:overworld", Range: [2]int32{320, -64}}, world.Overworld.Range()},
+		{"nether", protocol.DimensionDefinition{Name: "minecraft:nether", Range: [2]int32{128, 0}}, world.Nether.Range()},
+		{"old overworld", protocol.DimensionDefinition{Name: "minecraft:overworld", Range: [2]int32{256, 0}}, cube.Range{0, 255}},
+		{"taller", protocol.DimensionDefinition{Name: "minecraft:overworld", Range: [2]int32{512, -128}}, cube.Range{-128, 511}},
+	}
+	for _, tt := range tests {
+		if got := DimensionRange(tt.def); got != tt.want {
+			t.Errorf("%s: DimensionRange(%v) = %v, want %v", tt.name, tt.def.Range, got, tt.want)
+		}
+	}
+}
+
+func TestCustomRange(t *testing.T) {
+	w := newTestWorld(t)
+	w.dimensionDefinitions = map[int]protocol.DimensionDefinition{
+		0: {Name: "minecraft:overworld", Range: [2]int32{320, -64}},
+		1: {Name: "minecraft:nether", Range: [2]int32{256, 0}},
+	}
+
+	tests := []struct {
+		name      string
+		dim       world.Dimension
+		wantRange cube.Range
+		custom    bool
+	}{
+		{"same as builtin", world.Overworld, world.Overworld.Range(), false},
+		{"taller nether", world.Nether, cube.Range{0, 255}, true},
+		{"no definition", world.End, world.End.Range(), false},
+	}
+	for _, tt := range tests {
+		w.SetDimension(tt.dim)
+		if got := w.Range(); got != tt.wantRange {
+			t.Errorf("%s: Range() = %v, want %v", tt.name, got, tt.wantRange)
+		}
+		if got := w.customRange(); got != tt.custom {
+			t.Errorf("%s: customRange() = %v, want %v", tt.name, got, tt.custom)
+		}
+		storage := w.storageDimension()
+		if storage.Range() != tt.wantRange {
+			t.Errorf("%s: storage dimension range %v, want %v", tt.name, storage.Range(), tt.wantRange)
+		}
+		if _, ok := storage.(customDimension); ok != tt.custom {
+			t.Errorf("%s: storage dimension is %T", tt.name, storage)
+		}
+		// the custom dimension is still the builtin one for everything but the height
+		if storage.WaterEvaporates() != tt.dim.WaterEvaporates() {
+			t.Errorf("%s: storage dimension isnt based on %v", tt.name, tt.dim)
+		}
+	}
+}
+
+func TestDimensionIDByName(t *testing.T) {
+	for name, want := range map[string]int{"minecraft:overworld": 0, "minecraft:nether": 1, "minecraft:the_end": 2} {
+		if id, ok := DimensionIDByName(name); !ok || id != want {
+			t.Errorf("DimensionIDByName(%q) = %d, %v", name, id, ok)
+		}
+	}
+	if _, ok := DimensionIDByName("custom:dimension"); ok {
+		t.Error("unknown dimension has an id")
+	}
+}
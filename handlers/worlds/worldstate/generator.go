@@ -0,0 +1,118 @@
+package worldstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type GeneratorType int
+
+const (
+	GeneratorVoid GeneratorType = iota
+	GeneratorFlat
+	GeneratorDefault
+)
+
+// FlatLayer is one layer of a superflat world, from the bottom up
+type FlatLayer struct {
+	Block string `json:"block_name"`
+	Count int    `json:"count"`
+}
+
+// Generator decides what the game generates for chunks that werent captured
+type Generator struct {
+	Type   GeneratorType
+	Layers []FlatLayer
+}
+
+var defaultFlatLayers = []FlatLayer{
+	{Block: "minecraft:bedrock", Count: 1},
+	{Block: "minecraft:dirt", Count: 2},
+	{Block: "minecraft:grass_block", Count: 1},
+}
+
+// ParseFlatLayers parses layers like minecraft:bedrock,2*minecraft:dirt,minecraft:grass_block from the bottom up
+func ParseFlatLayers(s string) ([]FlatLayer, error) {
+	var layers []FlatLayer
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		layer := FlatLayer{Block: part, Count: 1}
+		if count, block, ok := strings.Cut(part, "*"); ok {
+			n, err := strconv.Atoi(count)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid layer count in %q", part)
+			}
+			layer = FlatLayer{Block: block, Count: n}
+		}
+		if !strings.Contains(layer.Block, ":") {
+			layer.Block = "minecraft:" + layer.Block
+		}
+		layers = append(layers, layer)
+	}
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("no layers in %q", s)
+	}
+	return layers, nil
+}
+
+// ParseGenerator parses a generator name, void, flat or default, layers are only used for flat
+func ParseGenerator(name, layers string) (Generator, error) {
+	switch strings.ToLower(name) {
+	case "void", "":
+		return Generator{Type: GeneratorVoid}, nil
+	case "flat":
+		g := Generator{Type: GeneratorFlat, Layers: defaultFlatLayers}
+		if layers != "" {
+			var err error
+			g.Layers, err = ParseFlatLayers(layers)
+			if err != nil {
+				return Generator{}, err
+			}
+		}
+		return g, nil
+	case "default":
+		return Generator{Type: GeneratorDefault}, nil
+	}
+	return Generator{}, fmt.Errorf("unknown generator %q, use void, flat or default", name)
+}
+
+func (g Generator) String() string {
+	switch g.Type {
+	case GeneratorFlat:
+		var parts []string
+		for _, l := range g.Layers {
+			if l.Count == 1 {
+				parts = append(parts, l.Block)
+			} else {
+				parts = append(parts, fmt.Sprintf("%d*%s", l.Count, l.Block))
+			}
+		}
+		return "flat " + strings.Join(parts, ",")
+	case GeneratorDefault:
+		return "default"
+	}
+	return "void"
+}
+
+// levelDat returns the generator id and flat world layers to put into level.dat
+func (g Generator) levelDat() (generator int32, flatLayers string) {
+	switch g.Type {
+	case GeneratorFlat:
+		data, _ := json.Marshal(map[string]any{
+			"biome_id":          1,
+			"block_layers":      g.Layers,
+			"encoding_version":  6,
+			"structure_options": nil,
+			"world_version":     "version.post_1_18",
+		})
+		return 2, string(data)
+	case GeneratorDefault:
+		return 1, ""
+	}
+	return 2, `{"biome_id":1,"block_layers":[{"block_data":0,"block_id":0,"count":1},{"block_data":0,"block_id":0,"count":2},{"block_data":0,"block_id":0,"count":1}],"encoding_version":3,"structure_options":null}`
+}
@@ -0,0 +1,123 @@
+package worldstate
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bedrock-tool/bedrocktool/utils/blockhistory"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// newCaptureWorld is a world in folder set up like the handler does it, without a provider yet
+func newCaptureWorld(t *testing.T, folder string) *World {
+	w, err := New(nil, func(world.ChunkPos, *chunk.Chunk, bool) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.BlockRegistry = world.DefaultBlockRegistry
+	w.BiomeRegistry = world.DefaultBiomes
+	w.SetDimension(world.Overworld)
+	w.Name = filepath.Base(folder)
+	w.Folder = folder
+	w.log = w.log.WithField("test", t.Name())
+	// stops the flushing started by the first chunk
+	t.Cleanup(func() { close(w.finish) })
+	return w
+}
+
+func applyAndFlushHistory(t *testing.T, w *World) {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+	w.applyBlockUpdates()
+	if err := w.flushHistory(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlockHistoryRecorder(t *testing.T) {
+	folder := filepath.Join(t.TempDir(), "test")
+	w := newCaptureWorld(t, folder)
+	w.RecordHistory = true
+	air := testRID(t, w, "minecraft:air")
+	stone := testRID(t, w, "minecraft:stone")
+	gold := testRID(t, w, "minecraft:gold_block")
+	w.memState.StoreChunk(world.ChunkPos{0, 0}, newTestColumn(w, map[[3]int]uint32{{1, 64, 1}: stone}))
+	w.memState.StoreChunk(world.ChunkPos{-1, 2}, newTestColumn(w, nil))
+
+	start := time.UnixMilli(1_700_000_000_000)
+	w.QueueBlockUpdate(protocol.BlockPos{1, 64, 1}, air, 0, start)
+	w.QueueBlockUpdate(protocol.BlockPos{1, 64, 1}, gold, 0, start.Add(time.Second))
+	// setting the block it already is isnt a change
+	w.QueueBlockUpdate(protocol.BlockPos{1, 64, 1}, gold, 0, start.Add(2*time.Second))
+	w.QueueBlockUpdate(protocol.BlockPos{-3, 70, 40}, stone, 0, start.Add(3*time.Second))
+	// chunks that werent received are skipped
+	w.QueueBlockUpdate(protocol.BlockPos{100, 64, 100}, stone, 0, start)
+	applyAndFlushHistory(t, w)
+
+	dir := blockhistory.Dir(folder)
+	read := func() []string {
+		entries, err := blockhistory.ReadChunks(dir, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.From.Name+">"+e.To.Name)
+		}
+		return got
+	}
+	want := []string{
+		"minecraft:stone>minecraft:air",
+		"minecraft:air>minecraft:gold_block",
+		"minecraft:air>minecraft:stone",
+	}
+	if got := read(); !slices.Equal(got, want) {
+		t.Errorf("history %v, want %v", got, want)
+	}
+	chunks, err := blockhistory.Chunks(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 {
+		t.Errorf("history of %v, want 2 chunks", chunks)
+	}
+	if len(w.history) != 0 {
+		t.Errorf("%d entries kept after flushing", len(w.history))
+	}
+
+	// later flushes append
+	w.QueueBlockUpdate(protocol.BlockPos{1, 64, 1}, stone, 0, start.Add(4*time.Second))
+	applyAndFlushHistory(t, w)
+	want = append(want, "minecraft:gold_block>minecraft:stone")
+	if got := read(); !slices.Equal(got, want) {
+		t.Errorf("history after append %v, want %v", got, want)
+	}
+
+	// renaming moves the history with the world
+	renamed := filepath.Join(filepath.Dir(folder), "renamed")
+	if err := w.Rename("renamed", renamed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); err == nil {
+		t.Error("history left at the old name")
+	}
+	dir = blockhistory.Dir(renamed)
+	if got := read(); !slices.Equal(got, want) {
+		t.Errorf("history after rename %v, want %v", got, want)
+	}
+
+	// a new capture with the same name starts a new history
+	w2 := newCaptureWorld(t, renamed)
+	w2.RecordHistory = true
+	w2.memState.StoreChunk(world.ChunkPos{0, 0}, newTestColumn(w2, nil))
+	w2.QueueBlockUpdate(protocol.BlockPos{2, 64, 2}, gold, 0, start)
+	applyAndFlushHistory(t, w2)
+	if got, want := read(), []string{"minecraft:air>minecraft:gold_block"}; !slices.Equal(got, want) {
+		t.Errorf("history of the new capture %v, want %v", got, want)
+	}
+}
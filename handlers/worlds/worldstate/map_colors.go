@@ -0,0 +1,106 @@
+package worldstate
+
+import "image/color"
+
+// base colours of bedrock maps, each one is used with 4 shades
+var mapBaseColors = [...]color.RGBA{
+	{0, 0, 0, 0},
+	{127, 178, 56, 255},
+	{247, 233, 163, 255},
+	{199, 199, 199, 255},
+	{255, 0, 0, 255},
+	{160, 160, 255, 255},
+	{167, 167, 167, 255},
+	{0, 124, 0, 255},
+	{255, 255, 255, 255},
+	{164, 168, 184, 255},
+	{151, 109, 77, 255},
+	{112, 112, 112, 255},
+	{64, 64, 255, 255},
+	{143, 119, 72, 255},
+	{255, 252, 245, 255},
+	{216, 127, 51, 255},
+	{178, 76, 216, 255},
+	{102, 153, 216, 255},
+	{229, 229, 51, 255},
+	{127, 204, 25, 255},
+	{242, 127, 165, 255},
+	{76, 76, 76, 255},
+	{153, 153, 153, 255},
+	{76, 127, 153, 255},
+	{127, 63, 178, 255},
+	{51, 76, 178, 255},
+	{102, 76, 51, 255},
+	{102, 127, 51, 255},
+	{153, 51, 51, 255},
+	{25, 25, 25, 255},
+	{250, 238, 77, 255},
+	{92, 219, 213, 255},
+	{74, 128, 255, 255},
+	{0, 217, 58, 255},
+	{129, 86, 49, 255},
+	{112, 2, 0, 255},
+	{209, 177, 161, 255},
+	{159, 82, 36, 255},
+	{149, 87, 108, 255},
+	{112, 108, 138, 255},
+	{186, 133, 36, 255},
+	{103, 117, 53, 255},
+	{160, 77, 78, 255},
+	{57, 41, 35, 255},
+	{135, 107, 98, 255},
+	{87, 92, 92, 255},
+	{122, 73, 88, 255},
+	{76, 62, 92, 255},
+	{76, 50, 35, 255},
+	{76, 82, 42, 255},
+	{142, 60, 46, 255},
+	{37, 22, 16, 255},
+	{189, 48, 49, 255},
+	{148, 63, 97, 255},
+	{92, 25, 29, 255},
+	{22, 126, 134, 255},
+	{58, 142, 140, 255},
+	{86, 44, 62, 255},
+	{20, 180, 133, 255},
+	{100, 100, 100, 255},
+	{216, 175, 147, 255},
+	{127, 167, 150, 255},
+}
+
+var mapShades = [4]uint32{180, 220, 255, 135}
+
+// mapPalette is every base colour in every shade
+var mapPalette = func() (p []color.RGBA) {
+	for _, c := range mapBaseColors[1:] {
+		for _, s := range mapShades {
+			p = append(p, color.RGBA{
+				R: uint8(uint32(c.R) * s / 255),
+				G: uint8(uint32(c.G) * s / 255),
+				B: uint8(uint32(c.B) * s / 255),
+				A: 255,
+			})
+		}
+	}
+	return p
+}()
+
+// nearestMapColor returns the closest colour a bedrock map can show
+func nearestMapColor(c color.RGBA) color.RGBA {
+	if c.A < 128 {
+		return color.RGBA{}
+	}
+	var best color.RGBA
+	var bestDist = -1
+	for _, p := range mapPalette {
+		dr, dg, db := int(c.R)-int(p.R), int(c.G)-int(p.G), int(c.B)-int(p.B)
+		dist := dr*dr + dg*dg + db*db
+		if dist == 0 {
+			return p
+		}
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = p, dist
+		}
+	}
+	return best
+}
@@ -0,0 +1,164 @@
+package worldstate
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestNearestMapColor(t *testing.T) {
+	tests := []struct {
+		name string
+		in   color.RGBA
+		want color.RGBA
+	}{
+		{"palette colour", color.RGBA{127, 178, 56, 255}, color.RGBA{127, 178, 56, 255}},
+		{"darker shade", color.RGBA{89, 125, 39, 255}, color.RGBA{89, 125, 39, 255}},
+		{"close to a colour", color.RGBA{128, 176, 58, 255}, color.RGBA{127, 178, 56, 255}},
+		{"alpha is ignored when opaque enough", color.RGBA{255, 0, 0, 200}, color.RGBA{255, 0, 0, 255}},
+		{"transparent", color.RGBA{255, 0, 0, 100}, color.RGBA{}},
+	}
+	for _, tt := range tests {
+		if got := nearestMapColor(tt.in); got != tt.want {
+			t.Errorf("%s: nearestMapColor(%v) = %v, want %v", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func mapPixel(m *Map, x, y int) color.RGBA {
+	off := (y*128 + x) * 4
+	return color.RGBA{m.Colors[off], m.Colors[off+1], m.Colors[off+2], m.Colors[off+3]}
+}
+
+func TestStoreMapFlags(t *testing.T) {
+	green := color.RGBA{127, 178, 56, 255}
+	red := color.RGBA{255, 0, 0, 255}
+	s := newWorldStateMem("test")
+
+	// the first packet of a map sets it up even without the initialisation flag
+	s.StoreMap(&packet.ClientBoundMapItemData{
+		MapID:       5,
+		UpdateFlags: packet.MapUpdateFlagTexture,
+		Dimension:   1,
+		Scale:       2,
+		Origin:      protocol.BlockPos{100, 64, -50},
+		Width:       2,
+		Height:      1,
+		XOffset:     127,
+		Pixels:      []color.RGBA{green, red},
+	})
+	m := s.maps[5]
+	if m == nil {
+		t.Fatal("map not stored")
+	}
+	if m.Dimension != 1 || m.Scale != 2 || m.XCenter != 100 || m.ZCenter != -50 || m.ParentMapId != -1 {
+		t.Errorf("map not set up %+v", m)
+	}
+	if got := mapPixel(m, 127, 0); got != green {
+		t.Errorf("pixel 127,0 = %v", got)
+	}
+
+	// updates without the initialisation flag keep the center
+	s.StoreMap(&packet.ClientBoundMapItemData{
+		MapID:       5,
+		UpdateFlags: packet.MapUpdateFlagDecoration,
+		Origin:      protocol.BlockPos{0, 0, 0},
+		Decorations: []protocol.MapDecoration{
+			{Type: 0, X: 10, Y: 10},
+			{Type: 1, Rotation: 8, X: byte(0xf6), Y: 4}, // -10, 4
+			{Type: 6, X: 1, Y: 1},
+		},
+	})
+	if m.XCenter != 100 || m.ZCenter != -50 {
+		t.Errorf("center moved to %d,%d", m.XCenter, m.ZCenter)
+	}
+	if len(m.Decorations) != 1 {
+		t.Fatalf("decorations %v, players should be left out", m.Decorations)
+	}
+	d := m.Decorations[0].(map[string]any)
+	data, key := d["data"].(map[string]any), d["key"].(map[string]any)
+	if data["x"] != int32(-10) || data["y"] != int32(4) || data["rot"] != int32(8) || data["type"] != int32(1) {
+		t.Errorf("decoration data %v", data)
+	}
+	// half pixels from the center, one pixel is 4 blocks at scale 2
+	if key["blockX"] != int32(80) || key["blockZ"] != int32(-42) {
+		t.Errorf("decoration key %v", key)
+	}
+
+	// the decoration flag replaces the old ones
+	s.StoreMap(&packet.ClientBoundMapItemData{MapID: 5, UpdateFlags: packet.MapUpdateFlagDecoration})
+	if len(m.Decorations) != 0 {
+		t.Errorf("decorations not cleared %v", m.Decorations)
+	}
+
+	s.StoreMap(&packet.ClientBoundMapItemData{
+		MapID:       5,
+		UpdateFlags: packet.MapUpdateFlagInitialisation,
+		Scale:       0,
+		Origin:      protocol.BlockPos{-8, 64, 8},
+		LockedMap:   true,
+	})
+	if m.XCenter != -8 || m.ZCenter != 8 || m.Scale != 0 || !m.MapLocked {
+		t.Errorf("initialisation not applied %+v", m)
+	}
+	if got := mapPixel(m, 127, 0); got != green {
+		t.Errorf("initialisation changed the texture")
+	}
+
+	// fewer pixels than the size says
+	s.StoreMap(&packet.ClientBoundMapItemData{
+		MapID:       5,
+		UpdateFlags: packet.MapUpdateFlagTexture,
+		Width:       2,
+		Height:      2,
+		Pixels:      []color.RGBA{red, red, red},
+	})
+	if mapPixel(m, 0, 1) != red || mapPixel(m, 1, 1) != (color.RGBA{}) {
+		t.Errorf("short texture wrote %v %v", mapPixel(m, 0, 1), mapPixel(m, 1, 1))
+	}
+}
+
+func TestStoreMapWhilePaused(t *testing.T) {
+	w := newTestWorld(t)
+	green := color.RGBA{127, 178, 56, 255}
+	red := color.RGBA{255, 0, 0, 255}
+	texture := func(id int64, origin protocol.BlockPos, x int32, c color.RGBA) *packet.ClientBoundMapItemData {
+		return &packet.ClientBoundMapItemData{
+			MapID:       id,
+			UpdateFlags: packet.MapUpdateFlagInitialisation | packet.MapUpdateFlagTexture,
+			Origin:      origin,
+			Width:       1,
+			Height:      1,
+			XOffset:     x,
+			Pixels:      []color.RGBA{c},
+		}
+	}
+	w.StoreMap(texture(1, protocol.BlockPos{0, 0, 0}, 0, green))
+
+	w.paused = true
+	w.StoreMap(texture(1, protocol.BlockPos{0, 0, 0}, 1, red))
+	w.StoreMap(texture(2, protocol.BlockPos{5000, 0, 0}, 0, red))
+	captured := w.memState.maps[1]
+	if mapPixel(captured, 1, 0) != (color.RGBA{}) || w.memState.maps[2] != nil {
+		t.Fatal("map changed while paused")
+	}
+	paused := w.pausedState.maps[1]
+	if paused == captured || mapPixel(paused, 0, 0) != green || mapPixel(paused, 1, 0) != red {
+		t.Error("paused map doesnt start from the captured one")
+	}
+
+	// only the map around the player is kept
+	w.paused = false
+	w.pausedState.ApplyTo(lockedWorld{w}, cube.Pos{0, 64, 0}, 4, func(world.ChunkPos, *chunk.Chunk) {})
+	if m := w.memState.maps[1]; m == nil || mapPixel(m, 1, 0) != red {
+		t.Error("map near the player not applied")
+	}
+	if w.memState.maps[2] != nil {
+		t.Error("far away map applied")
+	}
+}
@@ -92,9 +92,9 @@ func (w *World) addResourcePacks() error {
 }
 
 func (w *World) FinalizePacks(addBehaviorPack func(fs utils.WriterFS) (*resource.Header, error)) error {
-	err := <-w.resourcePacksDone
-	if err != nil {
-		return err
+	<-w.resourcePacksDone
+	if w.resourcePacksErr != nil {
+		return w.resourcePacksErr
 	}
 
 	messages.Router.Handle(&messages.Message{
@@ -106,6 +106,12 @@ func (w *World) FinalizePacks(addBehaviorPack func(fs utils.WriterFS) (*resource
 		},
 	})
 
+	return w.writePacks(addBehaviorPack, true)
+}
+
+// writePacks writes the behavior pack and the pack lists of the world,
+// the resource pack list is only written once the resource packs are copied
+func (w *World) writePacks(addBehaviorPack func(fs utils.WriterFS) (*resource.Header, error), resourcePacksDone bool) error {
 	fs := utils.OSWriter{Base: w.Folder}
 	header, err := addBehaviorPack(fs)
 	if err != nil {
@@ -122,7 +128,7 @@ func (w *World) FinalizePacks(addBehaviorPack func(fs utils.WriterFS) (*resource
 		}
 	}
 
-	if len(w.resourcePackDependencies) > 0 {
+	if resourcePacksDone && len(w.resourcePackDependencies) > 0 {
 		err := addPacksJSON(fs, "world_resource_packs.json", w.resourcePackDependencies)
 		if err != nil {
 			return err
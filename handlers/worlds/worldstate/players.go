@@ -11,9 +11,12 @@ type player struct {
 	add                 *packet.AddPlayer
 	Position            mgl32.Vec3
 	Pitch, Yaw, HeadYaw float32
+	gone                bool // out of view, still saved where it was last seen
 }
 
 func (w *World) AddPlayer(pk *packet.AddPlayer) {
+	w.entityLock.Lock()
+	defer w.entityLock.Unlock()
 	w.players[pk.UUID] = &player{
 		add:      pk,
 		Position: pk.Position,
@@ -23,6 +26,42 @@ func (w *World) AddPlayer(pk *packet.AddPlayer) {
 	}
 }
 
+func (w *World) MovePlayer(id uint64, pos mgl32.Vec3, pitch, yaw, headYaw float32) {
+	w.entityLock.Lock()
+	defer w.entityLock.Unlock()
+	for _, p := range w.players {
+		if p.add.EntityRuntimeID == id {
+			p.Position = pos
+			p.Pitch, p.Yaw, p.HeadYaw = pitch, yaw, headYaw
+			p.gone = false
+			return
+		}
+	}
+}
+
+// RemovePlayer marks the player as out of view
+func (w *World) RemovePlayer(uniqueID int64) {
+	w.entityLock.Lock()
+	defer w.entityLock.Unlock()
+	for _, p := range w.players {
+		if p.add.AbilityData.EntityUniqueID == uniqueID {
+			p.gone = true
+			return
+		}
+	}
+}
+
+// RangePlayers calls f for every player that is in view
+func (w *World) RangePlayers(f func(name string, pos mgl32.Vec3, yaw float32)) {
+	w.entityLock.Lock()
+	defer w.entityLock.Unlock()
+	for _, p := range w.players {
+		if !p.gone {
+			f(p.add.Username, p.Position, p.HeadYaw)
+		}
+	}
+}
+
 func (w *World) playersToEntities() {
 	for _, p := range w.players {
 		metadata := protocol.NewEntityMetadata()
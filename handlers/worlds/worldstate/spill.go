@@ -0,0 +1,259 @@
+package worldstate
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/gob"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds/entity"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"github.com/df-mc/goleveldb/leveldb/opt"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	// types that can be inside of entity metadata, properties and item nbt
+	gob.Register(map[string]any{})
+	gob.Register([]any{})
+	gob.Register([]map[string]any{})
+	gob.Register(protocol.BlockPos{})
+	gob.Register(mgl32.Vec3{})
+}
+
+// spillStore keeps chunks and entities that dont fit in the memory budget in a temporary world db
+type spillStore struct {
+	dir string
+	dim world.Dimension
+	db  *mcdb.DB
+}
+
+func openSpillStore(log *logrus.Entry, blocks world.BlockRegistry, biomes *world.BiomeRegistry, dim world.Dimension) (*spillStore, error) {
+	dir, err := os.MkdirTemp("", "bedrocktool-spill")
+	if err != nil {
+		return nil, err
+	}
+	db, err := mcdb.Config{
+		Log:         log,
+		Compression: opt.NoCompression,
+		Blocks:      blocks,
+		Biomes:      biomes,
+	}.Open(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &spillStore{dir: dir, dim: dim, db: db}, nil
+}
+
+func (s *spillStore) storeColumn(pos world.ChunkPos, col *world.Column) error {
+	return s.db.StoreColumn(pos, s.dim, col)
+}
+
+func (s *spillStore) loadColumn(pos world.ChunkPos) (*world.Column, error) {
+	return s.db.LoadColumn(pos, s.dim)
+}
+
+func entityKey(prefix string, id entity.RuntimeID) []byte {
+	return []byte("bedrocktool_spill_" + prefix + "_" + strconv.FormatUint(id, 10))
+}
+
+func encodeEntity(es *entity.Entity) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(es)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeEntity(data []byte) (*entity.Entity, error) {
+	var es entity.Entity
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&es)
+	if err != nil {
+		return nil, err
+	}
+	return &es, nil
+}
+
+func (s *spillStore) storeEntity(prefix string, es *entity.Entity) error {
+	data, err := encodeEntity(es)
+	if err != nil {
+		return err
+	}
+	return s.db.LDB().Put(entityKey(prefix, es.RuntimeID), data, nil)
+}
+
+func (s *spillStore) loadEntity(prefix string, id entity.RuntimeID) (*entity.Entity, error) {
+	data, err := s.db.LDB().Get(entityKey(prefix, id), nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeEntity(data)
+}
+
+func (s *spillStore) Close() error {
+	err := s.db.Close()
+	os.RemoveAll(s.dir)
+	return err
+}
+
+// columnSize is a rough estimate of how much memory a column uses
+func columnSize(col *world.Column) int64 {
+	size := int64(1024)
+	for _, sub := range col.Chunk.Sub() {
+		if sub.Empty() {
+			continue
+		}
+		// 4096 indices of up to 16 bits for every layer, plus the palette
+		size += int64(len(sub.Layers())) * (8192 + 256)
+	}
+	size += int64(len(col.BlockEntities)) * 512
+	return size
+}
+
+// entitySize is a rough estimate of how much memory an entity uses
+func entitySize(es *entity.Entity) int64 {
+	size := int64(512) + int64(len(es.Metadata))*64 + int64(len(es.Properties))*128
+	for _, items := range es.Inventory {
+		size += int64(len(items)) * 512
+	}
+	return size
+}
+
+// memoryUsage estimates how much memory the chunks and entities of this state use
+func (w *worldStateMem) memoryUsage() (size int64) {
+	for _, col := range w.chunks {
+		size += columnSize(col)
+	}
+	for _, es := range w.entities {
+		size += entitySize(es)
+	}
+	return size
+}
+
+type spillCandidate struct {
+	state   *worldStateMem
+	lastUse uint64
+	size    int64
+	chunk   *world.ChunkPos
+	entity  entity.RuntimeID
+}
+
+func (w *worldStateMem) spillCandidates(withChunks bool) (out []spillCandidate) {
+	if withChunks {
+		for pos, col := range w.chunks {
+			pos := pos
+			out = append(out, spillCandidate{state: w, lastUse: w.chunkUse[pos], size: columnSize(col), chunk: &pos})
+		}
+	}
+	for id, es := range w.entities {
+		out = append(out, spillCandidate{state: w, lastUse: w.entityUse[id], size: entitySize(es), entity: id})
+	}
+	return out
+}
+
+func (w *worldStateMem) spillChunk(pos world.ChunkPos) error {
+	err := w.spill.storeColumn(pos, w.chunks[pos])
+	if err != nil {
+		return err
+	}
+	delete(w.chunks, pos)
+	delete(w.chunkUse, pos)
+	w.spilledChunks[pos] = struct{}{}
+	return nil
+}
+
+func (w *worldStateMem) spillEntity(id entity.RuntimeID) error {
+	err := w.spill.storeEntity(w.name, w.entities[id])
+	if err != nil {
+		return err
+	}
+	delete(w.entities, id)
+	delete(w.entityUse, id)
+	w.spilledEntities[id] = struct{}{}
+	return nil
+}
+
+// closeSpill removes the spill store of this state, anything still spilled is lost
+func (w *worldStateMem) closeSpill() {
+	if w.spill != nil {
+		w.spill.Close()
+		w.spill = nil
+	}
+}
+
+// openSpill opens the spill store of a state if it isnt open yet, it is opened with the dimension height the server sent.
+// every state has its own store since chunks are only keyed by position
+func (w *World) openSpill(state *worldStateMem) error {
+	if state.spill != nil {
+		return nil
+	}
+	var err error
+	state.spill, err = openSpillStore(w.log, w.BlockRegistry, w.BiomeRegistry, w.storageDimension())
+	return err
+}
+
+// enforceMemoryBudget moves the least recently used chunks and entities to disk
+// until the estimated memory usage is below the budget, has to be called with both locks held
+func (w *World) enforceMemoryBudget() {
+	if w.MemoryBudget <= 0 {
+		return
+	}
+	states := []*worldStateMem{w.memState}
+	if w.pausedState != nil {
+		states = append(states, w.pausedState)
+	}
+
+	var usage int64
+	for _, state := range states {
+		usage += state.memoryUsage()
+	}
+	if usage <= w.MemoryBudget {
+		return
+	}
+
+	for _, state := range states {
+		if err := w.openSpill(state); err != nil {
+			w.log.WithError(err).Error("failed to open spill store")
+			w.MemoryBudget = 0
+			return
+		}
+	}
+
+	// chunks in the memstate go to the provider on their own, only spill those of the paused state
+	var candidates []spillCandidate
+	for _, state := range states {
+		candidates = append(candidates, state.spillCandidates(state == w.pausedState)...)
+	}
+	slices.SortFunc(candidates, func(a, b spillCandidate) int {
+		return cmp.Compare(a.lastUse, b.lastUse)
+	})
+
+	// spill down to 80% so this doesnt run again right away
+	target := w.MemoryBudget * 8 / 10
+	var spilled int
+	for _, c := range candidates {
+		if usage <= target {
+			break
+		}
+		var err error
+		if c.chunk != nil {
+			err = c.state.spillChunk(*c.chunk)
+		} else {
+			err = c.state.spillEntity(c.entity)
+		}
+		if err != nil {
+			w.log.WithError(err).Error("failed to spill to disk")
+			return
+		}
+		usage -= c.size
+		spilled++
+	}
+	w.log.Debugf("spilled %d chunks and entities to disk", spilled)
+}
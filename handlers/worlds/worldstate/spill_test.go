@@ -0,0 +1,145 @@
+package worldstate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds/entity"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestWorld is a world with the default registries and both states, not backed by a provider
+func newTestWorld(t *testing.T) *World {
+	w := &World{
+		BlockRegistry: world.DefaultBlockRegistry,
+		BiomeRegistry: world.DefaultBiomes,
+		dimension:     world.Overworld,
+		dimRange:      world.Overworld.Range(),
+		memState:      newWorldStateMem("mem"),
+		pausedState:   newWorldStateMem("paused"),
+		log:           logrus.WithField("test", t.Name()),
+	}
+	t.Cleanup(func() {
+		w.memState.closeSpill()
+		w.pausedState.closeSpill()
+	})
+	return w
+}
+
+func testRID(t *testing.T, w *World, name string) uint32 {
+	rid, ok := w.BlockRegistry.StateToRuntimeID(name, nil)
+	if !ok {
+		t.Fatalf("no runtime id for %s", name)
+	}
+	return rid
+}
+
+// newTestColumn is a column with the given blocks set in layer 0
+func newTestColumn(w *World, blocks map[[3]int]uint32) *world.Column {
+	c := chunk.New(w.BlockRegistry, w.dimRange)
+	for pos, rid := range blocks {
+		c.SetBlock(uint8(pos[0]), int16(pos[1]), uint8(pos[2]), 0, rid)
+	}
+	return &world.Column{Chunk: c}
+}
+
+func TestEntityGobRoundTrip(t *testing.T) {
+	item := protocol.ItemInstance{
+		StackNetworkID: 3,
+		Stack: protocol.ItemStack{
+			ItemType: protocol.ItemType{NetworkID: 5, MetadataValue: 1},
+			Count:    12,
+			NBTData: map[string]any{
+				"display": map[string]any{"Name": "sword"},
+				"ench":    []any{map[string]any{"id": int16(9), "lvl": int16(2)}},
+			},
+			CanBePlacedOn: []string{"minecraft:stone"},
+		},
+	}
+	es := &entity.Entity{
+		RuntimeID:  7,
+		UniqueID:   -7,
+		EntityType: "minecraft:villager_v2",
+		Position:   mgl32.Vec3{1, 2, 3},
+		Metadata: protocol.EntityMetadata{
+			protocol.EntityDataKeyName:        "bob",
+			protocol.EntityDataKeyFlags:       int64(1 << protocol.EntityDataFlagBaby),
+			protocol.EntityDataKeyVariant:     int32(2),
+			protocol.EntityDataKeyScale:       float32(0.5),
+			protocol.EntityDataKeyColorIndex:  byte(4),
+			protocol.EntityDataKeyBedPosition: protocol.BlockPos{1, 64, -3},
+		},
+		Properties: map[string]*entity.EntityProperty{
+			"minecraft:has_nectar": {Type: entity.PropertyTypeBool, Name: "minecraft:has_nectar", Value: true},
+		},
+		Inventory: map[byte]map[byte]protocol.ItemInstance{
+			protocol.WindowIDInventory: {0: item},
+		},
+		Helmet: &item,
+		ExtraNBT: map[string]any{
+			"Mainhand": []map[string]any{{"Name": "minecraft:stick", "Count": byte(1), "Damage": int16(0)}},
+			"Offers": map[string]any{
+				"Recipes": []any{map[string]any{"buyA": map[string]any{"Name": "minecraft:emerald"}, "rewardExp": byte(1)}},
+			},
+			"TradeTier": int32(2),
+		},
+	}
+
+	data, err := encodeEntity(es)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeEntity(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, es) {
+		t.Errorf("round trip changed the entity\ngot  %+v\nwant %+v", got, es)
+	}
+}
+
+func TestSpillStatesKeepTheirOwnChunks(t *testing.T) {
+	w := newTestWorld(t)
+	pos := world.ChunkPos{3, -2}
+	stone := testRID(t, w, "minecraft:stone")
+	gold := testRID(t, w, "minecraft:gold_block")
+
+	// the same position is spilled from the captured state first and the paused state after
+	for _, s := range []struct {
+		state *worldStateMem
+		rid   uint32
+	}{{w.memState, stone}, {w.pausedState, gold}} {
+		s.state.StoreChunk(pos, newTestColumn(w, map[[3]int]uint32{{1, 64, 1}: s.rid}))
+		s.state.StoreEntity(1, &entity.Entity{RuntimeID: 1, EntityType: s.state.name})
+		if err := w.openSpill(s.state); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.state.spillChunk(pos); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.state.spillEntity(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, s := range []struct {
+		state *worldStateMem
+		rid   uint32
+	}{{w.memState, stone}, {w.pausedState, gold}} {
+		col, ok := s.state.chunk(pos)
+		if !ok {
+			t.Fatalf("%s: spilled chunk not found", s.state.name)
+		}
+		if got := col.Chunk.Block(1, 64, 1, 0); got != s.rid {
+			t.Errorf("%s: block = %d, want %d", s.state.name, got, s.rid)
+		}
+		es := s.state.GetEntity(1)
+		if es == nil || es.EntityType != s.state.name {
+			t.Errorf("%s: entity = %+v", s.state.name, es)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package worldstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+// BoundingBox is the area of a dimension that has blocks in it
+type BoundingBox struct {
+	Min [3]int `json:"min"`
+	Max [3]int `json:"max"`
+}
+
+// Stats is a summary of a saved world
+type Stats struct {
+	Name            string                 `json:"name"`
+	CaptureStart    time.Time              `json:"capture_start"`
+	CaptureEnd      time.Time              `json:"capture_end"`
+	DurationSeconds float64                `json:"duration_seconds"`
+	Chunks          int                    `json:"chunks"`
+	ChunksPerMinute float64                `json:"chunks_per_minute"`
+	Bounds          map[string]BoundingBox `json:"bounds"`
+	Blocks          map[string]int         `json:"blocks"`
+	BlockEntities   map[string]int         `json:"block_entities"`
+	Entities        map[string]int         `json:"entities"`
+	UnknownBlocks   int                    `json:"unknown_blocks"`
+	CustomBlocks    map[string]int         `json:"custom_blocks"`
+}
+
+// collectStats counts everything in the provider, has to be called after storing everything
+func (w *World) collectStats(entities map[string]int) (*Stats, error) {
+	end := time.Now()
+	stats := &Stats{
+		Name:            w.Name,
+		CaptureStart:    w.created,
+		CaptureEnd:      end,
+		DurationSeconds: end.Sub(w.created).Seconds(),
+		Bounds:          make(map[string]BoundingBox),
+		Blocks:          make(map[string]int),
+		BlockEntities:   make(map[string]int),
+		Entities:        entities,
+		CustomBlocks:    make(map[string]int),
+	}
+
+	// runtime ids are counted per sub-chunk layer and only looked up once at the end,
+	// layer 1 holds the liquid of waterlogged blocks and is counted like layer 0
+	airRID, _ := w.BlockRegistry.StateToRuntimeID("minecraft:air", nil)
+	counts := make(map[uint32]int)
+	bounds := make(map[world.Dimension]*BoundingBox)
+	it := w.provider.NewColumnIterator(nil)
+	defer it.Release()
+	for it.Next() {
+		col := it.Column()
+		pos := it.Position()
+		dim := it.Dimension()
+		stats.Chunks++
+
+		for _, be := range col.BlockEntities {
+			name, _ := be.EncodeBlock()
+			stats.BlockEntities[name]++
+		}
+
+		r := col.Chunk.Range()
+		for i, sub := range col.Chunk.Sub() {
+			if sub.Empty() {
+				continue
+			}
+			lo, hi := [3]int{16, 16, 16}, [3]int{-1, -1, -1}
+			for _, layer := range sub.Layers() {
+				if pal := layer.Palette(); pal.Len() == 1 {
+					v := pal.Value(0)
+					counts[v] += 16 * 16 * 16
+					if v != airRID {
+						lo, hi = [3]int{0, 0, 0}, [3]int{15, 15, 15}
+					}
+					continue
+				}
+				for y := uint8(0); y < 16; y++ {
+					for x := uint8(0); x < 16; x++ {
+						for z := uint8(0); z < 16; z++ {
+							v := layer.At(x, y, z)
+							counts[v]++
+							if v != airRID {
+								lo = [3]int{min(lo[0], int(x)), min(lo[1], int(y)), min(lo[2], int(z))}
+								hi = [3]int{max(hi[0], int(x)), max(hi[1], int(y)), max(hi[2], int(z))}
+							}
+						}
+					}
+				}
+			}
+			if hi[0] < 0 {
+				continue
+			}
+
+			base := [3]int{int(pos[0]) * 16, r.Min() + i*16, int(pos[1]) * 16}
+			bmin := [3]int{base[0] + lo[0], base[1] + lo[1], base[2] + lo[2]}
+			bmax := [3]int{base[0] + hi[0], base[1] + hi[1], base[2] + hi[2]}
+			b, ok := bounds[dim]
+			if !ok {
+				b = &BoundingBox{Min: bmin, Max: bmax}
+				bounds[dim] = b
+			}
+			b.Min = [3]int{min(b.Min[0], bmin[0]), min(b.Min[1], bmin[1]), min(b.Min[2], bmin[2])}
+			b.Max = [3]int{max(b.Max[0], bmax[0]), max(b.Max[1], bmax[1]), max(b.Max[2], bmax[2])}
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+
+	for rid, n := range counts {
+		if rid == airRID {
+			continue
+		}
+		name, _, found := w.BlockRegistry.RuntimeIDToState(rid)
+		if !found {
+			stats.UnknownBlocks += n
+			continue
+		}
+		if name == "minecraft:air" {
+			continue
+		}
+		stats.Blocks[name] += n
+		if !strings.HasPrefix(name, "minecraft:") {
+			stats.CustomBlocks[name] += n
+		}
+	}
+
+	for dim, b := range bounds {
+		stats.Bounds[dimensionName(dim)] = *b
+	}
+	if minutes := stats.DurationSeconds / 60; minutes > 0 {
+		stats.ChunksPerMinute = math.Round(float64(stats.Chunks)/minutes*100) / 100
+	}
+	return stats, nil
+}
+
+func dimensionName(dim world.Dimension) string {
+	id, _ := world.DimensionID(dim)
+	switch id {
+	case 0:
+		return "overworld"
+	case 1:
+		return "nether"
+	case 2:
+		return "end"
+	}
+	return fmt.Sprintf("dimension %d", id)
+}
+
+func (s *Stats) WriteJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	return e.Encode(s)
+}
+
+type countEntry struct {
+	name  string
+	count int
+}
+
+func sortedCounts(m map[string]int) []countEntry {
+	out := make([]countEntry, 0, len(m))
+	for name, count := range m {
+		out = append(out, countEntry{name, count})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].count != out[j].count {
+			return out[i].count > out[j].count
+		}
+		return out[i].name < out[j].name
+	})
+	return out
+}
+
+// WriteSummary writes the stats in a readable form, rarest blocks last
+func (s *Stats) WriteSummary(w io.Writer) error {
+	var total int
+	for _, c := range s.Blocks {
+		total += c
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", s.Name)
+	fmt.Fprintf(&b, "captured %s to %s (%s)\n",
+		s.CaptureStart.Format(time.DateTime), s.CaptureEnd.Format(time.DateTime),
+		time.Duration(s.DurationSeconds*float64(time.Second)).Round(time.Second),
+	)
+	fmt.Fprintf(&b, "%d chunks, %.2f chunks per minute\n", s.Chunks, s.ChunksPerMinute)
+	for dim, bb := range s.Bounds {
+		fmt.Fprintf(&b, "%s: %d %d %d to %d %d %d\n", dim, bb.Min[0], bb.Min[1], bb.Min[2], bb.Max[0], bb.Max[1], bb.Max[2])
+	}
+	fmt.Fprintf(&b, "%d blocks, %d unknown blocks, %d custom block types\n", total, s.UnknownBlocks, len(s.CustomBlocks))
+
+	section := func(title string, m map[string]int) {
+		if len(m) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s:\n", title)
+		for _, e := range sortedCounts(m) {
+			fmt.Fprintf(&b, "%10d %s\n", e.count, e.name)
+		}
+	}
+	section("blocks", s.Blocks)
+	section("custom blocks", s.CustomBlocks)
+	section("block entities", s.BlockEntities)
+	section("entities", s.Entities)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
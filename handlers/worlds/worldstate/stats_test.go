@@ -0,0 +1,95 @@
+package worldstate
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+)
+
+func TestCollectStats(t *testing.T) {
+	w := newTestWorld(t)
+	w.Name = "stats"
+	w.created = time.Now().Add(-2 * time.Minute)
+	var err error
+	w.provider, err = mcdb.Config{Log: w.log, Blocks: w.BlockRegistry}.Open(filepath.Join(t.TempDir(), "world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.provider.Close() })
+
+	stone := testRID(t, w, "minecraft:stone")
+	glass := testRID(t, w, "minecraft:glass")
+	water, ok := w.BlockRegistry.StateToRuntimeID("minecraft:water", map[string]any{"liquid_depth": int32(0)})
+	if !ok {
+		t.Fatal("no runtime id for water")
+	}
+	store := func(pos world.ChunkPos, dim world.Dimension, c *chunk.Chunk) {
+		if err := w.provider.StoreColumn(pos, dim, &world.Column{Chunk: c}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a sub-chunk of only stone, read back with a single entry palette
+	full := chunk.New(w.BlockRegistry, world.Overworld.Range())
+	for x := uint8(0); x < 16; x++ {
+		for y := int16(-64); y < -48; y++ {
+			for z := uint8(0); z < 16; z++ {
+				full.SetBlock(x, y, z, 0, stone)
+			}
+		}
+	}
+	store(world.ChunkPos{1, -1}, world.Overworld, full)
+
+	// single blocks, the glass is waterlogged
+	mixed := chunk.New(w.BlockRegistry, world.Overworld.Range())
+	mixed.SetBlock(3, 70, 5, 0, stone)
+	mixed.SetBlock(4, 71, 5, 0, glass)
+	mixed.SetBlock(4, 71, 5, 1, water)
+	store(world.ChunkPos{0, 0}, world.Overworld, mixed)
+
+	nether := chunk.New(w.BlockRegistry, world.Nether.Range())
+	nether.SetBlock(0, 0, 0, 0, stone)
+	store(world.ChunkPos{-2, 3}, world.Nether, nether)
+
+	// a chunk with only air counts as a chunk but not for the bounds
+	store(world.ChunkPos{50, 50}, world.Overworld, chunk.New(w.BlockRegistry, world.Overworld.Range()))
+
+	entities := map[string]int{"minecraft:cow": 2}
+	stats, err := w.collectStats(entities)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Name != "stats" || stats.Chunks != 4 {
+		t.Errorf("name %q chunks %d", stats.Name, stats.Chunks)
+	}
+	wantBlocks := map[string]int{
+		"minecraft:stone": 16*16*16 + 2,
+		"minecraft:glass": 1,
+		"minecraft:water": 1,
+	}
+	if !reflect.DeepEqual(stats.Blocks, wantBlocks) {
+		t.Errorf("Blocks = %v, want %v", stats.Blocks, wantBlocks)
+	}
+	if stats.UnknownBlocks != 0 || len(stats.CustomBlocks) != 0 {
+		t.Errorf("unknown %d custom %v", stats.UnknownBlocks, stats.CustomBlocks)
+	}
+	wantBounds := map[string]BoundingBox{
+		"overworld": {Min: [3]int{3, -64, -16}, Max: [3]int{31, 71, 5}},
+		"nether":    {Min: [3]int{-32, 0, 48}, Max: [3]int{-32, 0, 48}},
+	}
+	if !reflect.DeepEqual(stats.Bounds, wantBounds) {
+		t.Errorf("Bounds = %v, want %v", stats.Bounds, wantBounds)
+	}
+	if !reflect.DeepEqual(stats.Entities, entities) {
+		t.Errorf("Entities = %v", stats.Entities)
+	}
+	if stats.ChunksPerMinute < 1.9 || stats.ChunksPerMinute > 2 {
+		t.Errorf("ChunksPerMinute = %v", stats.ChunksPerMinute)
+	}
+}
@@ -0,0 +1,196 @@
+package worldstate
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+// TextEntry is text found on a sign or in a book
+type TextEntry struct {
+	Dimension string   `json:"dimension"`
+	Pos       cube.Pos `json:"pos"`
+	Source    string   `json:"source"` // block entity id, like Sign, Lectern or Chest
+	Kind      string   `json:"kind"`   // sign or book
+	Title     string   `json:"title,omitempty"`
+	Author    string   `json:"author,omitempty"`
+	Text      string   `json:"text"`
+}
+
+// TextIndex keeps all text seen in block entities, by position
+type TextIndex struct {
+	lock    sync.Mutex
+	entries map[cube.Pos][]TextEntry
+}
+
+func newTextIndex() *TextIndex {
+	return &TextIndex{
+		entries: make(map[cube.Pos][]TextEntry),
+	}
+}
+
+// blockEntityNBT returns the nbt of a captured or loaded block entity
+func blockEntityNBT(b world.Block) map[string]any {
+	switch b := b.(type) {
+	case world.UnknownBlock:
+		return b.Properties
+	case world.NBTer:
+		return b.EncodeNBT()
+	}
+	return nil
+}
+
+// Update replaces the text at pos with what is in the block entity nbt
+func (t *TextIndex) Update(dim world.Dimension, pos cube.Pos, nbt map[string]any) {
+	id, _ := nbt["id"].(string)
+	var entries []TextEntry
+	add := func(e TextEntry) {
+		if strings.TrimSpace(e.Text) == "" {
+			return
+		}
+		e.Dimension = dimensionName(dim)
+		e.Pos = pos
+		e.Source = id
+		entries = append(entries, e)
+	}
+
+	switch id {
+	case "Sign", "HangingSign":
+		for _, side := range []string{"FrontText", "BackText"} {
+			if text, ok := nbt[side].(map[string]any); ok {
+				s, _ := text["Text"].(string)
+				add(TextEntry{Kind: "sign", Text: s})
+			}
+		}
+		// before 1.20 signs only had one side
+		if s, ok := nbt["Text"].(string); ok {
+			add(TextEntry{Kind: "sign", Text: s})
+		}
+	case "Lectern":
+		if book, ok := nbt["book"].(map[string]any); ok {
+			booksInItem(book, add)
+		}
+	}
+	booksInItems(nbt["Items"], add)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if len(entries) == 0 {
+		delete(t.entries, pos)
+		return
+	}
+	t.entries[pos] = entries
+}
+
+func booksInItems(items any, add func(TextEntry)) {
+	switch items := items.(type) {
+	case []any:
+		for _, it := range items {
+			if it, ok := it.(map[string]any); ok {
+				booksInItem(it, add)
+			}
+		}
+	case []map[string]any:
+		for _, it := range items {
+			booksInItem(it, add)
+		}
+	}
+}
+
+// booksInItem finds the text of books, also inside of shulker boxes
+func booksInItem(item map[string]any, add func(TextEntry)) {
+	tag, ok := item["tag"].(map[string]any)
+	if !ok {
+		return
+	}
+	name, _ := item["Name"].(string)
+	if name == "minecraft:written_book" || name == "minecraft:writable_book" {
+		var pages []string
+		switch p := tag["pages"].(type) {
+		case []any:
+			for _, page := range p {
+				if page, ok := page.(map[string]any); ok {
+					text, _ := page["text"].(string)
+					pages = append(pages, text)
+				}
+			}
+		case []map[string]any:
+			for _, page := range p {
+				text, _ := page["text"].(string)
+				pages = append(pages, text)
+			}
+		}
+		title, _ := tag["title"].(string)
+		author, _ := tag["author"].(string)
+		add(TextEntry{Kind: "book", Title: title, Author: author, Text: strings.Join(pages, "\n\n")})
+	}
+	booksInItems(tag["Items"], add)
+}
+
+// Entries returns all text sorted by position
+func (t *TextIndex) Entries() []TextEntry {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var out []TextEntry
+	for _, entries := range t.entries {
+		out = append(out, entries...)
+	}
+	slices.SortStableFunc(out, func(a, b TextEntry) int {
+		for i := range a.Pos {
+			if a.Pos[i] != b.Pos[i] {
+				return a.Pos[i] - b.Pos[i]
+			}
+		}
+		return 0
+	})
+	return out
+}
+
+// Find returns the entries that contain query, ignoring case
+func (t *TextIndex) Find(query string) []TextEntry {
+	query = strings.ToLower(query)
+	var out []TextEntry
+	for _, e := range t.Entries() {
+		if strings.Contains(strings.ToLower(e.Text), query) || strings.Contains(strings.ToLower(e.Title), query) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (t *TextIndex) Len() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return len(t.entries)
+}
+
+func (t *TextIndex) WriteJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	entries := t.Entries()
+	if entries == nil {
+		entries = []TextEntry{}
+	}
+	return e.Encode(entries)
+}
+
+func (t *TextIndex) WriteCSV(w io.Writer) error {
+	c := csv.NewWriter(w)
+	c.Write([]string{"dimension", "x", "y", "z", "source", "kind", "title", "author", "text"})
+	for _, e := range t.Entries() {
+		c.Write([]string{
+			e.Dimension,
+			strconv.Itoa(e.Pos[0]), strconv.Itoa(e.Pos[1]), strconv.Itoa(e.Pos[2]),
+			e.Source, e.Kind, e.Title, e.Author, e.Text,
+		})
+	}
+	c.Flush()
+	return c.Error()
+}
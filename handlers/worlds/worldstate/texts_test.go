@@ -0,0 +1,209 @@
+package worldstate
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+func book(name, title, author string, pages ...string) map[string]any {
+	var p []any
+	for _, page := range pages {
+		p = append(p, map[string]any{"text": page})
+	}
+	return map[string]any{
+		"Name": name,
+		"tag":  map[string]any{"title": title, "author": author, "pages": p},
+	}
+}
+
+func TestTextIndexUpdate(t *testing.T) {
+	pos := cube.Pos{1, 64, -2}
+	tests := []struct {
+		name string
+		nbt  map[string]any
+		want []TextEntry
+	}{
+		{
+			name: "sign with two sides",
+			nbt: map[string]any{
+				"id":        "Sign",
+				"FrontText": map[string]any{"Text": "front"},
+				"BackText":  map[string]any{"Text": "back"},
+			},
+			want: []TextEntry{{Kind: "sign", Text: "front"}, {Kind: "sign", Text: "back"}},
+		},
+		{
+			name: "empty back side",
+			nbt: map[string]any{
+				"id":        "HangingSign",
+				"FrontText": map[string]any{"Text": "hanging"},
+				"BackText":  map[string]any{"Text": " \n "},
+			},
+			want: []TextEntry{{Kind: "sign", Text: "hanging"}},
+		},
+		{
+			name: "old sign",
+			nbt:  map[string]any{"id": "Sign", "Text": "old"},
+			want: []TextEntry{{Kind: "sign", Text: "old"}},
+		},
+		{
+			name: "lectern",
+			nbt:  map[string]any{"id": "Lectern", "book": book("minecraft:written_book", "Diary", "steve", "one", "two")},
+			want: []TextEntry{{Kind: "book", Title: "Diary", Author: "steve", Text: "one\n\ntwo"}},
+		},
+		{
+			name: "pages as a typed list",
+			nbt: map[string]any{"id": "Lectern", "book": map[string]any{
+				"Name": "minecraft:writable_book",
+				"tag":  map[string]any{"pages": []map[string]any{{"text": "draft"}}},
+			}},
+			want: []TextEntry{{Kind: "book", Text: "draft"}},
+		},
+		{
+			name: "books in a chest",
+			nbt: map[string]any{"id": "Chest", "Items": []any{
+				map[string]any{"Name": "minecraft:dirt", "tag": map[string]any{}},
+				book("minecraft:writable_book", "", "", "notes"),
+			}},
+			want: []TextEntry{{Kind: "book", Text: "notes"}},
+		},
+		{
+			name: "book in a shulker box in a barrel",
+			nbt: map[string]any{"id": "Barrel", "Items": []map[string]any{{
+				"Name": "minecraft:shulker_box",
+				"tag":  map[string]any{"Items": []any{book("minecraft:written_book", "Secret", "alex", "hidden")}},
+			}}},
+			want: []TextEntry{{Kind: "book", Title: "Secret", Author: "alex", Text: "hidden"}},
+		},
+		{
+			name: "no text",
+			nbt:  map[string]any{"id": "Furnace", "Items": []any{map[string]any{"Name": "minecraft:coal"}}},
+		},
+	}
+	for _, tt := range tests {
+		ti := newTextIndex()
+		ti.Update(world.Overworld, pos, tt.nbt)
+		source, _ := tt.nbt["id"].(string)
+		for i := range tt.want {
+			tt.want[i].Dimension = "overworld"
+			tt.want[i].Pos = pos
+			tt.want[i].Source = source
+		}
+		if got := ti.Entries(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTextIndexReplace(t *testing.T) {
+	ti := newTextIndex()
+	pos := cube.Pos{0, 70, 0}
+	ti.Update(world.Nether, pos, map[string]any{"id": "Sign", "Text": "first"})
+	ti.Update(world.Nether, pos, map[string]any{"id": "Sign", "Text": "second"})
+	if e := ti.Entries(); len(e) != 1 || e[0].Text != "second" || e[0].Dimension != "nether" {
+		t.Errorf("entries after replacing %+v", e)
+	}
+	// a sign that was cleared is removed
+	ti.Update(world.Nether, pos, map[string]any{"id": "Sign", "Text": ""})
+	if ti.Len() != 0 {
+		t.Errorf("%d entries after clearing", ti.Len())
+	}
+}
+
+func TestTextIndexFindAndWrite(t *testing.T) {
+	ti := newTextIndex()
+	ti.Update(world.Overworld, cube.Pos{5, 64, 0}, map[string]any{"id": "Sign", "Text": "Welcome to Spawn"})
+	ti.Update(world.Overworld, cube.Pos{-5, 64, 0}, map[string]any{"id": "Lectern", "book": book("minecraft:written_book", "Spawn rules", "admin", "be nice")})
+	ti.Update(world.Overworld, cube.Pos{0, 64, 9}, map[string]any{"id": "Sign", "Text": "shop, \"cheap\""})
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"spawn", []string{"be nice", "Welcome to Spawn"}},
+		{"RULES", []string{"be nice"}},
+		{"cheap", []string{"shop, \"cheap\""}},
+		{"nothing", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, e := range ti.Find(tt.query) {
+			got = append(got, e.Text)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Find(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := ti.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 || rows[0][0] != "dimension" {
+		t.Fatalf("csv rows %v", rows)
+	}
+	// sorted by position, quotes and commas survive
+	want := []string{"overworld", "0", "64", "9", "Sign", "sign", "", "", "shop, \"cheap\""}
+	if !reflect.DeepEqual(rows[2], want) {
+		t.Errorf("csv row %v, want %v", rows[2], want)
+	}
+
+	buf.Reset()
+	if err := newTextIndex().WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.TrimSpace(buf.String()); s != "[]" {
+		t.Errorf("json of an empty index = %s", s)
+	}
+}
+
+func TestTextsOfPausedChunks(t *testing.T) {
+	w := newCaptureWorld(t, filepath.Join(t.TempDir(), "test"))
+	stone := testRID(t, w, "minecraft:stone")
+	signAt := func(pos cube.Pos, text string) *world.Column {
+		col := newTestColumn(w, map[[3]int]uint32{{0, 0, 0}: stone})
+		col.BlockEntities = map[cube.Pos]world.Block{
+			pos: world.UnknownBlock{BlockState: world.BlockState{Name: "Sign", Properties: map[string]any{"id": "Sign", "Text": text}}},
+		}
+		return col
+	}
+
+	w.PauseCapture()
+	// one chunk next to the player and one far away
+	if err := w.StoreChunk(world.ChunkPos{0, 0}, signAt(cube.Pos{1, 64, 1}, "near")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.StoreChunk(world.ChunkPos{40, 0}, signAt(cube.Pos{641, 64, 1}, "far")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetBlockNBT(cube.Pos{2, 64, 2}, map[string]any{"id": "Sign", "Text": "placed"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if n := w.Texts.Len(); n != 0 {
+		t.Fatalf("%d texts indexed while paused", n)
+	}
+
+	w.UnpauseCapture(cube.Pos{0, 64, 0}, 4)
+	var got []string
+	for _, e := range w.Texts.Entries() {
+		got = append(got, e.Text)
+	}
+	// only the chunk that was applied is indexed, with the sign placed while paused
+	if want := []string{"near", "placed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("texts after unpausing %v, want %v", got, want)
+	}
+	if _, ok := w.memState.chunk(world.ChunkPos{0, 0}); !ok {
+		t.Error("applied chunk not in memory")
+	}
+}
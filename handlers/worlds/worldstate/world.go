@@ -13,6 +13,7 @@ import (
 	"github.com/bedrock-tool/bedrocktool/locale"
 	"github.com/bedrock-tool/bedrocktool/ui/messages"
 	"github.com/bedrock-tool/bedrocktool/utils"
+	"github.com/bedrock-tool/bedrocktool/utils/blockhistory"
 	"github.com/df-mc/dragonfly/server/block/cube"
 	"github.com/df-mc/dragonfly/server/world"
 	"github.com/df-mc/dragonfly/server/world/chunk"
@@ -35,6 +36,7 @@ type worldStateInterface interface {
 	StoreEntity(id entity.RuntimeID, es *entity.Entity)
 	GetEntity(id entity.RuntimeID) *entity.Entity
 	AddEntityLink(el protocol.EntityLink)
+	applyMap(m *Map)
 }
 
 type resourcePackDependency struct {
@@ -63,30 +65,56 @@ type World struct {
 	pausedState *worldStateMem
 	// access to states
 	stateLock sync.Mutex
+	// estimated bytes of chunks and entities to keep in memory, 0 for no limit
+	MemoryBudget int64
 
 	entityLock sync.Mutex
 
 	ResourcePacks            []resource.Pack
-	resourcePacksDone        chan error
+	resourcePacksDone        chan struct{}
+	resourcePacksErr         error
 	resourcePackDependencies []resourcePackDependency
 
 	// closed when this world is done
 	finish chan struct{}
-	err    error
+	// held while a checkpoint is written, Finish and Rename wait for it
+	checkpointLock    sync.Mutex
+	packsCheckpointed bool
+	err               error
 
 	players map[uuid.UUID]*player
 
-	VoidGen  bool
-	timeSync time.Time
-	time     int
-	Name     string
-	Folder   string
+	// only chunks and entities inside of bounds are kept, nil keeps everything
+	bounds     *CaptureBounds
+	boundsLock sync.Mutex
+	// entities the filter doesnt keep are dropped, nil keeps everything
+	EntityFilter *entity.Filter
+	// which chunks arrived fully
+	Coverage *Coverage
+	// text on signs and in books
+	Texts *TextIndex
+
+	// what gets generated outside of the captured chunks
+	Generator Generator
+	created   time.Time
+	stats     *Stats
+	timeSync  time.Time
+	time      int
+	Name      string
+	Folder    string
 
 	UseHashedRids    bool
 	blockUpdatesLock sync.Mutex
 	blockUpdates     map[world.ChunkPos][]blockUpdate
-	onChunkUpdate    func(pos world.ChunkPos, chunk *chunk.Chunk, isPaused bool)
-	IgnoredChunks    map[world.ChunkPos]bool
+	// keep every applied block update for the block history file
+	RecordHistory bool
+	// applied updates that are not written to the history file yet
+	history        []historyEntry
+	historyStarted bool
+	onChunkUpdate  func(pos world.ChunkPos, chunk *chunk.Chunk, isPaused bool)
+	IgnoredChunks  map[world.ChunkPos]bool
+	// chunks entities were stored in at the last checkpoint
+	entityChunks map[world.ChunkPos]struct{}
 
 	log *logrus.Entry
 }
@@ -95,6 +123,14 @@ type blockUpdate struct {
 	rid   uint32
 	pos   protocol.BlockPos
 	layer uint8
+	time  time.Time
+}
+
+type historyEntry struct {
+	time     time.Time
+	pos      protocol.BlockPos
+	layer    uint8
+	from, to uint32
 }
 
 type Map struct {
@@ -118,18 +154,15 @@ func New(dimensionDefinitions map[int]protocol.DimensionDefinition, onChunkUpdat
 		StoredChunks:         make(map[world.ChunkPos]bool),
 		dimensionDefinitions: dimensionDefinitions,
 		finish:               make(chan struct{}),
-		memState: &worldStateMem{
-			chunks:      make(map[world.ChunkPos]*world.Column),
-			entities:    make(map[entity.RuntimeID]*entity.Entity),
-			entityLinks: make(map[entity.UniqueID]map[entity.UniqueID]struct{}),
-
-			uniqueIDsToRuntimeIDs: make(map[int64]uint64),
-		},
-		players:       make(map[uuid.UUID]*player),
-		blockUpdates:  make(map[world.ChunkPos][]blockUpdate),
-		onChunkUpdate: onChunkUpdate,
-		IgnoredChunks: make(map[world.ChunkPos]bool),
-		log:           logrus.WithFields(logrus.Fields{"part": "world"}),
+		created:              time.Now(),
+		memState:             newWorldStateMem("mem"),
+		players:              make(map[uuid.UUID]*player),
+		blockUpdates:         make(map[world.ChunkPos][]blockUpdate),
+		Coverage:             newCoverage(),
+		Texts:                newTextIndex(),
+		onChunkUpdate:        onChunkUpdate,
+		IgnoredChunks:        make(map[world.ChunkPos]bool),
+		log:                  logrus.WithFields(logrus.Fields{"part": "world"}),
 	}
 
 	return w, nil
@@ -148,6 +181,8 @@ func (w *World) storeMemToProvider() error {
 		return nil
 	}
 	if w.provider == nil {
+		// never remove a capture that crashed
+		w.Folder = freeFolder(w.Folder)
 		w.log.Debugf("Opening provider in %s", w.Folder)
 		utils.RemoveTree(w.Folder)
 		os.MkdirAll(w.Folder, 0o777)
@@ -161,15 +196,18 @@ func (w *World) storeMemToProvider() error {
 			return w.err
 		}
 
-		w.resourcePacksDone = make(chan error)
+		w.resourcePacksDone = make(chan struct{})
 		go func() {
 			defer close(w.resourcePacksDone)
-			err := w.addResourcePacks()
-			if err != nil {
-				w.resourcePacksDone <- err
-			}
+			w.resourcePacksErr = w.addResourcePacks()
 		}()
 	}
+	custom := w.customRange()
+	if custom {
+		if err := w.openSpill(w.memState); err != nil {
+			return err
+		}
+	}
 	for pos, col := range w.memState.chunks {
 		// dont put empty chunks in the world db, keep them in memory
 		empty := true
@@ -187,7 +225,15 @@ func (w *World) storeMemToProvider() error {
 		if err != nil {
 			w.log.Error("StoreColumn", err)
 		}
+		if custom {
+			// mcdb reads columns back with the builtin height, the spill store reads them with the custom one
+			if err := w.memState.spillChunk(pos); err != nil {
+				w.log.WithError(err).Error("failed to spill to disk")
+			}
+			continue
+		}
 		delete(w.memState.chunks, pos)
+		delete(w.memState.chunkUse, pos)
 	}
 	return nil
 }
@@ -197,17 +243,30 @@ func (w *World) Dimension() world.Dimension {
 }
 
 func (w *World) SetDimension(dim world.Dimension) {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
 	w.dimension = dim
 
 	w.dimRange = dim.Range()
 	id, _ := world.DimensionID(dim)
 	if d, ok := w.dimensionDefinitions[id]; ok {
-		w.dimRange = cube.Range{
-			int(d.Range[1]), int(d.Range[0]) - 1,
-		}
+		w.dimRange = DimensionRange(d)
 	}
 }
 
+// SetBounds changes the area that is captured, entities are checked against it when they are saved
+func (w *World) SetBounds(b *CaptureBounds) {
+	w.boundsLock.Lock()
+	defer w.boundsLock.Unlock()
+	w.bounds = b
+}
+
+func (w *World) Bounds() *CaptureBounds {
+	w.boundsLock.Lock()
+	defer w.boundsLock.Unlock()
+	return w.bounds
+}
+
 func (w *World) Range() cube.Range {
 	return w.dimRange
 }
@@ -224,6 +283,10 @@ func (w *World) StoreChunk(pos world.ChunkPos, col *world.Column) (err error) {
 }
 
 func (w *World) storeChunkLocked(pos world.ChunkPos, col *world.Column) (err error) {
+	if !w.Bounds().ContainsChunk(pos) {
+		return nil
+	}
+
 	var empty = true
 	for _, sub := range col.Chunk.Sub() {
 		if !sub.Empty() {
@@ -235,28 +298,55 @@ func (w *World) storeChunkLocked(pos world.ChunkPos, col *world.Column) (err err
 		w.StoredChunks[pos] = true
 		w.onChunkUpdate(pos, col.Chunk, w.paused)
 		// only start saving once a non empty chunk is received
-		w.onceOpen.Do(func() {
-			go func() {
-				t := time.NewTicker(10 * time.Second)
-				for {
-					select {
-					case <-w.finish:
-						return
-					case <-t.C:
-						w.stateLock.Lock()
-						w.applyBlockUpdates()
-						w.storeMemToProvider()
-						w.stateLock.Unlock()
-					}
-				}
-			}()
-		})
+		w.startFlushing()
+	}
+
+	// text of paused areas is indexed once they are applied, like their blocks
+	if !w.paused {
+		for bp, be := range col.BlockEntities {
+			w.Texts.Update(w.dimension, bp, blockEntityNBT(be))
+		}
 	}
 
 	w.currState().StoreChunk(pos, col)
 	return nil
 }
 
+// lockedWorld stores the chunks of the paused state in a world whose stateLock is already held
+type lockedWorld struct {
+	*World
+}
+
+func (w lockedWorld) StoreChunk(pos world.ChunkPos, col *world.Column) error {
+	return w.storeChunkLocked(pos, col)
+}
+
+// startFlushing starts moving chunks from memory to the provider every 10 seconds
+func (w *World) startFlushing() {
+	w.onceOpen.Do(func() {
+		go func() {
+			t := time.NewTicker(10 * time.Second)
+			for {
+				select {
+				case <-w.finish:
+					return
+				case <-t.C:
+					w.stateLock.Lock()
+					w.applyBlockUpdates()
+					if err := w.flushHistory(); err != nil {
+						w.log.WithError(err).Warn("failed to write block history")
+					}
+					w.storeMemToProvider()
+					w.entityLock.Lock()
+					w.enforceMemoryBudget()
+					w.entityLock.Unlock()
+					w.stateLock.Unlock()
+				}
+			}
+		}()
+	})
+}
+
 func (w *World) LoadChunk(pos world.ChunkPos) (*world.Column, bool, error) {
 	w.stateLock.Lock()
 	defer w.stateLock.Unlock()
@@ -265,11 +355,11 @@ func (w *World) LoadChunk(pos world.ChunkPos) (*world.Column, bool, error) {
 
 func (w *World) loadChunkLocked(pos world.ChunkPos) (*world.Column, bool, error) {
 	if w.paused {
-		if col, ok := w.pausedState.chunks[pos]; ok {
+		if col, ok := w.pausedState.chunk(pos); ok {
 			return col, true, nil
 		}
 	}
-	if col, ok := w.memState.chunks[pos]; ok {
+	if col, ok := w.memState.chunk(pos); ok {
 		return col, true, nil
 	}
 
@@ -288,11 +378,11 @@ func (w *World) loadChunkLocked(pos world.ChunkPos) (*world.Column, bool, error)
 	return nil, false, nil
 }
 
-func (w *World) QueueBlockUpdate(pos protocol.BlockPos, ridTo uint32, layer uint8) {
+func (w *World) QueueBlockUpdate(pos protocol.BlockPos, ridTo uint32, layer uint8, t time.Time) {
 	cp := world.ChunkPos{pos.X() >> 4, pos.Z() >> 4}
 	w.blockUpdatesLock.Lock()
 	defer w.blockUpdatesLock.Unlock()
-	w.blockUpdates[cp] = append(w.blockUpdates[cp], blockUpdate{rid: ridTo, pos: pos, layer: layer})
+	w.blockUpdates[cp] = append(w.blockUpdates[cp], blockUpdate{rid: ridTo, pos: pos, layer: layer, time: t})
 }
 
 func (w *World) SetBlockNBT(pos cube.Pos, nbt map[string]any, merge bool) error {
@@ -310,6 +400,9 @@ func (w *World) SetBlockNBT(pos cube.Pos, nbt map[string]any, merge bool) error
 		if prev, ok := col.BlockEntities[pos].(world.UnknownBlock); ok {
 			prev.Name = nbt["id"].(string)
 			maps.Copy(prev.Properties, nbt)
+			if !w.paused {
+				w.Texts.Update(w.dimension, pos, prev.Properties)
+			}
 			return nil
 		}
 	}
@@ -319,10 +412,17 @@ func (w *World) SetBlockNBT(pos cube.Pos, nbt map[string]any, merge bool) error
 			Properties: nbt,
 		},
 	}
+	if !w.paused {
+		w.Texts.Update(w.dimension, pos, nbt)
+	}
 	return nil
 }
 
 func (w *World) StoreEntity(id entity.RuntimeID, es *entity.Entity) {
+	// bounds are checked when saving, the entity might still walk into them
+	if !w.EntityFilter.Keep(es) {
+		return
+	}
 	w.entityLock.Lock()
 	defer w.entityLock.Unlock()
 	w.currState().StoreEntity(id, es)
@@ -331,28 +431,89 @@ func (w *World) StoreEntity(id entity.RuntimeID, es *entity.Entity) {
 func (w *World) StoreMap(m *packet.ClientBoundMapItemData) {
 	w.entityLock.Lock()
 	defer w.entityLock.Unlock()
-	w.currState().StoreMap(m)
+	state := w.currState()
+	if w.paused {
+		// updates to a map that was captured before start from the captured one
+		if _, ok := state.maps[m.MapID]; !ok {
+			if m1, ok := w.memState.maps[m.MapID]; ok {
+				state.maps[m.MapID] = m1.clone()
+			}
+		}
+	}
+	state.StoreMap(m)
+}
+
+// applyMap keeps a map of the paused state
+func (w *World) applyMap(m *Map) {
+	w.entityLock.Lock()
+	defer w.entityLock.Unlock()
+	w.memState.maps[m.MapID] = m
 }
 
+// GetEntityUniqueID returns an entity for reading, use UpdateEntityUniqueID to change it
 func (w *World) GetEntityUniqueID(id entity.UniqueID) *entity.Entity {
-	rid := w.currState().uniqueIDsToRuntimeIDs[id]
-	return w.GetEntity(rid)
+	w.entityLock.Lock()
+	defer w.entityLock.Unlock()
+	return w.getEntity(w.currState().uniqueIDsToRuntimeIDs[id])
 }
 
+// GetEntity returns an entity for reading, use UpdateEntity to change it
 func (w *World) GetEntity(id entity.RuntimeID) *entity.Entity {
 	w.entityLock.Lock()
 	defer w.entityLock.Unlock()
+	return w.getEntity(id)
+}
+
+// UpdateEntity calls f with the entity while holding the entity lock,
+// so it cant be spilled to disk halfway through a change. returns false if there is no such entity
+func (w *World) UpdateEntity(id entity.RuntimeID, f func(es *entity.Entity)) bool {
+	w.entityLock.Lock()
+	defer w.entityLock.Unlock()
+	es := w.getEntity(id)
+	if es == nil {
+		return false
+	}
+	f(es)
+	return true
+}
+
+func (w *World) UpdateEntityUniqueID(id entity.UniqueID, f func(es *entity.Entity)) bool {
+	w.entityLock.Lock()
+	defer w.entityLock.Unlock()
+	es := w.getEntity(w.currState().uniqueIDsToRuntimeIDs[id])
+	if es == nil {
+		return false
+	}
+	f(es)
+	return true
+}
+
+func (w *World) getEntity(id entity.RuntimeID) *entity.Entity {
 	if w.paused {
 		es := w.pausedState.GetEntity(id)
 		if es != nil {
 			return es
 		}
 	}
-	return w.memState.entities[id]
+	return w.memState.GetEntity(id)
+}
+
+// RangeEntities calls f for every entity in the current state
+func (w *World) RangeEntities(f func(es *entity.Entity)) {
+	w.entityLock.Lock()
+	defer w.entityLock.Unlock()
+	w.memState.rangeEntities(func(_ entity.RuntimeID, es *entity.Entity) {
+		f(es)
+	})
+	if w.paused {
+		w.pausedState.rangeEntities(func(_ entity.RuntimeID, es *entity.Entity) {
+			f(es)
+		})
+	}
 }
 
 func (w *World) EntityCount() int {
-	return len(w.memState.entities)
+	return w.memState.entityCount()
 }
 
 func (w *World) AddEntityLink(el protocol.EntityLink) {
@@ -362,16 +523,12 @@ func (w *World) AddEntityLink(el protocol.EntityLink) {
 }
 
 func (w *World) PauseCapture() {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
 	w.entityLock.Lock()
+	defer w.entityLock.Unlock()
 	w.paused = true
-	w.pausedState = &worldStateMem{
-		chunks:      make(map[world.ChunkPos]*world.Column),
-		entities:    make(map[entity.RuntimeID]*entity.Entity),
-		entityLinks: make(map[entity.UniqueID]map[entity.UniqueID]struct{}),
-
-		uniqueIDsToRuntimeIDs: make(map[int64]uint64),
-	}
-	w.stateLock.Unlock()
+	w.pausedState = newWorldStateMem("paused")
 }
 
 func (w *World) UnpauseCapture(around cube.Pos, radius int32) {
@@ -380,11 +537,14 @@ func (w *World) UnpauseCapture(around cube.Pos, radius int32) {
 	if !w.paused {
 		panic("attempt to unpause when not paused")
 	}
-	w.pausedState.ApplyTo(w, around, radius, func(pos world.ChunkPos, ch *chunk.Chunk) {
+	// the applied chunks go into memState
+	pausedState := w.pausedState
+	w.paused = false
+	pausedState.ApplyTo(lockedWorld{w}, around, radius, func(pos world.ChunkPos, ch *chunk.Chunk) {
 		w.onChunkUpdate(pos, ch, false)
 	})
+	pausedState.closeSpill()
 	w.pausedState = nil
-	w.paused = false
 }
 
 func (w *World) IsPaused() bool {
@@ -395,7 +555,7 @@ func (w *World) Open(name string, folder string, deferred bool) {
 	w.stateLock.Lock()
 	defer w.stateLock.Unlock()
 	w.Name = name
-	w.Folder = folder
+	w.Folder = freeFolder(folder)
 
 	if w.opened {
 		panic("trying to open already opened world")
@@ -403,8 +563,11 @@ func (w *World) Open(name string, folder string, deferred bool) {
 	w.opened = true
 
 	if w.paused && !deferred {
-		w.pausedState.ApplyTo(w, cube.Pos{}, -1, w.ChunkFunc)
+		pausedState := w.pausedState
 		w.paused = false
+		pausedState.ApplyTo(lockedWorld{w}, cube.Pos{}, -1, w.ChunkFunc)
+		pausedState.closeSpill()
+		w.pausedState = nil
 	}
 }
 
@@ -424,6 +587,27 @@ func (w *World) BlockByID(rid uint32) (runtimeID uint32, name string, properties
 	return rid, name, properties, found
 }
 
+// BlockName returns the name of the block at pos if its chunk is loaded
+func (w *World) BlockName(pos cube.Pos) (name string, found bool) {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+	chunkPos, _ := cubePosInChunk(pos)
+	col, ok, err := w.loadChunkLocked(chunkPos)
+	if err != nil || !ok {
+		return "", false
+	}
+	rid := col.Chunk.Block(uint8(pos.X()&15), int16(pos.Y()), uint8(pos.Z()&15), 0)
+	name, _, found = w.BlockRegistry.RuntimeIDToState(rid)
+	return name, found
+}
+
+// ApplyBlockUpdates applies all queued block updates to the stored chunks
+func (w *World) ApplyBlockUpdates() {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+	w.applyBlockUpdates()
+}
+
 func (w *World) applyBlockUpdates() {
 	w.blockUpdatesLock.Lock()
 	defer w.blockUpdatesLock.Unlock()
@@ -441,6 +625,18 @@ func (w *World) applyBlockUpdates() {
 
 		for _, update := range updates {
 			x, y, z := blockPosInChunk(update.pos)
+			if w.RecordHistory {
+				from := col.Chunk.Block(x, y, z, update.layer)
+				if from != update.rid {
+					w.history = append(w.history, historyEntry{
+						time:  update.time,
+						pos:   update.pos,
+						layer: update.layer,
+						from:  from,
+						to:    update.rid,
+					})
+				}
+			}
 			col.Chunk.SetBlock(x, y, z, update.layer, update.rid)
 		}
 		err = w.storeChunkLocked(pos, col)
@@ -451,12 +647,64 @@ func (w *World) applyBlockUpdates() {
 	}
 }
 
+// flushHistory appends the recorded block updates to the history files next to the world folder
+func (w *World) flushHistory() error {
+	if len(w.history) == 0 || w.Folder == "" {
+		return nil
+	}
+	dir := blockhistory.Dir(w.Folder)
+	if !w.historyStarted {
+		// dont append to the history of an older capture with the same name
+		err := os.RemoveAll(dir)
+		if err != nil {
+			return err
+		}
+	}
+	err := blockhistory.Append(dir, w.historyEntries())
+	if err != nil {
+		return err
+	}
+	w.historyStarted = true
+	w.history = w.history[:0]
+	return nil
+}
+
+func (w *World) historyEntries() []blockhistory.Entry {
+	state := func(rid uint32) blockhistory.BlockState {
+		name, properties, _ := w.BlockRegistry.RuntimeIDToState(rid)
+		return blockhistory.BlockState{Name: name, States: properties}
+	}
+	dimID, _ := world.DimensionID(w.dimension)
+	entries := make([]blockhistory.Entry, 0, len(w.history))
+	for _, h := range w.history {
+		entries = append(entries, blockhistory.Entry{
+			Time:      h.time.UnixMilli(),
+			Dimension: int32(dimID),
+			Pos:       [3]int32{h.pos.X(), h.pos.Y(), h.pos.Z()},
+			Layer:     h.layer,
+			From:      state(h.from),
+			To:        state(h.to),
+		})
+	}
+	return entries
+}
+
 // Rename moves the folder and reopens it
 func (w *World) Rename(name, folder string) error {
+	w.checkpointLock.Lock()
+	defer w.checkpointLock.Unlock()
 	w.stateLock.Lock()
 	defer w.stateLock.Unlock()
 
+	folder = freeFolder(folder)
 	os.RemoveAll(folder)
+	if w.historyStarted {
+		os.RemoveAll(blockhistory.Dir(folder))
+		err := os.Rename(blockhistory.Dir(w.Folder), blockhistory.Dir(folder))
+		if err != nil {
+			return err
+		}
+	}
 	if w.provider != nil {
 		err := w.provider.Close()
 		if err != nil {
@@ -481,7 +729,9 @@ func (w *World) Rename(name, folder string) error {
 	return nil
 }
 
-func (w *World) Finish(playerData map[string]any, excludedMobs []string, withPlayers bool, spawn cube.Pos, gd minecraft.GameData, experimental bool) error {
+func (w *World) Finish(playerData map[string]any, withPlayers bool, spawn cube.Pos, gd minecraft.GameData, experimental bool) error {
+	w.checkpointLock.Lock()
+	defer w.checkpointLock.Unlock()
 	w.stateLock.Lock()
 	defer w.stateLock.Unlock()
 	close(w.finish)
@@ -512,6 +762,11 @@ func (w *World) Finish(playerData map[string]any, excludedMobs []string, withPla
 		return err
 	}
 
+	err = w.flushHistory()
+	if err != nil {
+		return err
+	}
+
 	messages.Router.Handle(&messages.Message{
 		Source: "subcommand",
 		Target: "ui",
@@ -521,24 +776,50 @@ func (w *World) Finish(playerData map[string]any, excludedMobs []string, withPla
 		},
 	})
 
+	entityCounts := w.storeEntities()
+	w.memState.closeSpill()
+	if w.pausedState != nil {
+		w.pausedState.closeSpill()
+	}
+
+	err = w.provider.SaveLocalPlayerData(playerData)
+	if err != nil {
+		return err
+	}
+
+	err = w.storeMaps()
+	if err != nil {
+		return err
+	}
+
+	w.stats, err = w.collectStats(entityCounts)
+	if err != nil {
+		w.log.WithError(err).Warn("failed to collect world stats")
+	}
+
+	w.writeMetadata(spawn, gd, experimental)
+	os.Remove(path.Join(w.Folder, checkpointMarker))
+	return w.provider.Close()
+}
+
+// storeEntities writes entities to the provider and returns how many of each type were stored
+func (w *World) storeEntities() map[string]int {
+	counts := make(map[string]int)
 	chunkEntities := make(map[world.ChunkPos][]world.Entity)
-	for _, entityState := range w.memState.entities {
-		var ignore bool
-		for _, ex := range excludedMobs {
-			if ok, err := path.Match(ex, entityState.EntityType); ok {
-				w.log.Debugf("Excluding: %s %v", entityState.EntityType, entityState.Position)
-				ignore = true
-				break
-			} else if err != nil {
-				w.log.Warn(err)
-			}
+	bounds := w.Bounds()
+	w.memState.rangeEntities(func(_ entity.RuntimeID, entityState *entity.Entity) {
+		var ignore = !bounds.ContainsPos(entityState.Position.X(), entityState.Position.Z())
+		if !w.EntityFilter.Keep(entityState) {
+			w.log.Debugf("Excluding: %s %v", entityState.EntityType, entityState.Position)
+			ignore = true
 		}
 		if !ignore {
 			cp := world.ChunkPos{int32(entityState.Position.X()) >> 4, int32(entityState.Position.Z()) >> 4}
 			links := maps.Keys(w.memState.entityLinks[entityState.UniqueID])
 			chunkEntities[cp] = append(chunkEntities[cp], entityState.ToServerEntity(links))
+			counts[entityState.EntityType]++
 		}
-	}
+	})
 
 	for cp, v := range chunkEntities {
 		err := w.provider.StoreEntities(cp, w.dimension, v)
@@ -547,11 +828,28 @@ func (w *World) Finish(playerData map[string]any, excludedMobs []string, withPla
 		}
 	}
 
-	err = w.provider.SaveLocalPlayerData(playerData)
-	if err != nil {
-		return err
+	// clear chunks that had entities at the last checkpoint
+	for cp := range w.entityChunks {
+		if _, ok := chunkEntities[cp]; !ok {
+			err := w.provider.StoreEntities(cp, w.dimension, nil)
+			if err != nil {
+				w.log.Error(err)
+			}
+		}
+	}
+	w.entityChunks = make(map[world.ChunkPos]struct{}, len(chunkEntities))
+	for cp := range chunkEntities {
+		w.entityChunks[cp] = struct{}{}
 	}
+	return counts
+}
+
+// Stats returns the stats collected when the world was finished
+func (w *World) Stats() *Stats {
+	return w.stats
+}
 
+func (w *World) storeMaps() error {
 	ldb := w.provider.LDB()
 	for id, m := range w.memState.maps {
 		d, err := nbt.MarshalEncoding(m, nbt.LittleEndian)
@@ -564,6 +862,10 @@ func (w *World) Finish(playerData map[string]any, excludedMobs []string, withPla
 		}
 	}
 
+	return nil
+}
+
+func (w *World) writeMetadata(spawn cube.Pos, gd minecraft.GameData, experimental bool) {
 	// write metadata
 	s := w.provider.Settings()
 	s.Spawn = spawn
@@ -654,10 +956,10 @@ func (w *World) Finish(playerData map[string]any, excludedMobs []string, withPla
 		}
 	}
 
-	// void world
-	if w.VoidGen {
-		ld.FlatWorldLayers = `{"biome_id":1,"block_layers":[{"block_data":0,"block_id":0,"count":1},{"block_data":0,"block_id":0,"count":2},{"block_data":0,"block_id":0,"count":1}],"encoding_version":3,"structure_options":null}`
-		ld.Generator = 2
+	generator, flatLayers := w.Generator.levelDat()
+	ld.Generator = generator
+	if flatLayers != "" {
+		ld.FlatWorldLayers = flatLayers
 	}
 
 	ld.RandomTickSpeed = 0
@@ -680,5 +982,4 @@ func (w *World) Finish(playerData map[string]any, excludedMobs []string, withPla
 	}
 
 	w.provider.SaveSettings(s)
-	return w.provider.Close()
 }
@@ -1,61 +1,166 @@
 package worldstate
 
 import (
-	"image"
-	"image/draw"
+	"slices"
+	"sync/atomic"
 
 	"github.com/bedrock-tool/bedrocktool/handlers/worlds/entity"
-	"github.com/bedrock-tool/bedrocktool/utils"
 	"github.com/df-mc/dragonfly/server/block/cube"
 	"github.com/df-mc/dragonfly/server/world"
 	"github.com/df-mc/dragonfly/server/world/chunk"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
 	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"github.com/sirupsen/logrus"
 	"github.com/thomaso-mirodin/intmath/i32"
 )
 
 type worldStateMem struct {
+	name        string
 	maps        map[int64]*Map
 	chunks      map[world.ChunkPos]*world.Column
 	entities    map[entity.RuntimeID]*entity.Entity
 	entityLinks map[entity.UniqueID]map[entity.UniqueID]struct{}
 
 	uniqueIDsToRuntimeIDs map[entity.UniqueID]entity.RuntimeID
+
+	// chunks and entities that were moved to disk to stay in the memory budget
+	spill           *spillStore
+	spilledChunks   map[world.ChunkPos]struct{}
+	spilledEntities map[entity.RuntimeID]struct{}
+	// when things were last used, to find what to spill first
+	chunkUse  map[world.ChunkPos]uint64
+	entityUse map[entity.RuntimeID]uint64
+}
+
+// useCounter orders uses of chunks and entities across states
+var useCounter atomic.Uint64
+
+func newWorldStateMem(name string) *worldStateMem {
+	return &worldStateMem{
+		name:        name,
+		maps:        make(map[int64]*Map),
+		chunks:      make(map[world.ChunkPos]*world.Column),
+		entities:    make(map[entity.RuntimeID]*entity.Entity),
+		entityLinks: make(map[entity.UniqueID]map[entity.UniqueID]struct{}),
+
+		uniqueIDsToRuntimeIDs: make(map[int64]uint64),
+
+		spilledChunks:   make(map[world.ChunkPos]struct{}),
+		spilledEntities: make(map[entity.RuntimeID]struct{}),
+		chunkUse:        make(map[world.ChunkPos]uint64),
+		entityUse:       make(map[entity.RuntimeID]uint64),
+	}
 }
 
 func (w *worldStateMem) StoreChunk(pos world.ChunkPos, col *world.Column) {
 	w.chunks[pos] = col
+	delete(w.spilledChunks, pos)
+	w.chunkUse[pos] = useCounter.Add(1)
+}
+
+// chunk returns a chunk of this state, loading it back from disk if it was spilled
+func (w *worldStateMem) chunk(pos world.ChunkPos) (*world.Column, bool) {
+	if col, ok := w.chunks[pos]; ok {
+		w.chunkUse[pos] = useCounter.Add(1)
+		return col, true
+	}
+	if _, ok := w.spilledChunks[pos]; !ok {
+		return nil, false
+	}
+	col, err := w.spill.loadColumn(pos)
+	if err != nil {
+		logrus.WithError(err).Error("failed to load spilled chunk")
+		return nil, false
+	}
+	w.StoreChunk(pos, col)
+	return col, true
+}
+
+// rangeChunks calls f for all chunks, spilled chunks are loaded without keeping them in memory
+func (w *worldStateMem) rangeChunks(f func(pos world.ChunkPos, col *world.Column)) {
+	for pos, col := range w.chunks {
+		f(pos, col)
+	}
+	for pos := range w.spilledChunks {
+		col, err := w.spill.loadColumn(pos)
+		if err != nil {
+			logrus.WithError(err).Error("failed to load spilled chunk")
+			continue
+		}
+		f(pos, col)
+	}
+}
+
+func (m *Map) clone() *Map {
+	m2 := *m
+	m2.Decorations = slices.Clone(m.Decorations)
+	return &m2
 }
 
 func (w *worldStateMem) StoreMap(m *packet.ClientBoundMapItemData) {
-	return // not finished yet
 	m1, ok := w.maps[m.MapID]
 	if !ok {
 		m1 = &Map{
-			MapID:     m.MapID,
-			Height:    128,
-			Width:     128,
-			Scale:     1,
-			Dimension: 0,
-			ZCenter:   m.Origin.Z(),
-			XCenter:   m.Origin.X(),
+			MapID:       m.MapID,
+			ParentMapId: -1,
+			Height:      128,
+			Width:       128,
+			Decorations: []any{},
 		}
 		w.maps[m.MapID] = m1
 	}
-	draw.Draw(&image.RGBA{
-		Pix:    m1.Colors[:],
-		Rect:   image.Rect(0, 0, int(m.Width), int(m.Height)),
-		Stride: int(m.Width) * 4,
-	}, image.Rect(
-		int(m.XOffset), int(m.YOffset),
-		int(m.Width), int(m.Height),
-	), utils.RGBA2Img(
-		m.Pixels,
-		image.Rect(
-			0, 0,
-			int(m.Width), int(m.Height),
-		),
-	), image.Point{}, draw.Over)
+
+	if m.UpdateFlags&packet.MapUpdateFlagInitialisation != 0 || !ok {
+		m1.Dimension = m.Dimension
+		m1.Scale = m.Scale
+		m1.MapLocked = m.LockedMap
+		m1.XCenter = m.Origin.X()
+		m1.ZCenter = m.Origin.Z()
+	}
+
+	if m.UpdateFlags&packet.MapUpdateFlagDecoration != 0 {
+		m1.Decorations = m1.Decorations[:0]
+		for _, d := range m.Decorations {
+			// players move around, dont keep them
+			if d.Type == 0 || d.Type == 6 {
+				continue
+			}
+			// decoration positions are in half pixels from the center
+			scale := int32(1) << m1.Scale
+			m1.Decorations = append(m1.Decorations, map[string]any{
+				"data": map[string]any{
+					"rot":  int32(d.Rotation),
+					"type": int32(d.Type),
+					"x":    int32(int8(d.X)),
+					"y":    int32(int8(d.Y)),
+				},
+				"key": map[string]any{
+					"blockX": m1.XCenter + int32(int8(d.X))*scale/2,
+					"blockY": int32(64),
+					"blockZ": m1.ZCenter + int32(int8(d.Y))*scale/2,
+					"type":   int32(1),
+				},
+			})
+		}
+	}
+
+	if m.UpdateFlags&packet.MapUpdateFlagTexture != 0 {
+		for y := int32(0); y < m.Height; y++ {
+			for x := int32(0); x < m.Width; x++ {
+				i := int(y*m.Width + x)
+				if i >= len(m.Pixels) {
+					return
+				}
+				px, py := m.XOffset+x, m.YOffset+y
+				if px < 0 || py < 0 || px >= 128 || py >= 128 {
+					continue
+				}
+				c := nearestMapColor(m.Pixels[i])
+				off := (py*128 + px) * 4
+				m1.Colors[off], m1.Colors[off+1], m1.Colors[off+2], m1.Colors[off+3] = c.R, c.G, c.B, c.A
+			}
+		}
+	}
 }
 
 func (w *worldStateMem) cullChunks() {
@@ -75,7 +180,7 @@ func (w *worldStateMem) cullChunks() {
 
 func (w *worldStateMem) ApplyTo(w2 worldStateInterface, around cube.Pos, radius int32, cf func(world.ChunkPos, *chunk.Chunk)) {
 	w.cullChunks()
-	for pos, col := range w.chunks {
+	w.rangeChunks(func(pos world.ChunkPos, col *world.Column) {
 		dist := i32.Sqrt(i32.Pow(pos.X()-int32(around.X()/16), 2) + i32.Pow(pos.Z()-int32(around.Z()/16), 2))
 		if dist <= radius || radius < 0 {
 			w2.StoreChunk(pos, col)
@@ -83,9 +188,9 @@ func (w *worldStateMem) ApplyTo(w2 worldStateInterface, around cube.Pos, radius
 		} else {
 			cf(pos, nil)
 		}
-	}
+	})
 
-	for k, es := range w.entities {
+	w.rangeEntities(func(k entity.RuntimeID, es *entity.Entity) {
 		x := int(es.Position[0])
 		z := int(es.Position[2])
 		dist := i32.Sqrt(i32.Pow(int32(x-around.X()), 2) + i32.Pow(int32(z-around.Z()), 2))
@@ -93,6 +198,14 @@ func (w *worldStateMem) ApplyTo(w2 worldStateInterface, around cube.Pos, radius
 		if e2 != nil || dist < radius*16 || radius < 0 {
 			w2.StoreEntity(k, es)
 		}
+	})
+
+	// maps are kept like the area around their center
+	for _, m := range w.maps {
+		dx, dz := int64(m.XCenter)-int64(around.X()), int64(m.ZCenter)-int64(around.Z())
+		if dx*dx+dz*dz < int64(radius*16)*int64(radius*16) || radius < 0 {
+			w2.applyMap(m)
+		}
 	}
 }
 
@@ -106,10 +219,45 @@ func cubePosInChunk(pos cube.Pos) (p world.ChunkPos, sp int16) {
 func (w *worldStateMem) StoreEntity(id entity.RuntimeID, es *entity.Entity) {
 	w.entities[id] = es
 	w.uniqueIDsToRuntimeIDs[es.UniqueID] = es.RuntimeID
+	delete(w.spilledEntities, id)
+	w.entityUse[id] = useCounter.Add(1)
 }
 
+// GetEntity returns an entity of this state, loading it back from disk if it was spilled
 func (w *worldStateMem) GetEntity(id entity.RuntimeID) *entity.Entity {
-	return w.entities[id]
+	if es, ok := w.entities[id]; ok {
+		w.entityUse[id] = useCounter.Add(1)
+		return es
+	}
+	if _, ok := w.spilledEntities[id]; !ok {
+		return nil
+	}
+	es, err := w.spill.loadEntity(w.name, id)
+	if err != nil {
+		logrus.WithError(err).Error("failed to load spilled entity")
+		return nil
+	}
+	w.StoreEntity(id, es)
+	return es
+}
+
+// rangeEntities calls f for all entities, spilled entities are loaded without keeping them in memory
+func (w *worldStateMem) rangeEntities(f func(id entity.RuntimeID, es *entity.Entity)) {
+	for id, es := range w.entities {
+		f(id, es)
+	}
+	for id := range w.spilledEntities {
+		es, err := w.spill.loadEntity(w.name, id)
+		if err != nil {
+			logrus.WithError(err).Error("failed to load spilled entity")
+			continue
+		}
+		f(id, es)
+	}
+}
+
+func (w *worldStateMem) entityCount() int {
+	return len(w.entities) + len(w.spilledEntities)
 }
 
 func (w *worldStateMem) AddEntityLink(el protocol.EntityLink) {
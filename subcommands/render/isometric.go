@@ -0,0 +1,186 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bedrock-tool/bedrocktool/utils"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/resource"
+	"github.com/sirupsen/logrus"
+)
+
+// isometric images get big fast, worlds bigger than this many blocks of area need -area
+const isometricMaxWorldArea = 512 * 512
+
+// parseArea reads x1,z1,x2,z2 in blocks
+func parseArea(s string) (minX, minZ, maxX, maxZ int, err error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return 0, 0, 0, 0, fmt.Errorf("area %q should be x1,z1,x2,z2", s)
+	}
+	var v [4]int
+	for i, p := range parts {
+		v[i], err = strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return 0, 0, 0, 0, fmt.Errorf("area %q: %w", s, err)
+		}
+	}
+	return min(v[0], v[2]), min(v[1], v[3]), max(v[0], v[2]), max(v[1], v[3]), nil
+}
+
+// worldArea is the area of all chunks in the world in blocks
+func worldArea(db *mcdb.DB) (minX, minZ, maxX, maxZ int, err error) {
+	boundsMin := world.ChunkPos{math.MaxInt32, math.MaxInt32}
+	boundsMax := world.ChunkPos{math.MinInt32, math.MinInt32}
+	it := db.NewColumnIterator(nil)
+	for it.Next() {
+		pos := it.Position()
+		boundsMin[0] = min(boundsMin[0], pos[0])
+		boundsMin[1] = min(boundsMin[1], pos[1])
+		boundsMax[0] = max(boundsMax[0], pos[0])
+		boundsMax[1] = max(boundsMax[1], pos[1])
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return 0, 0, 0, 0, err
+	}
+	if boundsMin[0] > boundsMax[0] {
+		return 0, 0, 0, 0, errors.New("the world has no chunks")
+	}
+	return int(boundsMin[0]) * 16, int(boundsMin[1]) * 16, int(boundsMax[0])*16 + 15, int(boundsMax[1])*16 + 15, nil
+}
+
+func (c *RenderCMD) writeIsometric(db *mcdb.DB, renderer *utils.ChunkRenderer, entries []protocol.BlockEntry, packs []resource.Pack) error {
+	if c.IsoScale < 1 || c.IsoScale > 64 {
+		return fmt.Errorf("-iso-scale has to be between 1 and 64")
+	}
+	dim, ok := world.DimensionByID(c.Dimension)
+	if !ok {
+		return fmt.Errorf("unknown dimension %d", c.Dimension)
+	}
+
+	var minX, minZ, maxX, maxZ int
+	var err error
+	if c.Area != "" {
+		minX, minZ, maxX, maxZ, err = parseArea(c.Area)
+	} else {
+		minX, minZ, maxX, maxZ, err = worldArea(db)
+	}
+	if err != nil {
+		return err
+	}
+	area := (maxX - minX + 1) * (maxZ - minZ + 1)
+	if c.Area == "" && area > isometricMaxWorldArea {
+		return fmt.Errorf("the world is %d blocks of area, pick a part of it with -area", area)
+	}
+
+	// only the height that has blocks, with some of the ground under the lowest surface
+	r := dim.Range()
+	top, bottom := r.Min(), r.Max()
+	found := false
+	err = forIsometricRegions(db, dim, minX, minZ, maxX, maxZ, 0, func(chunks map[world.ChunkPos]*chunk.Chunk, _, _ cube.Pos) {
+		for _, ch := range chunks {
+			found = true
+			hm := ch.HeightMapWithWater()
+			for x := uint8(0); x < 16; x++ {
+				for z := uint8(0); z < 16; z++ {
+					h := int(hm.At(x, z))
+					top = max(top, h)
+					bottom = min(bottom, h)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("no chunks in the area")
+	}
+	bottom = max(bottom-16, r.Min())
+
+	iso := utils.NewIsometricRenderer(renderer, entries, packs, c.IsoScale)
+	from, to := cube.Pos{minX, bottom, minZ}, cube.Pos{maxX, top, maxZ}
+	if size := iso.ImageSize(from, to); int64(size.X)*int64(size.Y) > maxImagePixels {
+		return fmt.Errorf("%dx%d pixels is too large, use a smaller -area or -iso-scale", size.X, size.Y)
+	}
+	cv := iso.NewCanvas(from, to)
+	// the chunks one south and east of a region are loaded with it so the faces between regions are hidden
+	err = forIsometricRegions(db, dim, minX, minZ, maxX, maxZ, 1, func(chunks map[world.ChunkPos]*chunk.Chunk, regionFrom, regionTo cube.Pos) {
+		iso.DrawPart(cv, chunks, regionFrom, regionTo)
+	})
+	if err != nil {
+		return err
+	}
+	img := cv.Image()
+	fmt.Printf("%dx%d pixels\n", img.Rect.Dx(), img.Rect.Dy())
+	if c.Background != "" {
+		bg, err := parseHexColor(c.Background)
+		if err != nil {
+			return err
+		}
+		utils.Background(img, bg)
+	}
+
+	f, err := os.Create(c.Out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		return err
+	}
+	logrus.Infof("Wrote %s", c.Out)
+	return nil
+}
+
+// forIsometricRegions loads the chunks of the area a region at a time, rows of regions north to south and west to east in a row,
+// with border more chunks on the south and east side. f gets the blocks of the region that are in the area
+func forIsometricRegions(db *mcdb.DB, dim world.Dimension, minX, minZ, maxX, maxZ, border int, f func(chunks map[world.ChunkPos]*chunk.Chunk, from, to cube.Pos)) error {
+	const regionBlocks = regionChunks * 16 // 1 << 8
+	for rz := minZ >> 8; rz <= maxZ>>8; rz++ {
+		for rx := minX >> 8; rx <= maxX>>8; rx++ {
+			from := cube.Pos{max(rx*regionBlocks, minX), 0, max(rz*regionBlocks, minZ)}
+			to := cube.Pos{min(rx*regionBlocks+regionBlocks-1, maxX), 0, min(rz*regionBlocks+regionBlocks-1, maxZ)}
+
+			chunks := make(map[world.ChunkPos]*chunk.Chunk)
+			for cx := from.X() >> 4; cx <= min(to.X()>>4+border, maxX>>4); cx++ {
+				for cz := from.Z() >> 4; cz <= min(to.Z()>>4+border, maxZ>>4); cz++ {
+					pos := world.ChunkPos{int32(cx), int32(cz)}
+					col, err := db.LoadColumn(pos, dim)
+					if err != nil {
+						if errors.Is(err, leveldb.ErrNotFound) {
+							continue
+						}
+						return fmt.Errorf("chunk %v: %w", pos, err)
+					}
+					chunks[pos] = col.Chunk
+				}
+			}
+			if len(chunks) > 0 {
+				f(chunks, from, to)
+			}
+		}
+	}
+	return nil
+}
+
+func parseHexColor(s string) (color.RGBA, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(s, "#")) != 6 {
+		return color.RGBA{}, fmt.Errorf("background %q should be like #87ceeb", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, nil
+}
@@ -11,6 +11,7 @@ import (
 	"image/png"
 	"io/fs"
 	"math"
+	"math/bits"
 	"os"
 	"path"
 	"strings"
@@ -26,9 +27,22 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// the single png is held in memory while drawing, this is 1GB
+const maxImagePixels = 16384 * 16384
+
 type RenderCMD struct {
-	WorldPath string
-	Out       string
+	WorldPath   string
+	Out         string
+	Tiles       string
+	ZoomLevels  int
+	Dimension   int
+	Textures    bool
+	VanillaPack string
+	Mode        string
+	SliceY      int
+	Area        string
+	IsoScale    int
+	Background  string
 }
 
 func (*RenderCMD) Name() string     { return "render" }
@@ -37,9 +51,29 @@ func (*RenderCMD) Synopsis() string { return "render a world to png" }
 func (c *RenderCMD) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&c.WorldPath, "world", "", "world path")
 	f.StringVar(&c.Out, "out", "world.png", "out png path")
+	f.StringVar(&c.Tiles, "tiles", "", "write z/x/y tiles with an index.html to this folder instead of one png, only changed regions are rendered again")
+	f.IntVar(&c.ZoomLevels, "zoom-levels", 4, "how many zoomed out levels to write with -tiles")
+	f.IntVar(&c.Dimension, "dim", 0, "dimension id for -tiles (0 overworld, 1 nether, 2 end)")
+	f.BoolVar(&c.Textures, "textures", false, "draw block textures at 16 pixels per block")
+	f.StringVar(&c.Mode, "mode", "surface", "what to draw, "+strings.Join(utils.RenderModeNames(), ", ")+" or isometric")
+	f.IntVar(&c.SliceY, "slice-y", 40, "height the cave mode looks down from")
+	f.StringVar(&c.Area, "area", "", "blocks x1,z1,x2,z2 to draw in isometric mode, the whole world if empty and no bigger than 512x512")
+	f.IntVar(&c.IsoScale, "iso-scale", 8, "pixels per block edge in isometric mode")
+	f.StringVar(&c.Background, "background", "", "background color for isometric mode like #87ceeb, transparent if empty")
+	f.StringVar(&c.VanillaPack, "vanilla-pack", "", "folder or zip of the vanilla resource pack, used for the block colors and -textures")
 }
 
 func (c *RenderCMD) Execute(ctx context.Context) error {
+	isometric := c.Mode == "isometric"
+	var mode utils.RenderMode
+	if !isometric {
+		var err error
+		mode, err = utils.ParseRenderMode(c.Mode)
+		if err != nil {
+			return err
+		}
+	}
+
 	blockReg := &merge.BlockRegistry{
 		BlockRegistry: world.DefaultBlockRegistry,
 		Rids:          make(map[uint32]merge.Block),
@@ -137,9 +171,30 @@ func (c *RenderCMD) Execute(ctx context.Context) error {
 		}
 	}
 
-	var renderer utils.ChunkRenderer
+	// world packs go first so they override the vanilla textures
+	if c.VanillaPack != "" {
+		pack, err := resource.ReadPath(c.VanillaPack)
+		if err != nil {
+			return err
+		}
+		resourcePacks = append(resourcePacks, pack)
+	}
+
+	renderer := utils.ChunkRenderer{
+		Textured: c.Textures,
+		Biomes:   world.DefaultBiomes,
+		Mode:     mode,
+		SliceY:   c.SliceY,
+	}
 	renderer.ResolveColors(entries, resourcePacks)
 
+	if isometric {
+		return c.writeIsometric(db, &renderer, entries, resourcePacks)
+	}
+	if c.Tiles != "" {
+		return c.writeTiles(db, &renderer)
+	}
+
 	boundsMin := world.ChunkPos{math.MaxInt32, math.MaxInt32}
 	boundsMax := world.ChunkPos{math.MinInt32, math.MinInt32}
 	it := db.NewColumnIterator(nil)
@@ -157,8 +212,15 @@ func (c *RenderCMD) Execute(ctx context.Context) error {
 
 	chunksX := int(boundsMax[0] - boundsMin[0] + 1)
 	chunksY := int(boundsMax[1] - boundsMin[1] + 1)
-	r := image.Rect(0, 0, chunksX*16, chunksY*16)
+	pxPerChunk := 16 * renderer.BlockSize()
+	r := image.Rect(0, 0, chunksX*pxPerChunk, chunksY*pxPerChunk)
 	fmt.Printf("%dx%d pixels\n", r.Dx(), r.Dy())
+	if int64(r.Dx())*int64(r.Dy()) > maxImagePixels {
+		if c.Textures {
+			return fmt.Errorf("%dx%d pixels is too large for one png with -textures, use -tiles", r.Dx(), r.Dy())
+		}
+		return fmt.Errorf("%dx%d pixels is too large for one png, use -tiles", r.Dx(), r.Dy())
+	}
 	img := image.NewRGBA(r)
 
 	it = db.NewColumnIterator(nil)
@@ -171,12 +233,12 @@ func (c *RenderCMD) Execute(ctx context.Context) error {
 
 		tile := renderer.Chunk2Img(col.Chunk)
 		px := image.Pt(
-			int((pos.X()-boundsMin.X())*16),
-			int((pos.Z()-boundsMin.Z())*16),
+			int(pos.X()-boundsMin.X())*pxPerChunk,
+			int(pos.Z()-boundsMin.Z())*pxPerChunk,
 		)
 		draw.Draw(img, image.Rect(
 			px.X, px.Y,
-			px.X+16, px.Y+16,
+			px.X+pxPerChunk, px.Y+pxPerChunk,
 		), tile, image.Point{}, draw.Src)
 	}
 	it.Release()
@@ -199,6 +261,36 @@ func (c *RenderCMD) Execute(ctx context.Context) error {
 	return nil
 }
 
+func (c *RenderCMD) writeTiles(db *mcdb.DB, renderer *utils.ChunkRenderer) error {
+	if c.ZoomLevels < 0 || c.ZoomLevels > 8 {
+		return fmt.Errorf("-zoom-levels has to be between 0 and 8")
+	}
+	dim, ok := world.DimensionByID(c.Dimension)
+	if !ok {
+		return fmt.Errorf("unknown dimension %d", c.Dimension)
+	}
+	err := os.MkdirAll(c.Tiles, 0o777)
+	if err != nil {
+		return err
+	}
+
+	t := &tileWriter{
+		db:       db,
+		renderer: renderer,
+		dim:      dim,
+		dimID:    c.Dimension,
+		out:      c.Tiles,
+		minZoom:  -c.ZoomLevels,
+		maxZoom:  bits.Len(uint(renderer.BlockSize())) - 1,
+	}
+	err = t.Write(path.Base(c.WorldPath))
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Wrote %s", path.Join(c.Tiles, "index.html"))
+	return nil
+}
+
 func init() {
 	commands.RegisterCommand(&RenderCMD{})
 }
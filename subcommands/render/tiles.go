@@ -0,0 +1,366 @@
+package render
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"html/template"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/bedrock-tool/bedrocktool/utils"
+	"github.com/bedrock-tool/bedrocktool/utils/leaflet"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/sirupsen/logrus"
+)
+
+//go:embed index.html
+var indexHTML string
+
+var indexTemplate = template.Must(template.New("index").Parse(indexHTML))
+
+// a region is the 16x16 chunks that make up one tile at zoom 0
+const regionChunks = 16
+
+// leveldb key tags that change how a chunk looks
+var chunkContentTags = map[byte]bool{
+	0x2b: true, // 3d biomes and heightmap
+	0x2f: true, // subchunk
+	0x31: true, // block entities
+}
+
+type regionPos [2]int32
+
+func (r regionPos) String() string {
+	return strconv.Itoa(int(r[0])) + "," + strconv.Itoa(int(r[1]))
+}
+
+// tilesManifest is written next to the tiles to know what changed on the next run
+type tilesManifest struct {
+	MinZoom   int               `json:"min_zoom"`
+	MaxZoom   int               `json:"max_zoom"`
+	Mode      string            `json:"mode"`
+	Dimension int               `json:"dimension"`
+	Regions   map[string]uint64 `json:"regions"`
+}
+
+type tileWriter struct {
+	db       *mcdb.DB
+	renderer *utils.ChunkRenderer
+	dim      world.Dimension
+	dimID    int
+	out      string
+	minZoom  int
+	maxZoom  int // zoom the chunks are drawn at, above 0 when the renderer draws more than a pixel per block
+}
+
+// regionHashes hashes the raw chunk data of every region in the dimension without decoding it
+func (t *tileWriter) regionHashes() (map[regionPos]uint64, error) {
+	hashers := make(map[regionPos]hash.Hash64)
+
+	iter := t.db.LDB().NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		key := iter.Key()
+		var tag byte
+		switch len(key) {
+		case 9, 10:
+			if t.dimID != 0 {
+				continue
+			}
+			tag = key[8]
+		case 13, 14:
+			if int(int32(binary.LittleEndian.Uint32(key[8:12]))) != t.dimID {
+				continue
+			}
+			tag = key[12]
+		default:
+			continue
+		}
+		if !chunkContentTags[tag] {
+			continue
+		}
+
+		x := int32(binary.LittleEndian.Uint32(key[0:4]))
+		z := int32(binary.LittleEndian.Uint32(key[4:8]))
+		r := regionPos{x >> 4, z >> 4}
+		h, ok := hashers[r]
+		if !ok {
+			h = fnv.New64a()
+			hashers[r] = h
+		}
+		h.Write(key)
+		h.Write(iter.Value())
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	hashes := make(map[regionPos]uint64, len(hashers))
+	for r, h := range hashers {
+		hashes[r] = h.Sum64()
+	}
+	return hashes, nil
+}
+
+// mode is the render mode as stored in the manifest, a change needs all tiles drawn again
+func (t *tileWriter) mode() string {
+	if t.renderer.Mode == utils.RenderCave {
+		return t.renderer.Mode.String() + "@" + strconv.Itoa(t.renderer.SliceY)
+	}
+	return t.renderer.Mode.String()
+}
+
+func (t *tileWriter) tilePath(z int, x, y int32) string {
+	return filepath.Join(t.out, strconv.Itoa(z), strconv.Itoa(int(x)), strconv.Itoa(int(y))+".png")
+}
+
+func (t *tileWriter) readManifest() (*tilesManifest, error) {
+	var m tilesManifest
+	data, err := os.ReadFile(filepath.Join(t.out, "tiles.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return &m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &m)
+	return &m, err
+}
+
+// renderTile draws the chunks of one tile at maxZoom
+func (t *tileWriter) renderTile(p regionPos) (*image.RGBA, error) {
+	chunks := int32(regionChunks >> t.maxZoom)
+	pxPerChunk := 16 << t.maxZoom
+	img := image.NewRGBA(image.Rect(0, 0, regionChunks*16, regionChunks*16))
+	for cz := int32(0); cz < chunks; cz++ {
+		for cx := int32(0); cx < chunks; cx++ {
+			pos := world.ChunkPos{p[0]*chunks + cx, p[1]*chunks + cz}
+			col, err := t.db.LoadColumn(pos, t.dim)
+			if err != nil {
+				if errors.Is(err, leveldb.ErrNotFound) {
+					continue
+				}
+				return nil, fmt.Errorf("chunk %v: %w", pos, err)
+			}
+			px := image.Pt(int(cx)*pxPerChunk, int(cz)*pxPerChunk)
+			draw.Draw(img, image.Rectangle{Min: px, Max: px.Add(image.Pt(pxPerChunk, pxPerChunk))}, t.renderer.Chunk2Img(col.Chunk), image.Point{}, draw.Src)
+		}
+	}
+	return img, nil
+}
+
+func readTile(filename string) (image.Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func removeTile(filename string) error {
+	err := os.Remove(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return err
+}
+
+// writeTile writes img, or removes the tile if it is empty
+func writeTile(filename string, img *image.RGBA) error {
+	empty := true
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] != 0 {
+			empty = false
+			break
+		}
+	}
+	if empty {
+		return removeTile(filename)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0o777); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
+
+// downscaleInto averages every 2x2 pixels of src into dst at offset
+func downscaleInto(dst *image.RGBA, src image.Image, offset image.Point) {
+	b := src.Bounds()
+	for y := 0; y < b.Dy()/2; y++ {
+		for x := 0; x < b.Dx()/2; x++ {
+			var r, g, bl, a uint32
+			for i := 0; i < 4; i++ {
+				cr, cg, cb, ca := src.At(b.Min.X+x*2+i%2, b.Min.Y+y*2+i/2).RGBA()
+				r, g, bl, a = r+cr, g+cg, bl+cb, a+ca
+			}
+			dst.SetRGBA(offset.X+x, offset.Y+y, color.RGBA{
+				R: uint8(r / 4 >> 8), G: uint8(g / 4 >> 8), B: uint8(bl / 4 >> 8), A: uint8(a / 4 >> 8),
+			})
+		}
+	}
+}
+
+// writeZoomedOut builds the tile at zoom z from the four tiles under it
+func (t *tileWriter) writeZoomedOut(z int, p regionPos) error {
+	img := image.NewRGBA(image.Rect(0, 0, regionChunks*16, regionChunks*16))
+	half := regionChunks * 16 / 2
+	for i := int32(0); i < 4; i++ {
+		child, err := readTile(t.tilePath(z+1, p[0]*2+i%2, p[1]*2+i/2))
+		if err != nil {
+			return err
+		}
+		if child != nil {
+			downscaleInto(img, child, image.Pt(int(i%2)*half, int(i/2)*half))
+		}
+	}
+	return writeTile(t.tilePath(z, p[0], p[1]), img)
+}
+
+// changedRegions compares the hashes to the last manifest and returns the regions that need drawing,
+// including the ones that are gone, and the regions for the new manifest
+func changedRegions(last map[string]uint64, hashes map[regionPos]uint64, full bool) (map[regionPos]bool, map[string]uint64) {
+	changed := make(map[regionPos]bool)
+	newRegions := make(map[string]uint64, len(hashes))
+	for r, h := range hashes {
+		newRegions[r.String()] = h
+		if full || last[r.String()] != h {
+			changed[r] = true
+		}
+	}
+	// regions that are gone
+	for key := range last {
+		if _, ok := newRegions[key]; ok {
+			continue
+		}
+		var r regionPos
+		if _, err := fmt.Sscanf(key, "%d,%d", &r[0], &r[1]); err != nil {
+			continue
+		}
+		changed[r] = true
+	}
+	return changed, newRegions
+}
+
+// Write renders the regions that changed since the last run and the zoomed out tiles above them
+func (t *tileWriter) Write(name string) error {
+	manifest, err := t.readManifest()
+	if err != nil {
+		return err
+	}
+	full := manifest.MinZoom != t.minZoom || manifest.MaxZoom != t.maxZoom || manifest.Mode != t.mode() || manifest.Dimension != t.dimID || manifest.Regions == nil
+
+	hashes, err := t.regionHashes()
+	if err != nil {
+		return err
+	}
+
+	changed, newRegions := changedRegions(manifest.Regions, hashes, full)
+	logrus.Infof("%d regions, %d to render", len(hashes), len(changed))
+
+	i := 0
+	perSide := int32(1) << t.maxZoom
+	changedTiles := make(map[regionPos]bool)
+	for r := range changed {
+		i++
+		_, exists := hashes[r]
+		for tz := int32(0); tz < perSide; tz++ {
+			for tx := int32(0); tx < perSide; tx++ {
+				p := regionPos{r[0]*perSide + tx, r[1]*perSide + tz}
+				changedTiles[p] = true
+				filename := t.tilePath(t.maxZoom, p[0], p[1])
+				if !exists {
+					if err := removeTile(filename); err != nil {
+						return err
+					}
+					continue
+				}
+				img, err := t.renderTile(p)
+				if err != nil {
+					return err
+				}
+				if err := writeTile(filename, img); err != nil {
+					return err
+				}
+			}
+		}
+		if i%50 == 0 {
+			logrus.Infof("rendered %d/%d regions", i, len(changed))
+		}
+	}
+
+	changed = changedTiles
+	for z := t.maxZoom - 1; z >= t.minZoom; z-- {
+		parents := make(map[regionPos]bool)
+		for r := range changed {
+			parents[regionPos{r[0] >> 1, r[1] >> 1}] = true
+		}
+		for p := range parents {
+			if err := t.writeZoomedOut(z, p); err != nil {
+				return err
+			}
+		}
+		changed = parents
+	}
+
+	// center the page on the middle of the world
+	var minR, maxR regionPos
+	first := true
+	for r := range hashes {
+		if first {
+			minR, maxR, first = r, r, false
+		}
+		minR = regionPos{min(minR[0], r[0]), min(minR[1], r[1])}
+		maxR = regionPos{max(maxR[0], r[0]), max(maxR[1], r[1])}
+	}
+	f, err := os.Create(filepath.Join(t.out, "index.html"))
+	if err != nil {
+		return err
+	}
+	err = indexTemplate.Execute(f, map[string]any{
+		"Name":    name,
+		"MinZoom": t.minZoom,
+		"MaxZoom": t.maxZoom,
+		"Lng":     (minR[0] + maxR[0] + 1) * regionChunks * 16 / 2,
+		"Lat":     -(minR[1] + maxR[1] + 1) * regionChunks * 16 / 2,
+	})
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if err := leaflet.WriteFiles(filepath.Join(t.out, "leaflet")); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(tilesManifest{
+		MinZoom:   t.minZoom,
+		MaxZoom:   t.maxZoom,
+		Mode:      t.mode(),
+		Dimension: t.dimID,
+		Regions:   newRegions,
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(t.out, "tiles.json"), data, 0o644)
+}
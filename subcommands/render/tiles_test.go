@@ -0,0 +1,182 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"maps"
+	"testing"
+)
+
+func TestDownscaleInto(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    []color.RGBA // 2x2, row by row
+		offset image.Point
+		want   color.RGBA
+	}{
+		{
+			name: "solid",
+			src:  []color.RGBA{{10, 20, 30, 255}, {10, 20, 30, 255}, {10, 20, 30, 255}, {10, 20, 30, 255}},
+			want: color.RGBA{10, 20, 30, 255},
+		},
+		{
+			name: "average",
+			src:  []color.RGBA{{0, 0, 0, 255}, {255, 255, 255, 255}, {0, 0, 0, 255}, {255, 255, 255, 255}},
+			want: color.RGBA{127, 127, 127, 255},
+		},
+		{
+			name: "half transparent",
+			src:  []color.RGBA{{200, 100, 0, 255}, {}, {200, 100, 0, 255}, {}},
+			want: color.RGBA{100, 50, 0, 127},
+		},
+		{
+			name:   "offset",
+			src:    []color.RGBA{{4, 4, 4, 4}, {4, 4, 4, 4}, {4, 4, 4, 4}, {4, 4, 4, 4}},
+			offset: image.Pt(3, 1),
+			want:   color.RGBA{4, 4, 4, 4},
+		},
+	}
+	for _, tt := range tests {
+		src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+		for i, c := range tt.src {
+			src.SetRGBA(i%2, i/2, c)
+		}
+		dst := image.NewRGBA(image.Rect(0, 0, 4, 4))
+		downscaleInto(dst, src, tt.offset)
+		if got := dst.RGBAAt(tt.offset.X, tt.offset.Y); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+		// nothing but the one pixel is written
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				if (x != tt.offset.X || y != tt.offset.Y) && dst.RGBAAt(x, y) != (color.RGBA{}) {
+					t.Errorf("%s: pixel %d,%d written", tt.name, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestDownscaleIntoSubImage(t *testing.T) {
+	// src bounds that dont start at 0,0 are read from their own origin
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 2; y < 4; y++ {
+		for x := 2; x < 4; x++ {
+			src.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	downscaleInto(dst, src.SubImage(image.Rect(2, 2, 4, 4)), image.Point{})
+	if got, want := dst.RGBAAt(0, 0), (color.RGBA{255, 0, 0, 255}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestChangedRegions(t *testing.T) {
+	last := map[string]uint64{"0,0": 1, "1,0": 2, "-1,-1": 3}
+	tests := []struct {
+		name    string
+		last    map[string]uint64
+		hashes  map[regionPos]uint64
+		full    bool
+		changed []regionPos
+	}{
+		{
+			name:   "first run",
+			hashes: map[regionPos]uint64{{0, 0}: 1, {1, 0}: 2},
+			// no manifest means nothing matches
+			changed: []regionPos{{0, 0}, {1, 0}},
+		},
+		{
+			name:   "unchanged",
+			last:   last,
+			hashes: map[regionPos]uint64{{0, 0}: 1, {1, 0}: 2, {-1, -1}: 3},
+		},
+		{
+			name:    "one changed",
+			last:    last,
+			hashes:  map[regionPos]uint64{{0, 0}: 1, {1, 0}: 5, {-1, -1}: 3},
+			changed: []regionPos{{1, 0}},
+		},
+		{
+			name:    "new region",
+			last:    last,
+			hashes:  map[regionPos]uint64{{0, 0}: 1, {1, 0}: 2, {-1, -1}: 3, {0, 7}: 4},
+			changed: []regionPos{{0, 7}},
+		},
+		{
+			name:    "region gone",
+			last:    last,
+			hashes:  map[regionPos]uint64{{0, 0}: 1, {1, 0}: 2},
+			changed: []regionPos{{-1, -1}},
+		},
+		{
+			name:    "full",
+			last:    last,
+			hashes:  map[regionPos]uint64{{0, 0}: 1, {1, 0}: 2},
+			full:    true,
+			changed: []regionPos{{0, 0}, {1, 0}, {-1, -1}},
+		},
+		{
+			name:   "bad key in manifest",
+			last:   map[string]uint64{"x": 1, "0,0": 1},
+			hashes: map[regionPos]uint64{{0, 0}: 1},
+		},
+	}
+	for _, tt := range tests {
+		changed, regions := changedRegions(tt.last, tt.hashes, tt.full)
+		want := make(map[regionPos]bool)
+		for _, r := range tt.changed {
+			want[r] = true
+		}
+		if !maps.Equal(changed, want) {
+			t.Errorf("%s: changed %v, want %v", tt.name, changed, want)
+		}
+		if len(regions) != len(tt.hashes) {
+			t.Errorf("%s: %d regions in manifest, want %d", tt.name, len(regions), len(tt.hashes))
+		}
+		for r, h := range tt.hashes {
+			if regions[r.String()] != h {
+				t.Errorf("%s: manifest %s = %d, want %d", tt.name, r, regions[r.String()], h)
+			}
+		}
+	}
+}
+
+func TestWriteZoomedOut(t *testing.T) {
+	tw := &tileWriter{out: t.TempDir()}
+	size := regionChunks * 16
+	child := image.NewRGBA(image.Rect(0, 0, size, size))
+	for i := 0; i < len(child.Pix); i += 4 {
+		copy(child.Pix[i:], []uint8{0, 255, 0, 255})
+	}
+	// only the bottom right child of tile 0,0 at zoom 0 exists
+	if err := writeTile(tw.tilePath(1, 1, 1), child); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.writeZoomedOut(0, regionPos{0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	img, err := readTile(tw.tilePath(0, 0, 0))
+	if err != nil || img == nil {
+		t.Fatalf("zoomed out tile not written: %v", err)
+	}
+	half := size / 2
+	if _, _, _, a := img.At(half-1, half-1).RGBA(); a != 0 {
+		t.Errorf("top left should be empty")
+	}
+	if r, g, _, a := img.At(half, half).RGBA(); r != 0 || g>>8 != 255 || a>>8 != 255 {
+		t.Errorf("bottom right should be green")
+	}
+
+	// a zoomed out tile with no children is removed
+	if err := removeTile(tw.tilePath(1, 1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.writeZoomedOut(0, regionPos{0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if img, err := readTile(tw.tilePath(0, 0, 0)); err != nil || img != nil {
+		t.Errorf("empty tile not removed: %v", err)
+	}
+}
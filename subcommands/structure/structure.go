@@ -0,0 +1,125 @@
+package structure
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/bedrock-tool/bedrocktool/subcommands/merge"
+	"github.com/bedrock-tool/bedrocktool/utils/commands"
+	"github.com/bedrock-tool/bedrocktool/utils/structure"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/sirupsen/logrus"
+)
+
+type StructureCMD struct {
+	WorldPath string
+	Pos1      string
+	Pos2      string
+	Dimension int
+	Out       string
+	Schem     bool
+}
+
+func (*StructureCMD) Name() string     { return "export-structure" }
+func (*StructureCMD) Synopsis() string { return "export a region of a world as .mcstructure or .schem" }
+
+func (c *StructureCMD) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.WorldPath, "world", "", "world path")
+	f.StringVar(&c.Pos1, "pos1", "", "first corner x,y,z")
+	f.StringVar(&c.Pos2, "pos2", "", "second corner x,y,z")
+	f.IntVar(&c.Dimension, "dim", 0, "dimension id (0 overworld, 1 nether, 2 end)")
+	f.StringVar(&c.Out, "out", "structure.mcstructure", "output path")
+	f.BoolVar(&c.Schem, "schem", false, "also write a sponge .schem next to the output")
+}
+
+type dbChunkSource struct {
+	db  *mcdb.DB
+	dim world.Dimension
+}
+
+func (s dbChunkSource) LoadChunk(pos world.ChunkPos) (*world.Column, bool, error) {
+	col, err := s.db.LoadColumn(pos, s.dim)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return col, true, nil
+}
+
+func (c *StructureCMD) Execute(ctx context.Context) error {
+	if c.WorldPath == "" {
+		return fmt.Errorf("missing -world")
+	}
+	pos1, err := structure.ParsePos(c.Pos1)
+	if err != nil {
+		return fmt.Errorf("-pos1: %w", err)
+	}
+	pos2, err := structure.ParsePos(c.Pos2)
+	if err != nil {
+		return fmt.Errorf("-pos2: %w", err)
+	}
+	dim, ok := world.DimensionByID(c.Dimension)
+	if !ok {
+		return fmt.Errorf("unknown dimension %d", c.Dimension)
+	}
+
+	blockReg := &merge.BlockRegistry{
+		BlockRegistry: world.DefaultBlockRegistry,
+		Rids:          make(map[uint32]merge.Block),
+	}
+	db, err := mcdb.Config{
+		Log:      logrus.StandardLogger(),
+		Blocks:   blockReg,
+		Entities: &merge.EntityRegistry{},
+		ReadOnly: true,
+	}.Open(path.Clean(strings.ReplaceAll(c.WorldPath, "\\", "/")))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	s, err := structure.Read(dbChunkSource{db: db, dim: dim}, blockReg, pos1, pos2)
+	if err != nil {
+		return err
+	}
+
+	err = writeFile(c.Out, s.WriteMCStructure)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Wrote %s", c.Out)
+
+	if c.Schem {
+		schemPath := strings.TrimSuffix(c.Out, path.Ext(c.Out)) + ".schem"
+		err = writeFile(schemPath, s.WriteSchematic)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Wrote %s", schemPath)
+	}
+
+	return nil
+}
+
+func writeFile(filename string, write func(w io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return write(f)
+}
+
+func init() {
+	commands.RegisterCommand(&StructureCMD{})
+}
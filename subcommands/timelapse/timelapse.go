@@ -0,0 +1,325 @@
+package timelapse
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds/worldstate"
+	"github.com/bedrock-tool/bedrocktool/subcommands/merge"
+	"github.com/bedrock-tool/bedrocktool/utils"
+	"github.com/bedrock-tool/bedrocktool/utils/blockhistory"
+	"github.com/bedrock-tool/bedrocktool/utils/commands"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/sirupsen/logrus"
+)
+
+type TimelapseCMD struct {
+	WorldPath string
+	At        string
+	Out       string
+	Frames    string
+	Step      time.Duration
+	Dimension int
+	Area      string
+}
+
+func (*TimelapseCMD) Name() string { return "timelapse" }
+func (*TimelapseCMD) Synopsis() string {
+	return "write a world as it was at a time, or render time-lapse frames from its block history"
+}
+
+func (c *TimelapseCMD) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.WorldPath, "world", "", "world folder, captured with -block-history so the history is next to it")
+	f.StringVar(&c.At, "at", "", "time to write the world at, RFC3339 or a duration after the first change like 10m")
+	f.StringVar(&c.Out, "out", "", "folder to write the world at -at to")
+	f.StringVar(&c.Frames, "frames", "", "folder to render time-lapse frames to")
+	f.DurationVar(&c.Step, "step", time.Minute, "time between frames")
+	f.IntVar(&c.Dimension, "dim", 0, "dimension id (0 overworld, 1 nether, 2 end)")
+	f.StringVar(&c.Area, "area", "", "blocks x1,z1,x2,z2 to use the history of, all of it if empty")
+}
+
+// columns keeps the chunks that get changed in memory
+type columns struct {
+	db   *mcdb.DB
+	dim  world.Dimension
+	reg  world.BlockRegistry
+	cols map[world.ChunkPos]*world.Column
+	// blocks that arent in the registry, like custom blocks, are skipped
+	unknown map[string]bool
+}
+
+func newColumns(db *mcdb.DB, dim world.Dimension, reg world.BlockRegistry) *columns {
+	return &columns{
+		db:      db,
+		dim:     dim,
+		reg:     reg,
+		cols:    make(map[world.ChunkPos]*world.Column),
+		unknown: make(map[string]bool),
+	}
+}
+
+func (c *columns) get(pos world.ChunkPos) (*world.Column, error) {
+	if col, ok := c.cols[pos]; ok {
+		return col, nil
+	}
+	col, err := c.db.LoadColumn(pos, c.dim)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			err = nil
+		}
+		c.cols[pos] = nil
+		return nil, err
+	}
+	c.cols[pos] = col
+	return col, nil
+}
+
+func (c *columns) set(e *blockhistory.Entry, state blockhistory.BlockState) error {
+	col, err := c.get(e.ChunkPos())
+	if err != nil || col == nil {
+		return err
+	}
+	rid, ok := c.reg.StateToRuntimeID(state.Name, state.States)
+	if !ok {
+		if !c.unknown[state.Name] {
+			c.unknown[state.Name] = true
+			logrus.Warnf("skipping changes to unknown block %s", state.Name)
+		}
+		return nil
+	}
+	col.Chunk.SetBlock(uint8(e.Pos[0]&15), int16(e.Pos[1]), uint8(e.Pos[2]&15), e.Layer, rid)
+	return nil
+}
+
+func (c *TimelapseCMD) Execute(ctx context.Context) error {
+	if c.WorldPath == "" {
+		return fmt.Errorf("missing -world")
+	}
+	if c.Out == "" && c.Frames == "" {
+		return fmt.Errorf("need -out or -frames")
+	}
+	c.WorldPath = path.Clean(strings.ReplaceAll(c.WorldPath, "\\", "/"))
+
+	dim, ok := world.DimensionByID(c.Dimension)
+	if !ok {
+		return fmt.Errorf("unknown dimension %d", c.Dimension)
+	}
+
+	area, err := worldstate.ParseCaptureBounds(c.Area, "", 0)
+	if err != nil {
+		return err
+	}
+	// only the files of the chunks in the area are read
+	entries, err := blockhistory.ReadChunks(blockhistory.Dir(c.WorldPath), int32(c.Dimension), area.ContainsChunk)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no block history for dimension %d", c.Dimension)
+	}
+	start := time.UnixMilli(entries[0].Time)
+	end := time.UnixMilli(entries[len(entries)-1].Time)
+	logrus.Infof("%d changes from %s to %s", len(entries), start.Format(time.RFC3339), end.Format(time.RFC3339))
+
+	if c.Out != "" {
+		if c.At == "" {
+			return fmt.Errorf("-out needs -at")
+		}
+		at, err := parseTime(c.At, start)
+		if err != nil {
+			return err
+		}
+		err = c.writeSnapshot(entries, dim, at)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Frames != "" {
+		err = c.renderFrames(entries, dim, start, end)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseTime(s string, start time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -at %q", s)
+	}
+	return start.Add(d), nil
+}
+
+func newBlockRegistry() *merge.BlockRegistry {
+	return &merge.BlockRegistry{
+		BlockRegistry: world.DefaultBlockRegistry,
+		Rids:          make(map[uint32]merge.Block),
+	}
+}
+
+// writeSnapshot copies the world and undoes every change after at
+func (c *TimelapseCMD) writeSnapshot(entries []blockhistory.Entry, dim world.Dimension, at time.Time) error {
+	err := utils.CopyFS(os.DirFS(c.WorldPath), utils.OSWriter{Base: c.Out})
+	if err != nil {
+		return err
+	}
+
+	blockReg := newBlockRegistry()
+	db, err := mcdb.Config{
+		Log:      logrus.StandardLogger(),
+		Blocks:   blockReg,
+		Entities: &merge.EntityRegistry{},
+	}.Open(c.Out)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	cols := newColumns(db, dim, blockReg)
+	var undone int
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := &entries[i]
+		if !time.UnixMilli(e.Time).After(at) {
+			break
+		}
+		if err := cols.set(e, e.From); err != nil {
+			return err
+		}
+		undone++
+	}
+	for pos, col := range cols.cols {
+		if col == nil {
+			continue
+		}
+		if err := db.StoreColumn(pos, dim, col); err != nil {
+			return err
+		}
+	}
+
+	logrus.Infof("Wrote %s at %s, undid %d changes", c.Out, at.Format(time.RFC3339), undone)
+	return nil
+}
+
+// renderFrames renders the changed area once every step from the first to the last change
+func (c *TimelapseCMD) renderFrames(entries []blockhistory.Entry, dim world.Dimension, start, end time.Time) error {
+	if c.Step <= 0 {
+		return fmt.Errorf("-step needs to be positive")
+	}
+
+	blockReg := newBlockRegistry()
+	db, err := mcdb.Config{
+		Log:      logrus.StandardLogger(),
+		Blocks:   blockReg,
+		Entities: &merge.EntityRegistry{},
+		ReadOnly: true,
+	}.Open(c.WorldPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// go back to the state before the first change
+	cols := newColumns(db, dim, blockReg)
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := &entries[i]
+		if err := cols.set(e, e.From); err != nil {
+			return err
+		}
+	}
+	boundsMin, boundsMax := chunkBounds(entries)
+
+	var renderer utils.ChunkRenderer
+	renderer.ResolveColors(nil, nil)
+
+	img := image.NewRGBA(image.Rect(0, 0,
+		int(boundsMax[0]-boundsMin[0]+1)*16,
+		int(boundsMax[1]-boundsMin[1]+1)*16,
+	))
+	drawChunk := func(pos world.ChunkPos) {
+		col := cols.cols[pos]
+		if col == nil {
+			return
+		}
+		px := image.Pt(int(pos[0]-boundsMin[0])*16, int(pos[1]-boundsMin[1])*16)
+		draw.Draw(img, image.Rect(px.X, px.Y, px.X+16, px.Y+16), renderer.Chunk2Img(col.Chunk), image.Point{}, draw.Src)
+	}
+	for pos := range cols.cols {
+		drawChunk(pos)
+	}
+
+	err = os.MkdirAll(c.Frames, 0o777)
+	if err != nil {
+		return err
+	}
+
+	var next, frame int
+	for t := start; ; t = t.Add(c.Step) {
+		dirty := make(map[world.ChunkPos]struct{})
+		for ; next < len(entries) && !time.UnixMilli(entries[next].Time).After(t); next++ {
+			e := &entries[next]
+			if err := cols.set(e, e.To); err != nil {
+				return err
+			}
+			dirty[e.ChunkPos()] = struct{}{}
+		}
+		for pos := range dirty {
+			drawChunk(pos)
+		}
+
+		err = writePng(path.Join(c.Frames, fmt.Sprintf("frame_%05d.png", frame)), img)
+		if err != nil {
+			return err
+		}
+		frame++
+		if t.After(end) {
+			break
+		}
+	}
+
+	logrus.Infof("Wrote %d frames to %s", frame, c.Frames)
+	return nil
+}
+
+// chunkBounds returns the smallest and biggest chunk changed by entries
+func chunkBounds(entries []blockhistory.Entry) (boundsMin, boundsMax world.ChunkPos) {
+	boundsMin = world.ChunkPos{math.MaxInt32, math.MaxInt32}
+	boundsMax = world.ChunkPos{math.MinInt32, math.MinInt32}
+	for i := range entries {
+		pos := entries[i].ChunkPos()
+		boundsMin[0] = min(boundsMin[0], pos[0])
+		boundsMin[1] = min(boundsMin[1], pos[1])
+		boundsMax[0] = max(boundsMax[0], pos[0])
+		boundsMax[1] = max(boundsMax[1], pos[1])
+	}
+	return boundsMin, boundsMax
+}
+
+func writePng(filename string, img image.Image) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
+
+func init() {
+	commands.RegisterCommand(&TimelapseCMD{})
+}
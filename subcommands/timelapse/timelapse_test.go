@@ -0,0 +1,114 @@
+package timelapse
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bedrock-tool/bedrocktool/utils/blockhistory"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseTime(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		s    string
+		want time.Time
+		err  bool
+	}{
+		{s: "10m", want: start.Add(10 * time.Minute)},
+		{s: "1h30s", want: start.Add(time.Hour + 30*time.Second)},
+		{s: "2024-05-01T13:00:00Z", want: start.Add(time.Hour)},
+		{s: "yesterday", err: true},
+		{s: "", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseTime(tt.s, start)
+		if (err != nil) != tt.err {
+			t.Errorf("parseTime(%q) error = %v", tt.s, err)
+			continue
+		}
+		if !tt.err && !got.Equal(tt.want) {
+			t.Errorf("parseTime(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestChunkBounds(t *testing.T) {
+	entries := []blockhistory.Entry{
+		{Pos: [3]int32{1, 64, 1}},
+		{Pos: [3]int32{-17, 64, 40}},
+		{Pos: [3]int32{33, 0, -1}},
+	}
+	boundsMin, boundsMax := chunkBounds(entries)
+	if want := (world.ChunkPos{-2, -1}); boundsMin != want {
+		t.Errorf("min = %v, want %v", boundsMin, want)
+	}
+	if want := (world.ChunkPos{2, 2}); boundsMax != want {
+		t.Errorf("max = %v, want %v", boundsMax, want)
+	}
+}
+
+func TestWriteSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	worldPath := filepath.Join(dir, "world")
+	rid := func(name string) uint32 {
+		rid, ok := world.DefaultBlockRegistry.StateToRuntimeID(name, nil)
+		if !ok {
+			t.Fatalf("no runtime id for %s", name)
+		}
+		return rid
+	}
+
+	// the saved world has the state after the last change
+	db, err := mcdb.Config{Log: logrus.StandardLogger(), Blocks: world.DefaultBlockRegistry}.Open(worldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := chunk.New(world.DefaultBlockRegistry, world.Overworld.Range())
+	c.SetBlock(1, 64, 1, 0, rid("minecraft:gold_block"))
+	if err := db.StoreColumn(world.ChunkPos{0, 0}, world.Overworld, &world.Column{Chunk: c}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []blockhistory.Entry{
+		{Time: 1000, Pos: [3]int32{1, 64, 1}, From: blockhistory.BlockState{Name: "minecraft:air"}, To: blockhistory.BlockState{Name: "minecraft:stone"}},
+		{Time: 2000, Pos: [3]int32{1, 64, 1}, From: blockhistory.BlockState{Name: "minecraft:stone"}, To: blockhistory.BlockState{Name: "minecraft:gold_block"}},
+		// changes in chunks the world doesnt have are skipped
+		{Time: 2500, Pos: [3]int32{100, 64, 100}, From: blockhistory.BlockState{Name: "minecraft:air"}, To: blockhistory.BlockState{Name: "minecraft:stone"}},
+	}
+	tests := []struct {
+		at   int64
+		want string
+	}{
+		{at: 500, want: "minecraft:air"},
+		{at: 1000, want: "minecraft:stone"},
+		{at: 1500, want: "minecraft:stone"},
+		{at: 3000, want: "minecraft:gold_block"},
+	}
+	for _, tt := range tests {
+		cmd := &TimelapseCMD{WorldPath: worldPath, Out: filepath.Join(dir, "at", time.UnixMilli(tt.at).Format("150405.000"))}
+		if err := cmd.writeSnapshot(entries, world.Overworld, time.UnixMilli(tt.at)); err != nil {
+			t.Fatal(err)
+		}
+		db, err := mcdb.Config{Log: logrus.StandardLogger(), Blocks: world.DefaultBlockRegistry, ReadOnly: true}.Open(cmd.Out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		col, err := db.LoadColumn(world.ChunkPos{0, 0}, world.Overworld)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := col.Chunk.Block(1, 64, 1, 0); got != rid(tt.want) {
+			name, _, _ := world.DefaultBlockRegistry.RuntimeIDToState(got)
+			t.Errorf("at %d: block is %s, want %s", tt.at, name, tt.want)
+		}
+		db.Close()
+	}
+}
@@ -0,0 +1,204 @@
+package world
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds"
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds/entity"
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds/worldstate"
+	"github.com/bedrock-tool/bedrocktool/utils"
+	"github.com/bedrock-tool/bedrocktool/utils/commands"
+	"github.com/bedrock-tool/bedrocktool/utils/proxy"
+	"github.com/sirupsen/logrus"
+)
+
+type BatchCMD struct {
+	Folder       string
+	Merge        bool
+	EnableVoid   bool
+	Generator    string
+	FlatLayers   string
+	SaveEntities bool
+	BlockHistory bool
+	EntityFilter string
+	Output       string
+}
+
+func (*BatchCMD) Name() string { return "worlds-batch" }
+func (*BatchCMD) Synopsis() string {
+	return "convert a folder of .pcap2 captures into worlds without a client"
+}
+
+func (c *BatchCMD) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.Folder, "folder", "captures", "folder with the .pcap2 files")
+	f.BoolVar(&c.Merge, "merge", false, "merge captures of the same server into one world")
+	f.BoolVar(&c.EnableVoid, "void", true, "use the void generator")
+	f.StringVar(&c.Generator, "generator", "", "generator for chunks that werent captured, void, flat or default (overrides -void)")
+	f.StringVar(&c.FlatLayers, "flat-layers", "", "layers for the flat generator from the bottom up")
+	f.BoolVar(&c.SaveEntities, "save-entities", true, "Save Entities")
+	f.BoolVar(&c.BlockHistory, "block-history", false, "record block update history")
+	f.StringVar(&c.EntityFilter, "entity-filter", "", "entity filter rules seperated by ;")
+	f.StringVar(&c.Output, "output", "both", "what to keep after saving, both, mcworld or folder")
+}
+
+// captureNameRegex matches the names of files written by the capture handler, host-date.pcap2
+var captureNameRegex = regexp.MustCompile(`^(.*)-\d{4}-\d{2}-\d{2}_\d{2}-\d{2}-\d{2}$`)
+
+// captureServer returns the server a capture was made on, from its filename
+func captureServer(filename string) string {
+	name := strings.TrimSuffix(filepath.Base(filename), ".pcap2")
+	if m := captureNameRegex.FindStringSubmatch(name); m != nil {
+		return m[1]
+	}
+	return name
+}
+
+// findCaptures lists the .pcap2 files in folder, grouped by server if merge is set
+func findCaptures(folder string, merge bool) ([][]string, error) {
+	var files []string
+	err := filepath.WalkDir(folder, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".pcap2" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// capture names end with the date, so this is oldest first per server, also across sub folders
+	slices.SortFunc(files, func(a, b string) int {
+		if c := strings.Compare(filepath.Base(a), filepath.Base(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+
+	if !merge {
+		groups := make([][]string, 0, len(files))
+		for _, file := range files {
+			groups = append(groups, []string{file})
+		}
+		return groups, nil
+	}
+
+	var groups [][]string
+	var servers = make(map[string]int)
+	for _, file := range files {
+		server := captureServer(file)
+		i, ok := servers[server]
+		if !ok {
+			i = len(groups)
+			servers[server] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], file)
+	}
+	return groups, nil
+}
+
+func (c *BatchCMD) settings() (worlds.WorldSettings, error) {
+	entityFilter, err := entity.NewFilter(entity.SplitRules(c.EntityFilter))
+	if err != nil {
+		return worlds.WorldSettings{}, err
+	}
+
+	generatorName := c.Generator
+	if generatorName == "" && !c.EnableVoid {
+		generatorName = "default"
+	}
+	generator, err := worldstate.ParseGenerator(generatorName, c.FlatLayers)
+	if err != nil {
+		return worlds.WorldSettings{}, err
+	}
+
+	output, err := worlds.ParseOutputMode(c.Output)
+	if err != nil {
+		return worlds.WorldSettings{}, err
+	}
+
+	return worlds.WorldSettings{
+		Output:          output,
+		Generator:       generator,
+		SaveEntities:    c.SaveEntities,
+		SaveInventories: true,
+		EntityFilter:    entityFilter,
+		BlockUpdates:    true,
+		BlockHistory:    c.BlockHistory,
+	}, nil
+}
+
+func (c *BatchCMD) Execute(ctx context.Context) error {
+	settings, err := c.settings()
+	if err != nil {
+		return err
+	}
+
+	groups, err := findCaptures(c.Folder, c.Merge)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		return fmt.Errorf("no .pcap2 files in %s", c.Folder)
+	}
+
+	type failure struct {
+		files []string
+		err   error
+	}
+	var failures []failure
+
+	for i, files := range groups {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		logrus.Infof("[%d/%d] converting %s", i+1, len(groups), strings.Join(files, ", "))
+		err := convertCaptures(ctx, settings, files)
+		if err != nil {
+			logrus.Errorf("[%d/%d] failed: %s", i+1, len(groups), err)
+			failures = append(failures, failure{files, err})
+		}
+	}
+
+	logrus.Infof("converted %d of %d", len(groups)-len(failures), len(groups))
+	if len(failures) > 0 {
+		for _, f := range failures {
+			logrus.Errorf("  %s: %s", strings.Join(f.files, ", "), f.err)
+		}
+		return fmt.Errorf("%d conversions failed", len(failures))
+	}
+	return nil
+}
+
+// convertCaptures runs the captures through the worlds handler, all but the last one get preloaded into the same world.
+// panics in the chunk workers come back out of the handler when it waits for them before saving, so they are recovered here too
+func convertCaptures(ctx context.Context, settings worlds.WorldSettings, files []string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	settings.PreloadReplays = files[:len(files)-1]
+
+	p, err := proxy.New(false)
+	if err != nil {
+		return err
+	}
+	p.AddHandler(worlds.NewWorldsHandler(settings))
+
+	return p.Run(ctx, &utils.ConnectInfo{Replay: files[len(files)-1]})
+}
+
+func init() {
+	commands.RegisterCommand(&BatchCMD{})
+}
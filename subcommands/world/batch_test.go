@@ -0,0 +1,83 @@
+package world
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCaptureServer(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"captures/play.example.com-2024-03-01_18-30-05.pcap2", "play.example.com"},
+		{"mc-server.net-2023-12-31_23-59-59.pcap2", "mc-server.net"},
+		{"1.2.3.4-19132-2024-01-01_00-00-00.pcap2", "1.2.3.4-19132"},
+		// renamed captures are their own server
+		{"my capture.pcap2", "my capture"},
+		{"server-2024-03-01.pcap2", "server-2024-03-01"},
+	}
+	for _, tt := range tests {
+		if got := captureServer(tt.filename); got != tt.want {
+			t.Errorf("captureServer(%q) = %q, want %q", tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestFindCaptures(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"b.net-2024-02-01_10-00-00.pcap2",
+		"a.com-2024-03-01_10-00-00.pcap2",
+		"a.com-2024-01-01_10-00-00.pcap2",
+		"b.net-2024-01-15_10-00-00.pcap2",
+		"old/a.com-2023-12-01_10-00-00.pcap2",
+		"notes.txt",
+		"a.com-2024-01-01_10-00-00.pcap2.tmp",
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := func(name string) string { return filepath.Join(dir, name) }
+
+	groups, err := findCaptures(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{p("old/a.com-2023-12-01_10-00-00.pcap2")},
+		{p("a.com-2024-01-01_10-00-00.pcap2")},
+		{p("a.com-2024-03-01_10-00-00.pcap2")},
+		{p("b.net-2024-01-15_10-00-00.pcap2")},
+		{p("b.net-2024-02-01_10-00-00.pcap2")},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("without merge got %v, want %v", groups, want)
+	}
+
+	// merged captures are grouped by server, oldest first so newer captures overwrite older chunks
+	groups, err = findCaptures(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = [][]string{
+		{p("old/a.com-2023-12-01_10-00-00.pcap2"), p("a.com-2024-01-01_10-00-00.pcap2"), p("a.com-2024-03-01_10-00-00.pcap2")},
+		{p("b.net-2024-01-15_10-00-00.pcap2"), p("b.net-2024-02-01_10-00-00.pcap2")},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("with merge got %v, want %v", groups, want)
+	}
+
+	groups, err = findCaptures(filepath.Join(dir, "missing"), true)
+	if err == nil {
+		t.Errorf("no error for a missing folder, got %v", groups)
+	}
+}
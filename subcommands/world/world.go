@@ -5,27 +5,47 @@ import (
 	"flag"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/bedrock-tool/bedrocktool/handlers/worlds"
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds/entity"
+	"github.com/bedrock-tool/bedrocktool/handlers/worlds/worldstate"
 	"github.com/bedrock-tool/bedrocktool/locale"
 	"github.com/bedrock-tool/bedrocktool/utils"
+	"github.com/bedrock-tool/bedrocktool/utils/blockhistory"
 	"github.com/bedrock-tool/bedrocktool/utils/commands"
 	"github.com/bedrock-tool/bedrocktool/utils/proxy"
+	"github.com/sirupsen/logrus"
 )
 
 type WorldCMD struct {
 	ServerAddress   string
 	ListenAddress   string
 	EnableVoid      bool
+	Generator       string
+	FlatLayers      string
 	SaveEntities    bool
 	SaveInventories bool
 	SaveImage       bool
 	BlockUpdates    bool
+	BlockHistory    bool
 	ExcludeMobs     string
+	EntityFilter    string
 	StartPaused     bool
 	PreloadReplay   string
+	PreloadWorld    string
+	Output          string
+	MemoryBudget    int
+	WebMap          string
+	MapTextures     bool
+	VanillaPack     string
 	ChunkRadius     int
 	ScriptPath      string
+	Bounds          string
+	CaptureCenter   string
+	CaptureRadius   int
+	Checkpoint      time.Duration
+	Recover         bool
 }
 
 func (*WorldCMD) Name() string     { return "worlds" }
@@ -35,15 +55,30 @@ func (c *WorldCMD) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&c.ServerAddress, "address", "", locale.Loc("remote_address", nil))
 	f.StringVar(&c.ListenAddress, "listen", "0.0.0.0:19132", "example :19132 or 127.0.0.1:19132")
 	f.BoolVar(&c.EnableVoid, "void", true, locale.Loc("enable_void", nil))
+	f.StringVar(&c.Generator, "generator", "", "generator for chunks that werent captured, void, flat or default (overrides -void)")
+	f.StringVar(&c.FlatLayers, "flat-layers", "", "layers for the flat generator from the bottom up, like minecraft:bedrock,2*minecraft:dirt,minecraft:grass_block")
 	f.BoolVar(&c.SaveImage, "image", false, locale.Loc("save_image", nil))
 	f.BoolVar(&c.SaveEntities, "save-entities", true, "Save Entities")
 	f.BoolVar(&c.SaveInventories, "save-inventories", true, "Save Inventories")
 	f.BoolVar(&c.BlockUpdates, "block-updates", false, "Block updates")
+	f.BoolVar(&c.BlockHistory, "block-history", false, "record block updates with timestamps into <world>."+blockhistory.DirName+" next to the world, one file per chunk, implies -block-updates")
 	f.StringVar(&c.ExcludeMobs, "exclude-mobs", "", "list of mobs to exclude seperated by comma")
+	f.StringVar(&c.EntityFilter, "entity-filter", "", "entity filter rules seperated by ;, like minecraft:*_golem;+*[named,!baby];-*[baby]")
 	f.BoolVar(&c.StartPaused, "start-paused", false, "pause the capturing on startup (can be restarted using /start-capture ingame)")
-	f.StringVar(&c.PreloadReplay, "preload-replay", "", "preload from a replay")
+	f.StringVar(&c.PreloadReplay, "preload-replay", "", "preload from replays, seperated by comma, applied in order")
+	f.IntVar(&c.MemoryBudget, "memory-budget", 0, "megabytes of chunks and entities to keep in memory, the rest goes to a temporary folder, 0 for no limit")
+	f.StringVar(&c.Output, "output", "both", "what to keep after saving, both, mcworld (removes the folder) or folder (no mcworld)")
+	f.StringVar(&c.PreloadWorld, "preload-world", "", "start from existing worlds (mcworld files or world folders), seperated by comma, applied before the replays")
+	f.StringVar(&c.WebMap, "web-map", "", "serve a live map for browsers on this address, :8080 is only reachable from this computer, 0.0.0.0:8080 from the whole network")
+	f.BoolVar(&c.MapTextures, "map-textures", false, "draw block textures on the gui map at 16 pixels per block, uses more memory")
+	f.StringVar(&c.VanillaPack, "vanilla-pack", "", "folder or zip of the vanilla resource pack for the map colors and textures")
 	f.IntVar(&c.ChunkRadius, "chunk-radius", 0, "the max chunk radius to force")
 	f.StringVar(&c.ScriptPath, "script", "", "path to script to use")
+	f.StringVar(&c.Bounds, "bounds", "", "only capture inside this box x1,z1,x2,z2")
+	f.StringVar(&c.CaptureCenter, "capture-center", "", "center x,z for -capture-radius")
+	f.IntVar(&c.CaptureRadius, "capture-radius", 0, "only capture within this many blocks of -capture-center")
+	f.DurationVar(&c.Checkpoint, "checkpoint-interval", 5*time.Minute, "how often to write the capture to disk so it can be recovered after a crash, 0 to disable")
+	f.BoolVar(&c.Recover, "recover", false, "save captures left over from a crash like -output says before starting, they are only listed without it")
 }
 
 func (c *WorldCMD) Execute(ctx context.Context) error {
@@ -56,6 +91,37 @@ func (c *WorldCMD) Execute(ctx context.Context) error {
 		script = string(data)
 	}
 
+	entityFilter, err := entity.NewFilter(append(strings.Split(c.ExcludeMobs, ","), entity.SplitRules(c.EntityFilter)...))
+	if err != nil {
+		return err
+	}
+
+	generatorName := c.Generator
+	if generatorName == "" && !c.EnableVoid {
+		generatorName = "default"
+	}
+	generator, err := worldstate.ParseGenerator(generatorName, c.FlatLayers)
+	if err != nil {
+		return err
+	}
+
+	output, err := worlds.ParseOutputMode(c.Output)
+	if err != nil {
+		return err
+	}
+
+	err = c.recoverCrashed(output)
+	if err != nil {
+		return err
+	}
+
+	captureBounds, err := worldstate.ParseCaptureBounds(c.Bounds, c.CaptureCenter, c.CaptureRadius)
+	if err != nil {
+		return err
+	}
+
+	preloadReplays := splitList(c.PreloadReplay)
+
 	proxy, err := proxy.New(true)
 	if err != nil {
 		return err
@@ -63,16 +129,26 @@ func (c *WorldCMD) Execute(ctx context.Context) error {
 	proxy.ListenAddress = c.ListenAddress
 
 	proxy.AddHandler(worlds.NewWorldsHandler(worlds.WorldSettings{
-		VoidGen:         c.EnableVoid,
+		Generator:       generator,
 		SaveEntities:    c.SaveEntities,
 		SaveInventories: c.SaveInventories,
 		SaveImage:       c.SaveImage,
-		ExcludedMobs:    strings.Split(c.ExcludeMobs, ","),
+		EntityFilter:    entityFilter,
 		StartPaused:     c.StartPaused,
-		PreloadReplay:   c.PreloadReplay,
+		PreloadReplays:  preloadReplays,
+		PreloadWorlds:   splitList(c.PreloadWorld),
+		Output:          output,
+		MemoryBudget:    int64(c.MemoryBudget) << 20,
+		WebMapAddress:   c.WebMap,
+		MapTextures:     c.MapTextures,
+		VanillaPack:     c.VanillaPack,
 		ChunkRadius:     int32(c.ChunkRadius),
 		Script:          script,
-		BlockUpdates:    c.BlockUpdates,
+		BlockUpdates:    c.BlockUpdates || c.BlockHistory,
+		BlockHistory:    c.BlockHistory,
+		CaptureBounds:   captureBounds,
+
+		CheckpointInterval: c.Checkpoint,
 	}))
 
 	server := ctx.Value(utils.ConnectInfoKey).(*utils.ConnectInfo)
@@ -83,6 +159,42 @@ func (c *WorldCMD) Execute(ctx context.Context) error {
 	return nil
 }
 
+func (c *WorldCMD) recoverCrashed(output worlds.OutputMode) error {
+	folders, err := worldstate.FindCrashedCaptures("worlds")
+	if err != nil {
+		return err
+	}
+	if len(folders) == 0 {
+		return nil
+	}
+	if !c.Recover {
+		logrus.Warnf("Found %d captures that didnt finish, they are kept and new captures use other folders, start with -recover to save them:", len(folders))
+		for _, folder := range folders {
+			logrus.Warnf("  %s", folder)
+		}
+		return nil
+	}
+	for _, folder := range folders {
+		filename, err := worlds.RecoverCapture(folder, output)
+		if err != nil {
+			logrus.Errorf("Failed to recover %s: %s", folder, err)
+			continue
+		}
+		logrus.Infof("Recovered %s", filename)
+	}
+	return nil
+}
+
+// splitList splits a comma seperated flag value, skipping empty entries
+func splitList(s string) (out []string) {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 func init() {
 	commands.RegisterCommand(&WorldCMD{})
 }
@@ -2,6 +2,7 @@ package worlds
 
 import (
 	"image"
+	"image/color"
 	"image/draw"
 	"math"
 	"sync"
@@ -36,9 +37,44 @@ type Map2 struct {
 
 	images   map[image.Point]*image.RGBA
 	imageOps map[image.Point]paint.ImageOp
+	// textured chunks by chunk position, these have more than a pixel per block
+	chunkOps map[image.Point]paint.ImageOp
+	markers  []messages.MapMarker
 	l        sync.Mutex
 }
 
+var markerColors = map[string]color.NRGBA{
+	"self":      {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	"players":   {R: 0x30, G: 0x60, B: 0xff, A: 0xff},
+	"entities":  {R: 0xff, G: 0x30, B: 0x30, A: 0xff},
+	"waypoints": {R: 0xff, G: 0xd0, B: 0x20, A: 0xff},
+}
+
+// drawMarker draws an arrow facing yaw, or a dot for markers without a direction, centered on the current offset
+func drawMarker(ops *op.Ops, marker messages.MapMarker) {
+	col, ok := markerColors[marker.Layer]
+	if !ok {
+		col = markerColors["entities"]
+	}
+	if marker.Layer == "waypoints" {
+		const r = 5
+		paint.FillShape(ops, col, clip.Ellipse{Min: image.Pt(-r, -r), Max: image.Pt(r, r)}.Op(ops))
+		return
+	}
+
+	// yaw 0 faces south, which is down on the map
+	rot := op.Affine(f32.Affine2D{}.Rotate(f32.Pt(0, 0), float32(marker.Yaw)*math.Pi/180)).Push(ops)
+	var p clip.Path
+	p.Begin(ops)
+	p.MoveTo(f32.Pt(0, 8))
+	p.LineTo(f32.Pt(5, -6))
+	p.LineTo(f32.Pt(0, -3))
+	p.LineTo(f32.Pt(-5, -6))
+	p.Close()
+	paint.FillShape(ops, col, clip.Outline{Path: p.End()}.Op())
+	rot.Pop()
+}
+
 func (m *mapInput) HandlePointerEvent(e pointer.Event) {
 	const WHEEL_DELTA = 120
 
@@ -127,6 +163,39 @@ func (m *Map2) Layout(gtx C) D {
 		aff.Pop()
 	}
 
+	for p, imageOp := range m.chunkOps {
+		scaledSize := 16 * m.mapInput.scaleFactor
+		pt := f32.Pt(float32(float64(p.X)*scaledSize), float32(float64(p.Y)*scaledSize))
+		if (image.Rectangle{Max: gtx.Constraints.Max}).Intersect(
+			image.Rectangle{
+				Min: pt.Round(),
+				Max: pt.Add(f32.Pt(float32(scaledSize), float32(scaledSize))).Round(),
+			}.Add(m.mapInput.center.Round()).Add(m.mapInput.transform.Transform(f32.Pt(0, 0)).Round()),
+		).Empty() {
+			continue
+		}
+
+		// scale the chunk down to 16 units so it lines up with the tiles
+		blockScale := 16 / float32(imageOp.Size().X)
+		scale := f32.Affine2D{}.Scale(f32.Pt(0, 0), f32.Pt(blockScale, blockScale))
+		aff := op.Affine(m.mapInput.transform.Offset(m.mapInput.center).Offset(pt).Mul(scale)).Push(gtx.Ops)
+		imageOp.Add(gtx.Ops)
+		paint.PaintOp{}.Add(gtx.Ops)
+		aff.Pop()
+	}
+
+	// markers stay the same size at every zoom
+	origin := m.mapInput.transform.Transform(f32.Pt(0, 0)).Add(m.mapInput.center)
+	for _, marker := range m.markers {
+		pt := f32.Pt(marker.Position.X(), marker.Position.Z()).Mul(float32(m.mapInput.scaleFactor)).Add(origin)
+		if !pt.Round().In(image.Rectangle{Max: gtx.Constraints.Max}) {
+			continue
+		}
+		off := op.Offset(pt.Round()).Push(gtx.Ops)
+		drawMarker(gtx.Ops, marker)
+		off.Pop()
+	}
+
 	return D{Size: gtx.Constraints.Max}
 }
 
@@ -157,11 +226,25 @@ func (m *Map2) Update(u *messages.UpdateMap) {
 	if u.ChunkCount == -1 {
 		m.images = make(map[image.Point]*image.RGBA)
 		m.imageOps = make(map[image.Point]paint.ImageOp)
+		m.chunkOps = make(map[image.Point]paint.ImageOp)
+		m.markers = nil
 		return
 	}
+	m.markers = u.Markers
 
 	var updatedTiles []image.Point
 	for _, cp := range u.UpdatedChunks {
+		chunk, ok := u.Chunks[cp]
+		if !ok {
+			continue
+		}
+		if chunk.Rect.Dx() != 16 {
+			// textured chunks would make huge tiles, they are drawn one by one
+			m.chunkOps[image.Pt(int(cp.X()), int(cp.Z()))] = paint.NewImageOp(chunk)
+			continue
+		}
+		// the map scales textured chunks far from the player down again, so this stays as big as its limit
+		delete(m.chunkOps, image.Pt(int(cp.X()), int(cp.Z())))
 		tilePos, posInTile := chunkPosToTilePos(cp)
 		img, ok := m.images[tilePos]
 		if !ok {
@@ -170,7 +253,7 @@ func (m *Map2) Update(u *messages.UpdateMap) {
 		}
 		draw.Draw(img, image.Rectangle{
 			Min: posInTile, Max: posInTile.Add(image.Pt(16, 16)),
-		}, u.Chunks[cp], image.Point{}, draw.Src)
+		}, chunk, image.Point{}, draw.Src)
 		updatedTiles = append(updatedTiles, tilePos)
 	}
 
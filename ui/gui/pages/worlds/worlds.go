@@ -13,6 +13,7 @@ import (
 	"gioui.org/x/component"
 	"github.com/bedrock-tool/bedrocktool/ui/gui/pages"
 	"github.com/bedrock-tool/bedrocktool/ui/messages"
+	"github.com/bedrock-tool/bedrocktool/utils"
 )
 
 type (
@@ -36,13 +37,19 @@ type Page struct {
 	voidGen    bool
 	worldName  string
 	back       widget.Clickable
+
+	mapMode     string
+	modeButtons []widget.Clickable
 }
 
 func New(invalidate func()) pages.Page {
 	return &Page{
+		mapMode:     utils.RenderSurface.String(),
+		modeButtons: make([]widget.Clickable, len(utils.RenderModeNames())),
 		worldMap: &Map2{
 			images:   make(map[image.Point]*image.RGBA),
 			imageOps: make(map[image.Point]paint.ImageOp),
+			chunkOps: make(map[image.Point]paint.ImageOp),
 		},
 		finishedWorldsList: widget.List{
 			List: layout.List{
@@ -120,6 +127,33 @@ func displayWorldProcessing(gtx C, th *material.Theme, entry *processingWorld) D
 	})
 }
 
+// layoutModeButtons draws a button per map render mode, the active one is disabled
+func (p *Page) layoutModeButtons(gtx C, th *material.Theme) D {
+	names := utils.RenderModeNames()
+	children := make([]layout.FlexChild, len(names))
+	for i, name := range names {
+		button := &p.modeButtons[i]
+		if button.Clicked(gtx) && name != p.mapMode {
+			p.mapMode = name
+			messages.Router.Handle(&messages.Message{
+				Source: "ui",
+				Target: "mapui",
+				Data:   messages.SetMapMode{Mode: name},
+			})
+		}
+		children[i] = layout.Rigid(func(gtx C) D {
+			return layout.UniformInset(4).Layout(gtx, func(gtx C) D {
+				b := material.Button(th, button, name)
+				if name == p.mapMode {
+					gtx = gtx.Disabled()
+				}
+				return b.Layout(gtx)
+			})
+		})
+	}
+	return layout.Flex{}.Layout(gtx, children...)
+}
+
 func (p *Page) Layout(gtx C, th *material.Theme) D {
 	if p.back.Clicked(gtx) {
 		messages.Router.Handle(&messages.Message{
@@ -133,7 +167,12 @@ func (p *Page) Layout(gtx C, th *material.Theme) D {
 		layout.Stacked(func(gtx C) D {
 			switch p.State {
 			case messages.UIStateMain:
-				return p.worldMap.Layout(gtx)
+				return layout.Stack{Alignment: layout.NE}.Layout(gtx,
+					layout.Stacked(p.worldMap.Layout),
+					layout.Stacked(func(gtx C) D {
+						return p.layoutModeButtons(gtx, th)
+					}),
+				)
 			case messages.UIStateFinished:
 				return layout.UniformInset(25).Layout(gtx, func(gtx C) D {
 					return layout.Flex{
@@ -64,11 +64,25 @@ type MapLookup struct {
 	Lookup *image.RGBA
 }
 
+// SetMapMode changes the render mode of the map, one of utils.RenderModeNames
+type SetMapMode struct {
+	Mode string
+}
+
 type UpdateMap struct {
 	Chunks        map[protocol.ChunkPos]*image.RGBA
 	UpdatedChunks []protocol.ChunkPos
 	ChunkCount    int
 	Rotation      float32
+	Markers       []MapMarker
+}
+
+// MapMarker is something shown on top of the map, Layer is self, players, entities or waypoints
+type MapMarker struct {
+	Layer    string
+	Label    string
+	Position mgl32.Vec3
+	Yaw      float32
 }
 
 type PlayerPosition struct {
@@ -1,21 +1,42 @@
 package messages
 
+import "sync"
+
 type router struct {
+	l        sync.RWMutex
 	handlers map[string]HandlerFunc
 }
 
 func (r *router) AddHandler(name string, handler HandlerFunc) {
+	r.l.Lock()
+	defer r.l.Unlock()
 	r.handlers[name] = handler
 }
 
+// RemoveHandler removes the handler added with name
+func (r *router) RemoveHandler(name string) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	delete(r.handlers, name)
+}
+
+// Handle sends msg to its target, or to every handler if it has none.
+// handlers are called without the lock held so they can send messages themselves
 func (r *router) Handle(msg *Message) *Message {
+	r.l.RLock()
 	if msg.Target == "" {
+		handlers := make([]HandlerFunc, 0, len(r.handlers))
 		for _, handler := range r.handlers {
+			handlers = append(handlers, handler)
+		}
+		r.l.RUnlock()
+		for _, handler := range handlers {
 			handler(msg)
 		}
 		return nil
 	}
 	handler, ok := r.handlers[msg.Target]
+	r.l.RUnlock()
 	if !ok {
 		return nil
 	}
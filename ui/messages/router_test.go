@@ -0,0 +1,65 @@
+package messages
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	r := router{handlers: make(map[string]HandlerFunc)}
+	var got []string
+	r.AddHandler("a", func(msg *Message) *Message {
+		got = append(got, "a")
+		return &Message{Source: "a"}
+	})
+	r.AddHandler("b", func(msg *Message) *Message {
+		got = append(got, "b")
+		// handlers can send messages themselves
+		r.Handle(&Message{Target: "a"})
+		return nil
+	})
+
+	if reply := r.Handle(&Message{Target: "a"}); reply == nil || reply.Source != "a" {
+		t.Errorf("reply = %v", reply)
+	}
+	if reply := r.Handle(&Message{Target: "missing"}); reply != nil {
+		t.Errorf("reply from missing handler = %v", reply)
+	}
+	got = nil
+	r.Handle(&Message{Target: "b"})
+	if len(got) != 2 {
+		t.Errorf("nested handle called %v", got)
+	}
+
+	r.RemoveHandler("a")
+	got = nil
+	r.Handle(&Message{})
+	if len(got) != 1 || got[0] != "b" {
+		t.Errorf("broadcast after remove called %v", got)
+	}
+}
+
+func TestRouterConcurrent(t *testing.T) {
+	r := router{handlers: make(map[string]HandlerFunc)}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		name := strconv.Itoa(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r.AddHandler(name, func(msg *Message) *Message { return nil })
+				r.RemoveHandler(name)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r.Handle(&Message{})
+				r.Handle(&Message{Target: name})
+			}
+		}()
+	}
+	wg.Wait()
+}
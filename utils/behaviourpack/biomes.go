@@ -11,17 +11,44 @@ type biomeDescription struct {
 
 type MinecraftBiome struct {
 	Description biomeDescription `json:"description"`
+	Components  map[string]any   `json:"components"`
+}
+
+// biomeComponents converts the biome definition the server sent to behaviour pack components
+func biomeComponents(data map[string]any) map[string]any {
+	components := make(map[string]any)
+	climate := make(map[string]any)
+	if v, ok := data["temperature"].(float32); ok {
+		climate["temperature"] = v
+	}
+	if v, ok := data["downfall"].(float32); ok {
+		climate["downfall"] = v
+	}
+	if len(climate) > 0 {
+		components["minecraft:climate"] = climate
+	}
+	if tags, ok := data["tags"].([]any); ok {
+		for _, tag := range tags {
+			if tag, ok := tag.(string); ok {
+				components[tag] = map[string]any{}
+			}
+		}
+	}
+	return components
 }
 
 func (b *Pack) AddBiomes(biomesMap map[string]any) {
+	b.l.Lock()
+	defer b.l.Unlock()
 	for name, biome := range biomesMap {
-		_ = biome
+		data, _ := biome.(map[string]any)
 		b.biomes = append(b.biomes, biomeBehaviour{
 			FormatVersion: "1.13.0",
 			MinecraftBiome: MinecraftBiome{
 				Description: biomeDescription{
 					Identifier: name,
 				},
+				Components: biomeComponents(data),
 			},
 		})
 	}
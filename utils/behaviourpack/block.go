@@ -10,6 +10,8 @@ type BlockBehaviour struct {
 }
 
 func (bp *Pack) AddBlock(block protocol.BlockEntry) {
+	bp.l.Lock()
+	defer bp.l.Unlock()
 	ns, _ := ns_name_split(block.Name)
 	if ns == "minecraft" {
 		return
@@ -5,18 +5,22 @@ import (
 	"io/fs"
 	"path"
 	"strings"
+	"sync"
 
 	"github.com/bedrock-tool/bedrocktool/utils"
 	"github.com/sandertv/gophertunnel/minecraft/resource"
 )
 
 type Pack struct {
+	// packets add to the pack while it is saved for a checkpoint
+	l             sync.Mutex
 	formatVersion string
 	Manifest      *resource.Manifest
 	blocks        map[string]*BlockBehaviour
 	items         map[string]*itemBehaviour
 	entities      map[string]*entityBehaviour
 	biomes        []biomeBehaviour
+	dimensions    map[string]*dimensionBehaviour
 }
 
 func New(name string) *Pack {
@@ -42,13 +46,26 @@ func New(name string) *Pack {
 			Dependencies: []resource.Dependency{},
 			Capabilities: []resource.Capability{},
 		},
-		blocks:   make(map[string]*BlockBehaviour),
-		items:    make(map[string]*itemBehaviour),
-		entities: make(map[string]*entityBehaviour),
+		blocks:     make(map[string]*BlockBehaviour),
+		items:      make(map[string]*itemBehaviour),
+		entities:   make(map[string]*entityBehaviour),
+		dimensions: make(map[string]*dimensionBehaviour),
 	}
 }
 
 func (bp *Pack) AddDependency(id string, ver resource.Version) {
+	bp.l.Lock()
+	defer bp.l.Unlock()
+	bp.addDependency(id, ver)
+}
+
+// addDependency adds a dependency once, packs are linked again on every save
+func (bp *Pack) addDependency(id string, ver resource.Version) {
+	for _, d := range bp.Manifest.Dependencies {
+		if d.UUID == id {
+			return
+		}
+	}
 	bp.Manifest.Dependencies = append(bp.Manifest.Dependencies, resource.Dependency{
 		UUID:    id,
 		Version: ver,
@@ -65,6 +82,8 @@ func fsFileExists(f fs.FS, name string) bool {
 }
 
 func (bp *Pack) CheckAddLink(pack resource.Pack) {
+	bp.l.Lock()
+	defer bp.l.Unlock()
 	hasBlocksJson := bp.HasBlocks() && fsFileExists(pack, "blocks.json")
 	hasEntitiesFolder := bp.HasEntities() && fsFileExists(pack, "entity")
 	hasItemsFolder := bp.HasItems() && fsFileExists(pack, "items")
@@ -75,7 +94,7 @@ func (bp *Pack) CheckAddLink(pack resource.Pack) {
 	}
 
 	h := pack.Manifest().Header
-	bp.AddDependency(h.UUID, h.Version)
+	bp.addDependency(h.UUID, h.Version)
 }
 
 func (bp *Pack) HasBlocks() bool {
@@ -90,8 +109,18 @@ func (bp *Pack) HasEntities() bool {
 	return len(bp.entities) > 0
 }
 
+func (bp *Pack) HasBiomes() bool {
+	return len(bp.biomes) > 0
+}
+
+func (bp *Pack) HasDimensions() bool {
+	return len(bp.dimensions) > 0
+}
+
 func (bp *Pack) HasContent() bool {
-	return bp.HasBlocks() || bp.HasItems()
+	bp.l.Lock()
+	defer bp.l.Unlock()
+	return bp.HasBlocks() || bp.HasItems() || bp.HasBiomes() || bp.HasDimensions()
 }
 
 func ns_name_split(identifier string) (ns, name string) {
@@ -100,6 +129,8 @@ func ns_name_split(identifier string) (ns, name string) {
 }
 
 func (bp *Pack) Save(fs utils.WriterFS, fpath string) error {
+	bp.l.Lock()
+	defer bp.l.Unlock()
 	if err := utils.WriteManifest(bp.Manifest, fs, fpath); err != nil {
 		return err
 	}
@@ -151,6 +182,24 @@ func (bp *Pack) Save(fs utils.WriterFS, fpath string) error {
 			}
 		}
 	}
+	if bp.HasBiomes() { // biomes
+		biomesDir := path.Join(fpath, "biomes")
+		for _, bb := range bp.biomes {
+			err := _add_thing(biomesDir, bb.MinecraftBiome.Description.Identifier, bb)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if bp.HasDimensions() { // dimensions
+		dimensionsDir := path.Join(fpath, "dimensions")
+		for _, db := range bp.dimensions {
+			err := _add_thing(dimensionsDir, db.MinecraftDimension.Description.Identifier, db)
+			if err != nil {
+				return err
+			}
+		}
+	}
 
 	return nil
 }
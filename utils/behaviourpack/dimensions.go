@@ -0,0 +1,40 @@
+package behaviourpack
+
+type dimensionBehaviour struct {
+	FormatVersion      string             `json:"format_version"`
+	MinecraftDimension MinecraftDimension `json:"minecraft:dimension"`
+}
+
+type dimensionDescription struct {
+	Identifier string `json:"identifier"`
+}
+
+type dimensionBounds struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+type MinecraftDimension struct {
+	Description dimensionDescription `json:"description"`
+	Components  map[string]any       `json:"components"`
+}
+
+// AddDimension adds a dimension with a custom height, max is exclusive
+func (bp *Pack) AddDimension(identifier string, min, max int) {
+	bp.l.Lock()
+	defer bp.l.Unlock()
+	bp.dimensions[identifier] = &dimensionBehaviour{
+		FormatVersion: "1.18.0",
+		MinecraftDimension: MinecraftDimension{
+			Description: dimensionDescription{
+				Identifier: identifier,
+			},
+			Components: map[string]any{
+				"minecraft:dimension_bounds": dimensionBounds{
+					Min: min,
+					Max: max,
+				},
+			},
+		},
+	}
+}
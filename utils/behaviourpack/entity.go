@@ -35,6 +35,8 @@ type EntityPropertyJson struct {
 }
 
 func (bp *Pack) AddEntity(EntityType string, attr []protocol.AttributeValue, meta protocol.EntityMetadata, props map[string]*entity.EntityProperty) {
+	bp.l.Lock()
+	defer bp.l.Unlock()
 	ns, _ := ns_name_split(EntityType)
 	if ns == "minecraft" {
 		return
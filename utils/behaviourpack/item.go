@@ -19,6 +19,8 @@ type itemBehaviour struct {
 }
 
 func (bp *Pack) AddItem(item protocol.ItemEntry) {
+	bp.l.Lock()
+	defer bp.l.Unlock()
 	ns, _ := ns_name_split(item.Name)
 	if ns == "minecraft" {
 		return
@@ -37,6 +39,8 @@ func (bp *Pack) AddItem(item protocol.ItemEntry) {
 }
 
 func (bp *Pack) ApplyComponentEntries(entries []protocol.ItemComponentEntry) {
+	bp.l.Lock()
+	defer bp.l.Unlock()
 	for _, ice := range entries {
 		item, ok := bp.items[ice.Name]
 		if !ok {
@@ -0,0 +1,193 @@
+// Package blockhistory stores the block changes of a capture with timestamps next to the saved world,
+// one file per chunk so an area can be read without the rest
+package blockhistory
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+// DirName is the suffix of the history folder that is written next to the world folder
+const DirName = "block_history"
+
+// Dir returns where the history of the world in folder is written,
+// it is kept outside of the folder so it doesnt end up in the mcworld
+func Dir(worldFolder string) string {
+	return path.Clean(worldFolder) + "." + DirName
+}
+
+// chunkFile is the name of the file with the changes of one chunk
+func chunkFile(dim int32, pos world.ChunkPos) string {
+	return fmt.Sprintf("%d_%d_%d.jsonl", dim, pos[0], pos[1])
+}
+
+func parseChunkFile(name string) (dim int32, pos world.ChunkPos, ok bool) {
+	var rest string
+	n, _ := fmt.Sscanf(name, "%d_%d_%d.%s", &dim, &pos[0], &pos[1], &rest)
+	return dim, pos, n == 4 && rest == "jsonl"
+}
+
+type BlockState struct {
+	Name   string         `json:"name"`
+	States map[string]any `json:"states,omitempty"`
+}
+
+// Entry is one block changing from one state to another
+type Entry struct {
+	Time      int64      `json:"t"` // unix milliseconds
+	Dimension int32      `json:"dim"`
+	Pos       [3]int32   `json:"pos"`
+	Layer     uint8      `json:"layer"`
+	From      BlockState `json:"from"`
+	To        BlockState `json:"to"`
+}
+
+func (e *Entry) ChunkPos() world.ChunkPos {
+	return world.ChunkPos{e.Pos[0] >> 4, e.Pos[2] >> 4}
+}
+
+// Write writes entries as one json object per line
+func Write(w io.Writer, entries []Entry) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Append adds entries to the end of the file of their chunk in dir
+func Append(dir string, entries []Entry) error {
+	type key struct {
+		dim int32
+		pos world.ChunkPos
+	}
+	var order []key
+	chunks := make(map[key][]Entry)
+	for _, e := range entries {
+		k := key{e.Dimension, e.ChunkPos()}
+		if _, ok := chunks[k]; !ok {
+			order = append(order, k)
+		}
+		chunks[k] = append(chunks[k], e)
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	err := os.MkdirAll(dir, 0o777)
+	if err != nil {
+		return err
+	}
+	for _, k := range order {
+		f, err := os.OpenFile(path.Join(dir, chunkFile(k.dim, k.pos)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o666)
+		if err != nil {
+			return err
+		}
+		err = Write(f, chunks[k])
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read reads all entries sorted by time
+func Read(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	dec := json.NewDecoder(bufio.NewReader(r))
+	dec.UseNumber()
+	for {
+		var e Entry
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		fixStates(e.From.States)
+		fixStates(e.To.States)
+		entries = append(entries, e)
+	}
+	sortByTime(entries)
+	return entries, nil
+}
+
+// Chunks lists the chunks in dim that have history in dir
+func Chunks(dir string, dim int32) ([]world.ChunkPos, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var chunks []world.ChunkPos
+	for _, f := range files {
+		d, pos, ok := parseChunkFile(f.Name())
+		if !ok || f.IsDir() || d != dim {
+			continue
+		}
+		chunks = append(chunks, pos)
+	}
+	return chunks, nil
+}
+
+// ReadChunks reads the entries of the chunks in dim that keep returns true for sorted by time,
+// every chunk is read if keep is nil
+func ReadChunks(dir string, dim int32, keep func(pos world.ChunkPos) bool) ([]Entry, error) {
+	chunks, err := Chunks(dir, dim)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, pos := range chunks {
+		if keep != nil && !keep(pos) {
+			continue
+		}
+		f, err := os.Open(path.Join(dir, chunkFile(dim, pos)))
+		if err != nil {
+			return nil, err
+		}
+		chunkEntries, err := Read(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("history of chunk %v: %w", pos, err)
+		}
+		entries = append(entries, chunkEntries...)
+	}
+	sortByTime(entries)
+	return entries, nil
+}
+
+// sortByTime keeps the order of entries with the same time, which is the order they were applied in
+func sortByTime(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time < entries[j].Time
+	})
+}
+
+// fixStates turns json numbers back into the int32 block states use
+func fixStates(states map[string]any) {
+	for k, v := range states {
+		if n, ok := v.(json.Number); ok {
+			i, _ := n.Int64()
+			states[k] = int32(i)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package blockhistory
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+func entry(t int64, dim int32, x, y, z int32, from, to string) Entry {
+	return Entry{
+		Time:      t,
+		Dimension: dim,
+		Pos:       [3]int32{x, y, z},
+		From:      BlockState{Name: from},
+		To:        BlockState{Name: to, States: map[string]any{"age": int32(t)}},
+	}
+}
+
+func TestAppendSplitsByChunk(t *testing.T) {
+	dir := path.Join(t.TempDir(), "world."+DirName)
+	first := []Entry{
+		entry(3, 0, 1, 64, 1, "minecraft:air", "minecraft:stone"),
+		entry(1, 0, 17, 64, 1, "minecraft:air", "minecraft:dirt"),
+		entry(2, 0, -1, 64, -17, "minecraft:air", "minecraft:sand"),
+		entry(2, 1, 1, 64, 1, "minecraft:air", "minecraft:netherrack"),
+	}
+	second := []Entry{
+		entry(4, 0, 2, 65, 3, "minecraft:stone", "minecraft:air"),
+		// same time as the entry before it in the same chunk, the order is kept
+		entry(4, 0, 2, 66, 3, "minecraft:air", "minecraft:torch"),
+	}
+	if err := Append(dir, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(dir, second); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	wantNames := []string{"0_-1_-2.jsonl", "0_0_0.jsonl", "0_1_0.jsonl", "1_0_0.jsonl"}
+	if !slices.Equal(names, wantNames) {
+		t.Errorf("files %v, want %v", names, wantNames)
+	}
+
+	chunks, err := Chunks(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 {
+		t.Errorf("chunks in dimension 0 = %v", chunks)
+	}
+
+	tests := []struct {
+		name  string
+		dim   int32
+		keep  func(world.ChunkPos) bool
+		times []int64
+		to    []string
+	}{
+		{
+			name:  "whole dimension",
+			times: []int64{1, 2, 3, 4, 4},
+			to:    []string{"minecraft:dirt", "minecraft:sand", "minecraft:stone", "minecraft:air", "minecraft:torch"},
+		},
+		{
+			name:  "one chunk",
+			keep:  func(pos world.ChunkPos) bool { return pos == world.ChunkPos{0, 0} },
+			times: []int64{3, 4, 4},
+			to:    []string{"minecraft:stone", "minecraft:air", "minecraft:torch"},
+		},
+		{
+			name:  "other dimension",
+			dim:   1,
+			times: []int64{2},
+			to:    []string{"minecraft:netherrack"},
+		},
+		{
+			name: "nothing kept",
+			keep: func(world.ChunkPos) bool { return false },
+		},
+		{
+			name: "no history",
+			dim:  2,
+		},
+	}
+	for _, tt := range tests {
+		entries, err := ReadChunks(dir, tt.dim, tt.keep)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		var times []int64
+		var to []string
+		for _, e := range entries {
+			times = append(times, e.Time)
+			to = append(to, e.To.Name)
+			if e.Dimension != tt.dim {
+				t.Errorf("%s: entry of dimension %d", tt.name, e.Dimension)
+			}
+		}
+		if !slices.Equal(times, tt.times) || !slices.Equal(to, tt.to) {
+			t.Errorf("%s: got %v %v, want %v %v", tt.name, times, to, tt.times, tt.to)
+		}
+	}
+}
+
+func TestReadChunksRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	e := entry(7, 0, -5, -60, 20, "minecraft:air", "minecraft:wheat")
+	e.Layer = 1
+	if err := Append(dir, []Entry{e}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ReadChunks(dir, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// states come back as int32 like the block registry uses them
+	if len(entries) != 1 || !reflect.DeepEqual(entries[0], e) {
+		t.Errorf("got %+v, want %+v", entries, e)
+	}
+}
+
+func TestChunksIgnoresOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"0_1_2.jsonl", "0_1_2.jsonl.tmp", "notes.txt", "0_x_2.jsonl"} {
+		if err := os.WriteFile(path.Join(dir, name), nil, 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chunks, err := Chunks(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []world.ChunkPos{{1, 2}}; !slices.Equal(chunks, want) {
+		t.Errorf("chunks = %v, want %v", chunks, want)
+	}
+
+	chunks, err = Chunks(path.Join(dir, "missing"), 0)
+	if err != nil || chunks != nil {
+		t.Errorf("missing folder = %v, %v", chunks, err)
+	}
+}
@@ -23,6 +23,18 @@ func isBlockLightblocking(b world.Block) bool {
 var waterColor = block.Water{}.Color()
 var notFoundColor = color.RGBA{0xff, 0, 0xff, 0xff}
 
+// baseColor is the color of a block on its own, custom blocks use the color of their texture
+func (cr *ChunkRenderer) baseColor(b world.Block) color.RGBA {
+	if b2, ok := b.(world.UnknownBlock); ok {
+		name, _ := b2.EncodeBlock()
+		if customColor, ok := cr.customBlockColors[name]; ok {
+			return customColor
+		}
+		return LookupColor(name)
+	}
+	return b.Color()
+}
+
 func (cr *ChunkRenderer) blockColorAt(c *chunk.Chunk, x uint8, y int16, z uint8) (blockColor color.RGBA) {
 	if y <= int16(c.Range().Min()) {
 		return color.RGBA{0, 0, 0, 0}
@@ -54,21 +66,7 @@ func (cr *ChunkRenderer) blockColorAt(c *chunk.Chunk, x uint8, y int16, z uint8)
 		return blockColor
 	}
 
-	if b2, ok := b.(world.UnknownBlock); ok {
-		name, _ := b2.EncodeBlock()
-		customColor, ok := cr.customBlockColors[name]
-		if ok {
-			blockColor = customColor
-			goto haveColor
-		}
-
-		blockColor = LookupColor(name)
-		goto haveColor
-	} else {
-		blockColor = b.Color()
-	}
-
-haveColor:
+	blockColor = cr.baseColor(b)
 	if blockColor.R == 0xff && blockColor.G == 0x0 && blockColor.B == 0xff {
 		if updater.Version == "" {
 			logrus.Println(b.EncodeBlock())
@@ -118,24 +116,54 @@ func (cr *ChunkRenderer) chunkGetColorAt(c *chunk.Chunk, x uint8, y int16, z uin
 }
 
 type ChunkRenderer struct {
+	// draw the block textures at TextureSize pixels per block instead of one color per block
+	Textured bool
+	// used for the grass and foliage colors in textured mode and the biome mode, defaults are used when nil
+	Biomes *world.BiomeRegistry
+	Mode   RenderMode
+	// where RenderCave starts looking down from
+	SliceY int
+
 	customBlockColors map[string]color.RGBA
+	textures          *blockTextures
 }
 
 func (cr *ChunkRenderer) ResolveColors(entries []protocol.BlockEntry, packs []resource.Pack) {
 	colors := ResolveColors(entries, packs)
 	cr.customBlockColors = colors
+	if cr.Textured {
+		cr.textures = newBlockTextures(entries, packs, "up")
+	}
+}
+
+// BlockSize is how many pixels wide a block is in the images from Chunk2Img
+func (cr *ChunkRenderer) BlockSize() int {
+	if cr.Textured {
+		return TextureSize
+	}
+	return 1
 }
 
 func (cr *ChunkRenderer) Chunk2Img(c *chunk.Chunk) *image.RGBA {
+	heights := cr.columnHeights(c)
+	if cr.Textured && cr.textures != nil {
+		return cr.chunk2ImgTextured(c, &heights)
+	}
 	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
-	hm := c.HeightMapWithWater()
 
 	for x := uint8(0); x < 16; x++ {
 		for z := uint8(0); z < 16; z++ {
-			img.SetRGBA(
-				int(x), int(z),
-				cr.chunkGetColorAt(c, x, hm.At(x, z), z),
-			)
+			y := heights[x][z]
+			var col color.RGBA
+			switch cr.Mode {
+			case RenderBiomes:
+				col = cr.biomeColor(c, x, y, z)
+			case RenderHillshade:
+				col = shadeColor(cr.blockColorAt(c, x, y, z), hillshade(&heights, int(x), int(z)))
+			default:
+				col = cr.chunkGetColorAt(c, x, y, z)
+			}
+			img.SetRGBA(int(x), int(z), col)
 		}
 	}
 	return img
@@ -17,7 +17,13 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func getTextureNames(entries []protocol.BlockEntry) map[string]string {
+// faceKeys are the keys a block can have the texture of a face under, first match wins
+var faceKeys = map[string][]string{
+	"up":   {"*", "up"},
+	"side": {"south", "sides", "side", "north", "east", "west", "*", "up"},
+}
+
+func getTextureNames(entries []protocol.BlockEntry, face string) map[string]string {
 	var res = map[string]string{}
 	for _, be := range entries {
 		if components, ok := be.Properties["components"].(map[string]any); ok {
@@ -25,9 +31,11 @@ func getTextureNames(entries []protocol.BlockEntry) map[string]string {
 				if mm, ok := mats["materials"].(map[string]any); ok {
 					mats = mm
 				}
-				instance, ok := mats["*"].(map[string]any)
-				if !ok {
-					instance, _ = mats["up"].(map[string]any)
+				var instance map[string]any
+				for _, key := range faceKeys[face] {
+					if instance, _ = mats[key].(map[string]any); instance != nil {
+						break
+					}
 				}
 				if instance != nil {
 					texture, ok := instance["texture"].(string)
@@ -43,7 +51,7 @@ func getTextureNames(entries []protocol.BlockEntry) map[string]string {
 	return res
 }
 
-func readBlocksJson(f fs.FS) (map[string]string, error) {
+func readBlocksJson(f fs.FS, face string) (map[string]string, error) {
 	blocksJsonContent, err := fs.ReadFile(f, "blocks.json")
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -75,14 +83,23 @@ func readBlocksJson(f fs.FS) (map[string]string, error) {
 		case string:
 			out[name] = textures
 		case map[string]any:
-			texs = textures["up"]
-			goto reParse
+			for _, key := range faceKeys[face] {
+				if t, ok := textures[key]; ok {
+					texs = t
+					goto reParse
+				}
+			}
 		}
 	}
 	return out, nil
 }
 
-func loadFlipbooks(f fs.FS) (map[string]string, error) {
+type flipbook struct {
+	Texture string
+	Frame   int
+}
+
+func loadFlipbooks(f fs.FS) (map[string]flipbook, error) {
 	flipbookContent, err := fs.ReadFile(f, "textures/flipbook_textures.json")
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -93,19 +110,43 @@ func loadFlipbooks(f fs.FS) (map[string]string, error) {
 	var m []struct {
 		Texture string `json:"flipbook_texture"`
 		Atlas   string `json:"atlas_tile"`
+		Frames  []any  `json:"frames"`
 	}
 	err = ParseJson(flipbookContent, &m)
 	if err != nil {
 		return nil, err
 	}
 
-	o := make(map[string]string)
+	o := make(map[string]flipbook)
 	for _, v := range m {
-		o[v.Atlas] = v.Texture
+		fb := flipbook{Texture: v.Texture}
+		if len(v.Frames) > 0 {
+			if frame, ok := v.Frames[0].(float64); ok {
+				fb.Frame = int(frame)
+			}
+		}
+		o[v.Atlas] = fb
 	}
 	return o, nil
 }
 
+// flipbookFrame cuts one square frame out of a flipbook strip
+func flipbookFrame(img image.Image, frame int) image.Image {
+	b := img.Bounds()
+	size := b.Dx()
+	if size == 0 || b.Dy() <= size {
+		return img
+	}
+	frame %= b.Dy() / size
+	r := image.Rect(b.Min.X, b.Min.Y+frame*size, b.Max.X, b.Min.Y+(frame+1)*size)
+	if s, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(r)
+	}
+	return img
+}
+
 func loadTexturesList(f fs.FS) (map[string]string, error) {
 	texturesContent, err := fs.ReadFile(f, "textures/textures_list.json")
 	if err != nil {
@@ -167,7 +208,8 @@ func loadTerrainTexture(f fs.FS) (map[string]string, error) {
 }
 
 func calculateMeanAverageColour(img image.Image) (c color.RGBA) {
-	imgSize := img.Bounds().Size()
+	bounds := img.Bounds()
+	imgSize := bounds.Size()
 
 	var redSum float64
 	var greenSum float64
@@ -175,7 +217,7 @@ func calculateMeanAverageColour(img image.Image) (c color.RGBA) {
 
 	for x := 0; x < imgSize.X; x++ {
 		for y := 0; y < imgSize.Y; y++ {
-			pixel := img.At(x, y)
+			pixel := img.At(bounds.Min.X+x, bounds.Min.Y+y)
 			col := color.RGBAModel.Convert(pixel).(color.RGBA)
 			if col.A < 128 {
 				continue
@@ -213,8 +255,17 @@ func (m *mergedFS) Open(name string) (f fs.File, err error) {
 }
 
 func ResolveColors(entries []protocol.BlockEntry, packs []resource.Pack) map[string]color.RGBA {
-	log := logrus.WithField("func", "ResolveColors")
 	colors := make(map[string]color.RGBA)
+	resolveBlockTextures(entries, packs, "up", func(block string, img image.Image) {
+		colors[block] = calculateMeanAverageColour(img)
+	})
+	return colors
+}
+
+// resolveBlockTextures calls found with the texture of face (up or side) for every block that has one in the packs,
+// earlier packs win, the merged filesystem of the packs is returned for other lookups
+func resolveBlockTextures(entries []protocol.BlockEntry, packs []resource.Pack, face string, found func(block string, img image.Image)) fs.FS {
+	log := logrus.WithField("func", "ResolveColors")
 
 	processPack := func(pack resource.Pack, merged fs.FS, textureNames map[string]string) error {
 		flipbooks, err := loadFlipbooks(pack)
@@ -233,14 +284,11 @@ func ResolveColors(entries []protocol.BlockEntry, packs []resource.Pack) map[str
 		}
 
 		for block, texture_name := range textureNames {
-			if _, ok := colors[block]; ok {
-				continue
-			}
-
 			var texturePath = texture_name
 
-			if flipbook_texture, ok := flipbooks[texturePath]; ok {
-				texturePath = flipbook_texture
+			flipbook, isFlipbook := flipbooks[texturePath]
+			if isFlipbook {
+				texturePath = flipbook.Texture
 			}
 
 			if terrain_texture, ok := terrainTextures[texturePath]; ok {
@@ -255,50 +303,33 @@ func ResolveColors(entries []protocol.BlockEntry, packs []resource.Pack) map[str
 				continue
 			}
 
-			var img image.Image
-			for _, format := range []string{".png", ".tga"} {
-				r, err := merged.Open(texturePath + format)
-				if errors.Is(err, fs.ErrNotExist) {
-					continue
-				}
-				if err != nil {
-					log.Error(err)
-					continue
-				}
-
-				switch format {
-				case ".png":
-					img, err = png.Decode(r)
-				case ".tga":
-					img, err = tga.Decode(r)
-				default:
-					panic("invalid extension")
-				}
-				r.Close()
-				if err != nil {
-					return err
-				}
-				break
+			img, err := openTexture(merged, texturePath)
+			if err != nil {
+				return err
 			}
 			if img == nil {
 				continue
 			}
+			if isFlipbook {
+				img = flipbookFrame(img, flipbook.Frame)
+			}
 
-			colors[block] = calculateMeanAverageColour(img)
+			found(block, img)
 			delete(textureNames, block)
 		}
 
 		return nil
 	}
 
-	textureNames := getTextureNames(entries)
+	textureNames := getTextureNames(entries, face)
 	var blockPacks []resource.Pack
 	var merged mergedFS
 	for _, pack := range packs {
-		blocksJson, err := readBlocksJson(pack)
+		blocksJson, err := readBlocksJson(pack, face)
 		if err != nil {
 			logrus.Error(err)
 		}
+		merged.fss = append(merged.fss, pack)
 		if blocksJson == nil {
 			continue
 		}
@@ -308,7 +339,6 @@ func ResolveColors(entries []protocol.BlockEntry, packs []resource.Pack) map[str
 		}
 
 		blockPacks = append(blockPacks, pack)
-		merged.fss = append(merged.fss, pack)
 	}
 
 	for _, pack := range blockPacks {
@@ -318,5 +348,32 @@ func ResolveColors(entries []protocol.BlockEntry, packs []resource.Pack) map[str
 		}
 	}
 
-	return colors
+	return &merged
+}
+
+// openTexture reads a png or tga texture, nil if there is none
+func openTexture(f fs.FS, texturePath string) (image.Image, error) {
+	for _, format := range []string{".png", ".tga"} {
+		r, err := f.Open(texturePath + format)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			logrus.Error(err)
+			continue
+		}
+
+		var img image.Image
+		switch format {
+		case ".png":
+			img, err = png.Decode(r)
+		case ".tga":
+			img, err = tga.Decode(r)
+		default:
+			panic("invalid extension")
+		}
+		r.Close()
+		return img, err
+	}
+	return nil, nil
 }
@@ -161,3 +161,29 @@ func (m multiCloser) Close() error {
 	}
 	return nil
 }
+
+// WriteMcworld zips folder into filename, the zip is written next to filename and only renamed once complete
+// so a crash never leaves a broken mcworld
+func WriteMcworld(filename, folder string) error {
+	tmpName := filename + ".tmp"
+	f, err := os.Create(tmpName)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+	ZipCompressPool(zw)
+	err = zw.AddFS(os.DirFS(folder))
+	if err == nil {
+		err = zw.Close()
+	}
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Rename(tmpName, filename)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+	}
+	return err
+}
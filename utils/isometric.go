@@ -0,0 +1,483 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/block"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/resource"
+	"github.com/sirupsen/logrus"
+)
+
+// light of the faces, the sun is above and a bit to the south
+const (
+	isoLightTop    = 1.0
+	isoLightSouth  = 0.8
+	isoLightEast   = 0.62
+	isoLightShadow = 0.7 // blocks that dont see the sky
+	isoWaterAlpha  = 0.65
+)
+
+// isoBox is a cuboid of a block in block units, 0 to 1 is a full block
+type isoBox struct {
+	min, max [3]float64
+}
+
+var fullBox = []isoBox{{max: [3]float64{1, 1, 1}}}
+
+// isoBlock is what the renderer knows about a runtime id
+type isoBlock struct {
+	name   string
+	block  world.Block
+	air    bool
+	water  bool
+	boxes  []isoBox
+	opaque bool // a full block that hides everything behind it
+}
+
+// IsometricRenderer draws blocks from the south east at an angle with their textures, on the cpu
+type IsometricRenderer struct {
+	cr *ChunkRenderer
+	// pixels per block edge
+	scale    int
+	top      *blockTextures
+	side     *blockTextures
+	geometry map[string][]isoBox
+	blocks   map[uint32]*isoBlock
+}
+
+func NewIsometricRenderer(cr *ChunkRenderer, entries []protocol.BlockEntry, packs []resource.Pack, scale int) *IsometricRenderer {
+	r := &IsometricRenderer{
+		cr:       cr,
+		scale:    scale,
+		top:      newBlockTextures(entries, packs, "up"),
+		side:     newBlockTextures(entries, packs, "side"),
+		geometry: make(map[string][]isoBox),
+		blocks:   make(map[uint32]*isoBlock),
+	}
+
+	geometries := make(map[string][]isoBox)
+	for _, pack := range packs {
+		if err := loadGeometries(pack, geometries); err != nil {
+			logrus.Warn(err)
+		}
+	}
+	for _, entry := range entries {
+		if boxes := customBlockBoxes(entry, geometries); boxes != nil {
+			r.geometry[entry.Name] = boxes
+		}
+	}
+	return r
+}
+
+// loadGeometries reads the cubes of all block models in a pack
+func loadGeometries(pack fs.FS, out map[string][]isoBox) error {
+	return fs.WalkDir(pack, "models", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == "models" {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != ".json" {
+			return nil
+		}
+		data, err := fs.ReadFile(pack, p)
+		if err != nil {
+			return err
+		}
+		var geo struct {
+			Geometry []struct {
+				Description struct {
+					Identifier string `json:"identifier"`
+				} `json:"description"`
+				Bones []struct {
+					Cubes []struct {
+						Origin [3]float64 `json:"origin"`
+						Size   [3]float64 `json:"size"`
+					} `json:"cubes"`
+				} `json:"bones"`
+			} `json:"minecraft:geometry"`
+		}
+		if err := ParseJson(data, &geo); err != nil {
+			// entity models in older formats, not needed here
+			return nil
+		}
+		for _, g := range geo.Geometry {
+			var boxes []isoBox
+			for _, bone := range g.Bones {
+				for _, c := range bone.Cubes {
+					boxes = append(boxes, pixelBox(c.Origin, c.Size))
+				}
+			}
+			if len(boxes) > 0 {
+				out[g.Description.Identifier] = boxes
+			}
+		}
+		return nil
+	})
+}
+
+// pixelBox converts a model cube to block units, models have x mirrored and are centered on x and z
+func pixelBox(origin, size [3]float64) isoBox {
+	clamp := func(v float64) float64 { return min(max(v, 0), 1) }
+	x0 := (8 - origin[0] - size[0]) / 16
+	y0 := origin[1] / 16
+	z0 := (origin[2] + 8) / 16
+	return isoBox{
+		min: [3]float64{clamp(x0), clamp(y0), clamp(z0)},
+		max: [3]float64{clamp(x0 + size[0]/16), clamp(y0 + size[1]/16), clamp(z0 + size[2]/16)},
+	}
+}
+
+// customBlockBoxes is the shape of a custom block from its geometry, or its collision box if the geometry isnt known
+func customBlockBoxes(entry protocol.BlockEntry, geometries map[string][]isoBox) []isoBox {
+	components, ok := entry.Properties["components"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	var identifier string
+	switch g := components["minecraft:geometry"].(type) {
+	case string:
+		identifier = g
+	case map[string]any:
+		identifier, _ = g["identifier"].(string)
+	}
+	if identifier == "minecraft:geometry.full_block" {
+		return nil
+	}
+	if boxes, ok := geometries[identifier]; ok {
+		return boxes
+	}
+
+	box, ok := components["minecraft:collision_box"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	origin, ok1 := floats3(box["origin"])
+	size, ok2 := floats3(box["size"])
+	if !ok1 || !ok2 {
+		return nil
+	}
+	return []isoBox{pixelBox(origin, size)}
+}
+
+func floats3(v any) (out [3]float64, ok bool) {
+	list, ok := v.([]any)
+	if !ok || len(list) != 3 {
+		return out, false
+	}
+	for i, f := range list {
+		switch f := f.(type) {
+		case float32:
+			out[i] = float64(f)
+		case float64:
+			out[i] = f
+		default:
+			return out, false
+		}
+	}
+	return out, true
+}
+
+// vanillaBoxes is the shape of the common vanilla blocks that arent full
+func vanillaBoxes(name string, properties map[string]any) []isoBox {
+	switch {
+	case strings.HasSuffix(name, "_slab") && !strings.Contains(name, "double"):
+		if properties["minecraft:vertical_half"] == "top" {
+			return []isoBox{{min: [3]float64{0, 0.5, 0}, max: [3]float64{1, 1, 1}}}
+		}
+		return []isoBox{{max: [3]float64{1, 0.5, 1}}}
+	case strings.HasSuffix(name, "carpet"):
+		return []isoBox{{max: [3]float64{1, 1.0 / 16, 1}}}
+	case name == "minecraft:snow_layer":
+		height, _ := properties["height"].(int32)
+		return []isoBox{{max: [3]float64{1, float64(height+1) / 8, 1}}}
+	}
+	return fullBox
+}
+
+func (r *IsometricRenderer) blockInfo(c *chunk.Chunk, rid uint32) *isoBlock {
+	if b, ok := r.blocks[rid]; ok {
+		return b
+	}
+	info := &isoBlock{}
+	br := c.BlockRegistry.(world.BlockRegistry)
+	b, found := br.BlockByRuntimeID(rid)
+	if !found {
+		info.air = true
+		r.blocks[rid] = info
+		return info
+	}
+	name, properties := b.EncodeBlock()
+	info.name = name
+	info.block = b
+	_, info.air = b.(block.Air)
+	_, info.water = b.(block.Water)
+	if boxes, ok := r.geometry[name]; ok {
+		info.boxes = boxes
+	} else {
+		info.boxes = vanillaBoxes(name, properties)
+	}
+	if len(info.boxes) == 1 && info.boxes[0] == fullBox[0] && !info.water {
+		tex, ok := r.top.textures[name]
+		info.opaque = isBlockLightblocking(b) && (!ok || tex.opaque)
+	}
+	r.blocks[rid] = info
+	return info
+}
+
+// texture returns the texture for the top or side of the block with the biome tint, or a flat color
+func (r *IsometricRenderer) texture(textures *blockTextures, info *isoBlock, c *chunk.Chunk, x uint8, y int16, z uint8, isTop bool) *blockTexture {
+	tex, ok := textures.textures[info.name]
+	if !ok {
+		if tex, ok = r.top.textures[info.name]; !ok {
+			return r.flatTexture(textures, info)
+		}
+	}
+	switch {
+	case info.water:
+		return textures.tint(info.name, tex, waterTint)
+	case grassTinted[info.name] && (isTop || info.name != "minecraft:grass_block" && info.name != "minecraft:grass"):
+		return textures.tint(info.name, tex, r.cr.biomeTint(c, x, y, z, r.top.grassColormap, defaultGrassColor))
+	case foliageTinted[info.name]:
+		return textures.tint(info.name, tex, r.cr.biomeTint(c, x, y, z, r.top.foliageColormap, defaultFoliageColor))
+	}
+	if tint, ok := fixedTints[info.name]; ok {
+		return textures.tint(info.name, tex, tint)
+	}
+	return tex
+}
+
+// flatTexture is a single color texture for blocks without one, cached like the tinted ones
+func (r *IsometricRenderer) flatTexture(textures *blockTextures, info *isoBlock) *blockTexture {
+	col := r.cr.baseColor(info.block)
+	textures.l.Lock()
+	defer textures.l.Unlock()
+	key := tintKey{"flat:" + info.name, col}
+	if tex, ok := textures.tinted[key]; ok {
+		return tex
+	}
+	img := image.NewRGBA(image.Rect(0, 0, TextureSize, TextureSize))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = col.R, col.G, col.B, 0xff
+	}
+	tex := &blockTexture{img: img, opaque: true}
+	textures.tinted[key] = tex
+	return tex
+}
+
+type isoPoint struct{ x, y float64 }
+
+func (p isoPoint) sub(o isoPoint) isoPoint { return isoPoint{p.x - o.x, p.y - o.y} }
+
+// IsoCanvas is the image with the projection of an area on it, drawn a part at a time with DrawPart
+type IsoCanvas struct {
+	img        *image.RGBA
+	scale      float64
+	offX, offY float64
+	minX, minZ int
+	maxX, maxZ int
+	minY, maxY int
+	chunks     map[world.ChunkPos]*chunk.Chunk
+}
+
+// project turns a block position into a pixel, x goes to the bottom right and z to the bottom left
+func (cv *IsoCanvas) project(x, y, z float64) isoPoint {
+	return isoPoint{
+		x: (x-z)*cv.scale + cv.offX,
+		y: (x+z)*cv.scale/2 - y*cv.scale + cv.offY,
+	}
+}
+
+// drawFace fills the parallelogram o, o+u, o+v with the part uv (u0, v0, u1, v1) of the texture
+func (cv *IsoCanvas) drawFace(o, u, v isoPoint, tex *image.RGBA, uv [4]float64, light, alpha float64) {
+	det := u.x*v.y - u.y*v.x
+	if math.Abs(det) < 1e-9 {
+		return
+	}
+	minP := isoPoint{min(o.x, o.x+u.x, o.x+v.x, o.x+u.x+v.x), min(o.y, o.y+u.y, o.y+v.y, o.y+u.y+v.y)}
+	maxP := isoPoint{max(o.x, o.x+u.x, o.x+v.x, o.x+u.x+v.x), max(o.y, o.y+u.y, o.y+v.y, o.y+u.y+v.y)}
+	bounds := image.Rect(int(math.Floor(minP.x)), int(math.Floor(minP.y)), int(math.Ceil(maxP.x)), int(math.Ceil(maxP.y))).Intersect(cv.img.Rect)
+	size := float64(tex.Rect.Dx())
+
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			d := isoPoint{float64(px) + 0.5, float64(py) + 0.5}.sub(o)
+			a := (d.x*v.y - d.y*v.x) / det
+			b := (u.x*d.y - u.y*d.x) / det
+			if a < 0 || a >= 1 || b < 0 || b >= 1 {
+				continue
+			}
+			tx := min(int((uv[0]+a*(uv[2]-uv[0]))*size), int(size)-1)
+			ty := min(int((uv[1]+b*(uv[3]-uv[1]))*size), int(size)-1)
+			s := tex.RGBAAt(tx, ty)
+			if s.A == 0 {
+				continue
+			}
+			// premultiplied, so light only scales the color and alpha scales everything
+			sr, sg, sb := float64(s.R)*light*alpha, float64(s.G)*light*alpha, float64(s.B)*light*alpha
+			sa := float64(s.A) * alpha
+			i := cv.img.PixOffset(px, py)
+			p := cv.img.Pix[i : i+4 : i+4]
+			inv := 1 - sa/0xff
+			p[0] = uint8(sr + float64(p[0])*inv)
+			p[1] = uint8(sg + float64(p[1])*inv)
+			p[2] = uint8(sb + float64(p[2])*inv)
+			p[3] = uint8(sa + float64(p[3])*inv)
+		}
+	}
+}
+
+func (cv *IsoCanvas) chunkAt(x, z int) (*chunk.Chunk, uint8, uint8) {
+	c, ok := cv.chunks[world.ChunkPos{int32(x >> 4), int32(z >> 4)}]
+	if !ok {
+		return nil, 0, 0
+	}
+	return c, uint8(x & 15), uint8(z & 15)
+}
+
+func (r *IsometricRenderer) blockAt(cv *IsoCanvas, x, y, z int) *isoBlock {
+	if x < cv.minX || x > cv.maxX || z < cv.minZ || z > cv.maxZ || y < cv.minY || y > cv.maxY {
+		return nil
+	}
+	c, lx, lz := cv.chunkAt(x, z)
+	if c == nil {
+		return nil
+	}
+	return r.blockInfo(c, c.Block(lx, int16(y), lz, 0))
+}
+
+// hides is true when the neighbour n covers the face of b towards it
+func hides(b, n *isoBlock) bool {
+	if n == nil || n.air {
+		return false
+	}
+	if n.opaque {
+		return true
+	}
+	// glass next to glass and water next to water dont draw the faces between them
+	return b.opaque == n.opaque && b.name == n.name && len(b.boxes) == 1 && b.boxes[0] == fullBox[0]
+}
+
+func (r *IsometricRenderer) drawBlock(cv *IsoCanvas, x, y, z int) {
+	c, lx, lz := cv.chunkAt(x, z)
+	if c == nil {
+		return
+	}
+	info := r.blockInfo(c, c.Block(lx, int16(y), lz, 0))
+	if info.air {
+		return
+	}
+	above := r.blockAt(cv, x, y+1, z)
+	south := r.blockAt(cv, x, y, z+1)
+	east := r.blockAt(cv, x+1, y, z)
+	full := len(info.boxes) == 1 && info.boxes[0] == fullBox[0]
+	if full && hides(info, above) && hides(info, south) && hides(info, east) {
+		return
+	}
+
+	shadow := 1.0
+	if int16(y) < c.HeightMap().At(lx, lz) {
+		shadow = isoLightShadow
+	}
+	alpha := 1.0
+	if info.water {
+		alpha = isoWaterAlpha
+	}
+	top := r.texture(r.top, info, c, lx, int16(y), lz, true).img
+	side := r.texture(r.side, info, c, lx, int16(y), lz, false).img
+
+	fx, fy, fz := float64(x), float64(y), float64(z)
+	for _, box := range info.boxes {
+		x0, y0, z0 := fx+box.min[0], fy+box.min[1], fz+box.min[2]
+		x1, y1, z1 := fx+box.max[0], fy+box.max[1], fz+box.max[2]
+
+		if !(box.max[1] == 1 && hides(info, above)) {
+			o := cv.project(x0, y1, z0)
+			cv.drawFace(o, cv.project(x1, y1, z0).sub(o), cv.project(x0, y1, z1).sub(o), top,
+				[4]float64{box.min[0], box.min[2], box.max[0], box.max[2]}, isoLightTop*shadow, alpha)
+		}
+		if !(box.max[2] == 1 && hides(info, south)) {
+			o := cv.project(x0, y1, z1)
+			cv.drawFace(o, cv.project(x1, y1, z1).sub(o), cv.project(x0, y0, z1).sub(o), side,
+				[4]float64{box.min[0], 1 - box.max[1], box.max[0], 1 - box.min[1]}, isoLightSouth*shadow, alpha)
+		}
+		if !(box.max[0] == 1 && hides(info, east)) {
+			o := cv.project(x1, y1, z1)
+			cv.drawFace(o, cv.project(x1, y1, z0).sub(o), cv.project(x1, y0, z1).sub(o), side,
+				[4]float64{1 - box.max[2], 1 - box.max[1], 1 - box.min[2], 1 - box.min[1]}, isoLightEast*shadow, alpha)
+		}
+	}
+}
+
+// ImageSize is the size of the image for the blocks between from and to
+func (r *IsometricRenderer) ImageSize(from, to cube.Pos) image.Point {
+	sizeX := to.X() - from.X() + 1
+	sizeY := to.Y() - from.Y() + 1
+	sizeZ := to.Z() - from.Z() + 1
+	return image.Pt((sizeX+sizeZ)*r.scale, (sizeX+sizeZ)*r.scale/2+sizeY*r.scale)
+}
+
+// NewCanvas makes an empty image for the blocks between from and to
+func (r *IsometricRenderer) NewCanvas(from, to cube.Pos) *IsoCanvas {
+	cv := &IsoCanvas{
+		scale: float64(r.scale),
+		minX:  from.X(),
+		minY:  from.Y(),
+		minZ:  from.Z(),
+		maxX:  to.X(),
+		maxY:  to.Y(),
+		maxZ:  to.Z(),
+	}
+	sizeY := to.Y() - from.Y() + 1
+	sizeZ := to.Z() - from.Z() + 1
+	cv.img = image.NewRGBA(image.Rectangle{Max: r.ImageSize(from, to)})
+	// the north west top corner of the area is at the top middle
+	cv.offX = float64(sizeZ*r.scale) - float64(from.X()-from.Z())*cv.scale
+	cv.offY = float64((sizeY+from.Y())*r.scale) - float64(from.X()+from.Z())*cv.scale/2
+	return cv
+}
+
+// Image returns what has been drawn so far
+func (cv *IsoCanvas) Image() *image.RGBA {
+	return cv.img
+}
+
+// DrawPart draws the columns between from and to onto the canvas, the height is the one of the canvas.
+// Parts have to be drawn north to south and west to east within a row,
+// chunks needs the chunks one south and east of the part too so the faces between parts are hidden, missing chunks are left empty
+func (r *IsometricRenderer) DrawPart(cv *IsoCanvas, chunks map[world.ChunkPos]*chunk.Chunk, from, to cube.Pos) {
+	cv.chunks = chunks
+	defer func() { cv.chunks = nil }()
+	minX, maxX := max(from.X(), cv.minX), min(to.X(), cv.maxX)
+	minZ, maxZ := max(from.Z(), cv.minZ), min(to.Z(), cv.maxZ)
+
+	// back to front, bottom to top
+	for y := cv.minY; y <= cv.maxY; y++ {
+		for z := minZ; z <= maxZ; z++ {
+			for x := minX; x <= maxX; x++ {
+				r.drawBlock(cv, x, y, z)
+			}
+		}
+	}
+}
+
+// Background fills the transparent pixels of img with a color
+func Background(img *image.RGBA, bg color.RGBA) {
+	for i := 0; i < len(img.Pix); i += 4 {
+		a := 0xff - uint16(img.Pix[i+3])
+		img.Pix[i] += uint8(uint16(bg.R) * a / 0xff)
+		img.Pix[i+1] += uint8(uint16(bg.G) * a / 0xff)
+		img.Pix[i+2] += uint8(uint16(bg.B) * a / 0xff)
+		img.Pix[i+3] = 0xff
+	}
+}
@@ -0,0 +1,55 @@
+//go:build ignore
+
+// downloads the leaflet release into dist so it gets embedded, run with go generate
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+const version = "1.9.4"
+
+var files = []string{
+	"leaflet.js",
+	"leaflet.css",
+	"images/layers.png",
+	"images/layers-2x.png",
+	"images/marker-icon.png",
+	"images/marker-icon-2x.png",
+	"images/marker-shadow.png",
+}
+
+func fetch(name string) error {
+	res, err := http.Get("https://unpkg.com/leaflet@" + version + "/dist/" + name)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", name, res.Status)
+	}
+	out := filepath.Join("dist", filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(out), 0o777); err != nil {
+		return err
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, res.Body)
+	return err
+}
+
+func main() {
+	for _, name := range files {
+		if err := fetch(name); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
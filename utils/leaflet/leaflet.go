@@ -0,0 +1,55 @@
+// Package leaflet embeds the leaflet map library so the maps work without internet
+package leaflet
+
+import (
+	"embed"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+//go:generate go run fetch.go
+
+//go:embed all:dist
+var dist embed.FS
+
+// FS returns the leaflet files, leaflet.js, leaflet.css and the images they use
+func FS() fs.FS {
+	sub, _ := fs.Sub(dist, "dist")
+	return sub
+}
+
+// Handler serves the leaflet files, mount it with http.StripPrefix
+func Handler() http.Handler {
+	return http.FileServer(http.FS(FS()))
+}
+
+// WriteFiles copies the leaflet files into folder, for pages that are opened from disk
+func WriteFiles(folder string) error {
+	return fs.WalkDir(FS(), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == "README.md" {
+			return nil
+		}
+		out := filepath.Join(folder, filepath.FromSlash(name))
+		if d.IsDir() {
+			return os.MkdirAll(out, 0o777)
+		}
+		r, err := FS().Open(name)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		w, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		_, err = io.Copy(w, r)
+		return err
+	})
+}
@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/block"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+)
+
+// RenderMode is what Chunk2Img draws for every column
+type RenderMode int
+
+const (
+	// RenderSurface draws the top block with water
+	RenderSurface RenderMode = iota
+	// RenderHillshade is the surface lit from the north west
+	RenderHillshade
+	// RenderBiomes draws a color per biome
+	RenderBiomes
+	// RenderCave draws the floor of the first open space below ChunkRenderer.SliceY
+	RenderCave
+	// RenderNether draws the floor under the bedrock ceiling
+	RenderNether
+)
+
+var renderModeNames = []string{"surface", "hillshade", "biome", "cave", "nether"}
+
+func (m RenderMode) String() string {
+	if int(m) < len(renderModeNames) {
+		return renderModeNames[m]
+	}
+	return "RenderMode(" + strconv.Itoa(int(m)) + ")"
+}
+
+// RenderModeNames lists the names ParseRenderMode accepts
+func RenderModeNames() []string {
+	return renderModeNames
+}
+
+func ParseRenderMode(s string) (RenderMode, error) {
+	for i, name := range renderModeNames {
+		if strings.EqualFold(s, name) {
+			return RenderMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown render mode %q, use one of %s", s, strings.Join(renderModeNames, ", "))
+}
+
+// biome colors, mostly the same as other map tools use
+var biomeColors = map[string]color.RGBA{
+	"ocean":                   {0x00, 0x00, 0x70, 0xff},
+	"deep_ocean":              {0x00, 0x00, 0x30, 0xff},
+	"warm_ocean":              {0x00, 0x00, 0xac, 0xff},
+	"lukewarm_ocean":          {0x00, 0x00, 0x90, 0xff},
+	"cold_ocean":              {0x20, 0x20, 0x70, 0xff},
+	"frozen_ocean":            {0x70, 0x70, 0xd6, 0xff},
+	"river":                   {0x00, 0x00, 0xff, 0xff},
+	"frozen_river":            {0xa0, 0xa0, 0xff, 0xff},
+	"beach":                   {0xfa, 0xde, 0x55, 0xff},
+	"plains":                  {0x8d, 0xb3, 0x60, 0xff},
+	"sunflower_plains":        {0xb5, 0xdb, 0x88, 0xff},
+	"snowy_plains":            {0xff, 0xff, 0xff, 0xff},
+	"desert":                  {0xfa, 0x94, 0x18, 0xff},
+	"windswept_hills":         {0x60, 0x60, 0x60, 0xff},
+	"forest":                  {0x05, 0x66, 0x21, 0xff},
+	"flower_forest":           {0x2d, 0x8e, 0x49, 0xff},
+	"birch_forest":            {0x30, 0x74, 0x44, 0xff},
+	"dark_forest":             {0x40, 0x51, 0x1a, 0xff},
+	"taiga":                   {0x0b, 0x66, 0x59, 0xff},
+	"snowy_taiga":             {0x31, 0x55, 0x4a, 0xff},
+	"old_growth_pine_taiga":   {0x59, 0x66, 0x51, 0xff},
+	"old_growth_spruce_taiga": {0x81, 0x8e, 0x79, 0xff},
+	"swamp":                   {0x07, 0xf9, 0xb2, 0xff},
+	"mangrove_swamp":          {0x2c, 0xcc, 0x8e, 0xff},
+	"jungle":                  {0x53, 0x7b, 0x09, 0xff},
+	"bamboo_jungle":           {0x76, 0x8e, 0x14, 0xff},
+	"savanna":                 {0xbd, 0xb2, 0x5f, 0xff},
+	"badlands":                {0xd9, 0x45, 0x15, 0xff},
+	"mushroom_fields":         {0xff, 0x00, 0xff, 0xff},
+	"meadow":                  {0x60, 0xa4, 0x45, 0xff},
+	"cherry_grove":            {0xff, 0xb7, 0xc5, 0xff},
+	"grove":                   {0x47, 0x72, 0x5a, 0xff},
+	"snowy_slopes":            {0xc4, 0xc4, 0xc4, 0xff},
+	"jagged_peaks":            {0xdc, 0xdc, 0xc8, 0xff},
+	"frozen_peaks":            {0xb0, 0xb3, 0xce, 0xff},
+	"stony_peaks":             {0x7b, 0x8f, 0x74, 0xff},
+	"deep_dark":               {0x1f, 0x1f, 0x1f, 0xff},
+	"lush_caves":              {0x4c, 0x7a, 0x1f, 0xff},
+	"dripstone_caves":         {0x7d, 0x5d, 0x3c, 0xff},
+	"nether_wastes":           {0xbf, 0x3b, 0x3b, 0xff},
+	"crimson_forest":          {0xdd, 0x08, 0x08, 0xff},
+	"warped_forest":           {0x49, 0x90, 0x7b, 0xff},
+	"soulsand_valley":         {0x52, 0x29, 0x21, 0xff},
+	"basalt_deltas":           {0x40, 0x36, 0x36, 0xff},
+	"the_end":                 {0x80, 0x80, 0xff, 0xff},
+}
+
+// biomeColor returns the color of the biome at the position, biomes without one get a color from their name
+func (cr *ChunkRenderer) biomeColor(c *chunk.Chunk, x uint8, y int16, z uint8) color.RGBA {
+	id := c.Biome(x, y, z)
+	name := strconv.Itoa(int(id))
+	if cr.Biomes != nil {
+		if biome, ok := cr.Biomes.BiomeByID(int(id)); ok {
+			name = biome.String()
+		}
+	}
+	if col, ok := biomeColors[name]; ok {
+		return col
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	sum := h.Sum32()
+	return color.RGBA{uint8(sum), uint8(sum >> 8), uint8(sum >> 16), 0xff}
+}
+
+func isAir(br world.BlockRegistry, c *chunk.Chunk, x uint8, y int16, z uint8) bool {
+	b, found := br.BlockByRuntimeID(c.Block(x, y, z, 0))
+	if !found {
+		return false
+	}
+	_, ok := b.(block.Air)
+	return ok
+}
+
+// caveFloor goes down from top out of the ceiling and returns the first block under the open space
+func caveFloor(c *chunk.Chunk, x uint8, z uint8, top int16) int16 {
+	br := c.BlockRegistry.(world.BlockRegistry)
+	return floorBelow(int16(c.Range().Min()), min(top, int16(c.Range().Max())), func(y int16) bool {
+		return isAir(br, c, x, y, z)
+	})
+}
+
+// floorBelow is caveFloor for one column, it stops at minY
+func floorBelow(minY, top int16, air func(y int16) bool) int16 {
+	y := top
+	for ; y > minY && !air(y); y-- {
+	}
+	for ; y > minY && air(y); y-- {
+	}
+	return y
+}
+
+// columnHeights returns the y of the block to draw for every column
+func (cr *ChunkRenderer) columnHeights(c *chunk.Chunk) (heights [16][16]int16) {
+	hm := c.HeightMapWithWater()
+	for x := uint8(0); x < 16; x++ {
+		for z := uint8(0); z < 16; z++ {
+			switch cr.Mode {
+			case RenderCave:
+				heights[x][z] = caveFloor(c, x, z, int16(cr.SliceY))
+			case RenderNether:
+				heights[x][z] = caveFloor(c, x, z, int16(c.Range().Max()))
+			default:
+				heights[x][z] = hm.At(x, z)
+			}
+		}
+	}
+	return heights
+}
+
+// hillshade is how much lighter a column is than flat ground, negative is darker
+func hillshade(heights *[16][16]int16, x, z int) int {
+	// slope towards the north west, on the chunk edge the slope from the other side is used so there are no seams
+	slope := func(h, prev, next int16, first bool) int {
+		if first {
+			return int(next - h)
+		}
+		return int(h - prev)
+	}
+	h := heights[x][z]
+	dx := slope(h, heights[max(x-1, 0)][z], heights[min(x+1, 15)][z], x == 0)
+	dz := slope(h, heights[x][max(z-1, 0)], heights[x][min(z+1, 15)], z == 0)
+	return min(max((dx+dz)*12, -60), 60)
+}
+
+func shadeColor(c color.RGBA, shade int) color.RGBA {
+	add := func(v uint8) uint8 {
+		return uint8(min(max(int(v)+shade, 0), 0xff))
+	}
+	return color.RGBA{add(c.R), add(c.G), add(c.B), c.A}
+}
@@ -0,0 +1,94 @@
+package utils
+
+import "testing"
+
+func TestParseRenderMode(t *testing.T) {
+	tests := []struct {
+		s    string
+		want RenderMode
+		err  bool
+	}{
+		{s: "surface", want: RenderSurface},
+		{s: "hillshade", want: RenderHillshade},
+		{s: "biome", want: RenderBiomes},
+		{s: "Cave", want: RenderCave},
+		{s: "NETHER", want: RenderNether},
+		{s: "biomes", err: true},
+		{s: "", err: true},
+		{s: "isometric", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRenderMode(tt.s)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseRenderMode(%q) error = %v", tt.s, err)
+			continue
+		}
+		if !tt.err && got != tt.want {
+			t.Errorf("ParseRenderMode(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+
+	// every name parses back to its mode
+	for _, name := range RenderModeNames() {
+		mode, err := ParseRenderMode(name)
+		if err != nil || mode.String() != name {
+			t.Errorf("ParseRenderMode(%q) = %v, %v", name, mode, err)
+		}
+	}
+	if s := RenderMode(42).String(); s != "RenderMode(42)" {
+		t.Errorf("RenderMode(42).String() = %q", s)
+	}
+}
+
+func TestHillshade(t *testing.T) {
+	tests := []struct {
+		name   string
+		height func(x, z int) int16
+		x, z   int
+		want   int
+	}{
+		{"flat", func(x, z int) int16 { return 64 }, 5, 5, 0},
+		{"rising east", func(x, z int) int16 { return int16(x) }, 5, 5, 12},
+		{"rising south east", func(x, z int) int16 { return int16(x + z) }, 5, 5, 24},
+		{"falling south", func(x, z int) int16 { return int16(-z) }, 5, 5, -12},
+		// on the west and north edge the slope to the next column is used
+		{"west edge", func(x, z int) int16 { return int16(2 * x) }, 0, 3, 24},
+		{"north edge", func(x, z int) int16 { return int16(-z) }, 3, 0, -12},
+		{"east edge", func(x, z int) int16 { return int16(x) }, 15, 3, 12},
+		{"clamped light", func(x, z int) int16 { return int16(10 * x) }, 5, 5, 60},
+		{"clamped dark", func(x, z int) int16 { return int16(-10 * (x + z)) }, 5, 5, -60},
+	}
+	for _, tt := range tests {
+		var heights [16][16]int16
+		for x := 0; x < 16; x++ {
+			for z := 0; z < 16; z++ {
+				heights[x][z] = tt.height(x, z)
+			}
+		}
+		if got := hillshade(&heights, tt.x, tt.z); got != tt.want {
+			t.Errorf("%s: hillshade(%d, %d) = %d, want %d", tt.name, tt.x, tt.z, got, tt.want)
+		}
+	}
+}
+
+func TestFloorBelow(t *testing.T) {
+	tests := []struct {
+		name   string
+		column string // from y 0 up, # is a block and . is air
+		top    int16
+		want   int16
+	}{
+		{"cave under the ceiling", "###....####", 10, 2},
+		{"starts in the open", "###.......", 9, 2},
+		{"two caves, the first is used", "#..##...###", 10, 4},
+		{"top below the cave", "###....####", 2, 0},
+		{"no floor", "..........", 9, 0},
+		{"solid", "##########", 9, 0},
+	}
+	for _, tt := range tests {
+		air := func(y int16) bool { return tt.column[y] == '.' }
+		if got := floorBelow(0, tt.top, air); got != tt.want {
+			t.Errorf("%s: floorBelow(%q, %d) = %d, want %d", tt.name, tt.column, tt.top, got, tt.want)
+		}
+	}
+}
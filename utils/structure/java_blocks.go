@@ -0,0 +1,314 @@
+package structure
+
+import (
+	"strconv"
+	"strings"
+)
+
+// bedrock block names that are called differently in java
+var javaBlockNames = map[string]string{
+	"minecraft:brick_block":                      "minecraft:bricks",
+	"minecraft:carpet":                           "minecraft:white_carpet",
+	"minecraft:deadbush":                         "minecraft:dead_bush",
+	"minecraft:end_bricks":                       "minecraft:end_stone_bricks",
+	"minecraft:fence_gate":                       "minecraft:oak_fence_gate",
+	"minecraft:flowing_lava":                     "minecraft:lava",
+	"minecraft:flowing_water":                    "minecraft:water",
+	"minecraft:frame":                            "minecraft:air",
+	"minecraft:glow_frame":                       "minecraft:air",
+	"minecraft:golden_rail":                      "minecraft:powered_rail",
+	"minecraft:grass":                            "minecraft:grass_block",
+	"minecraft:grass_path":                       "minecraft:dirt_path",
+	"minecraft:hardened_clay":                    "minecraft:terracotta",
+	"minecraft:info_update":                      "minecraft:air",
+	"minecraft:info_update2":                     "minecraft:air",
+	"minecraft:invisible_bedrock":                "minecraft:barrier",
+	"minecraft:lit_pumpkin":                      "minecraft:jack_o_lantern",
+	"minecraft:magma":                            "minecraft:magma_block",
+	"minecraft:melon_block":                      "minecraft:melon",
+	"minecraft:mob_spawner":                      "minecraft:spawner",
+	"minecraft:monster_egg":                      "minecraft:infested_stone",
+	"minecraft:nether_brick":                     "minecraft:nether_bricks",
+	"minecraft:noteblock":                        "minecraft:note_block",
+	"minecraft:portal":                           "minecraft:nether_portal",
+	"minecraft:quartz_ore":                       "minecraft:nether_quartz_ore",
+	"minecraft:red_nether_brick":                 "minecraft:red_nether_bricks",
+	"minecraft:reeds":                            "minecraft:sugar_cane",
+	"minecraft:slime":                            "minecraft:slime_block",
+	"minecraft:snow":                             "minecraft:snow_block",
+	"minecraft:snow_layer":                       "minecraft:snow",
+	"minecraft:standing_banner":                  "minecraft:white_banner",
+	"minecraft:standing_sign":                    "minecraft:oak_sign",
+	"minecraft:stonecutter_block":                "minecraft:stonecutter",
+	"minecraft:tallgrass":                        "minecraft:short_grass",
+	"minecraft:trapdoor":                         "minecraft:oak_trapdoor",
+	"minecraft:wall_banner":                      "minecraft:white_wall_banner",
+	"minecraft:wall_sign":                        "minecraft:oak_wall_sign",
+	"minecraft:waterlily":                        "minecraft:lily_pad",
+	"minecraft:web":                              "minecraft:cobweb",
+	"minecraft:wooden_button":                    "minecraft:oak_button",
+	"minecraft:wooden_door":                      "minecraft:oak_door",
+	"minecraft:wooden_pressure_plate":            "minecraft:oak_pressure_plate",
+	"minecraft:yellow_flower":                    "minecraft:dandelion",
+	"minecraft:unlit_redstone_torch":             "minecraft:redstone_torch",
+	"minecraft:unpowered_repeater":               "minecraft:repeater",
+	"minecraft:powered_repeater":                 "minecraft:repeater",
+	"minecraft:unpowered_comparator":             "minecraft:comparator",
+	"minecraft:powered_comparator":               "minecraft:comparator",
+	"minecraft:lit_furnace":                      "minecraft:furnace",
+	"minecraft:lit_blast_furnace":                "minecraft:blast_furnace",
+	"minecraft:lit_smoker":                       "minecraft:smoker",
+	"minecraft:lit_redstone_lamp":                "minecraft:redstone_lamp",
+	"minecraft:lit_redstone_ore":                 "minecraft:redstone_ore",
+	"minecraft:lit_deepslate_redstone_ore":       "minecraft:deepslate_redstone_ore",
+	"minecraft:daylight_detector_inverted":       "minecraft:daylight_detector",
+	"minecraft:stained_glass":                    "minecraft:white_stained_glass",
+	"minecraft:stained_glass_pane":               "minecraft:white_stained_glass_pane",
+	"minecraft:concrete":                         "minecraft:white_concrete",
+	"minecraft:concrete_powder":                  "minecraft:white_concrete_powder",
+	"minecraft:wool":                             "minecraft:white_wool",
+	"minecraft:stained_hardened_clay":            "minecraft:white_terracotta",
+	"minecraft:silver_glazed_terracotta":         "minecraft:light_gray_glazed_terracotta",
+	"minecraft:undyed_shulker_box":               "minecraft:shulker_box",
+	"minecraft:light_block":                      "minecraft:light",
+	"minecraft:client_request_placeholder_block": "minecraft:air",
+}
+
+// bedrock block entity ids and the java ones they become,
+// block entities that are entities or plain blocks in java are not in here and are left out
+var javaBlockEntityIDs = map[string]string{
+	"Banner":                "minecraft:banner",
+	"Barrel":                "minecraft:barrel",
+	"Beacon":                "minecraft:beacon",
+	"Bed":                   "minecraft:bed",
+	"Beehive":               "minecraft:beehive",
+	"Bell":                  "minecraft:bell",
+	"BlastFurnace":          "minecraft:blast_furnace",
+	"BrewingStand":          "minecraft:brewing_stand",
+	"BrushableBlock":        "minecraft:brushable_block",
+	"CalibratedSculkSensor": "minecraft:calibrated_sculk_sensor",
+	"Campfire":              "minecraft:campfire",
+	"Chest":                 "minecraft:chest",
+	"ChiseledBookshelf":     "minecraft:chiseled_bookshelf",
+	"CommandBlock":          "minecraft:command_block",
+	"Comparator":            "minecraft:comparator",
+	"Conduit":               "minecraft:conduit",
+	"Crafter":               "minecraft:crafter",
+	"DaylightDetector":      "minecraft:daylight_detector",
+	"DecoratedPot":          "minecraft:decorated_pot",
+	"Dispenser":             "minecraft:dispenser",
+	"Dropper":               "minecraft:dropper",
+	"EnchantTable":          "minecraft:enchanting_table",
+	"EndGateway":            "minecraft:end_gateway",
+	"EndPortal":             "minecraft:end_portal",
+	"EnderChest":            "minecraft:ender_chest",
+	"Furnace":               "minecraft:furnace",
+	"HangingSign":           "minecraft:hanging_sign",
+	"Hopper":                "minecraft:hopper",
+	"Jigsaw":                "minecraft:jigsaw",
+	"JigsawBlock":           "minecraft:jigsaw",
+	"Jukebox":               "minecraft:jukebox",
+	"JukeBox":               "minecraft:jukebox",
+	"Lectern":               "minecraft:lectern",
+	"MobSpawner":            "minecraft:mob_spawner",
+	"SculkCatalyst":         "minecraft:sculk_catalyst",
+	"SculkSensor":           "minecraft:sculk_sensor",
+	"SculkShrieker":         "minecraft:sculk_shrieker",
+	"ShulkerBox":            "minecraft:shulker_box",
+	"Sign":                  "minecraft:sign",
+	"Skull":                 "minecraft:skull",
+	"Smoker":                "minecraft:smoker",
+	"StructureBlock":        "minecraft:structure_block",
+	"TrialSpawner":          "minecraft:trial_spawner",
+	"Vault":                 "minecraft:vault",
+}
+
+// blocks whose lit state is part of the bedrock name
+var javaLitBlocks = map[string]bool{
+	"minecraft:lit_furnace":                true,
+	"minecraft:lit_blast_furnace":          true,
+	"minecraft:lit_smoker":                 true,
+	"minecraft:lit_redstone_lamp":          true,
+	"minecraft:lit_redstone_ore":           true,
+	"minecraft:lit_deepslate_redstone_ore": true,
+	"minecraft:unlit_redstone_torch":       false,
+}
+
+var javaFacing = []string{"down", "up", "north", "south", "west", "east"}
+
+// direction states, bedrock uses different orders for different blocks
+var (
+	directionSWNE = []string{"south", "west", "north", "east"}
+	directionEWSN = []string{"east", "west", "south", "north"}
+)
+
+var javaRailShapes = []string{
+	"north_south", "east_west", "ascending_east", "ascending_west", "ascending_north",
+	"ascending_south", "south_east", "south_west", "north_west", "north_east",
+}
+
+// javaBlock converts a bedrock block to its java name and block states.
+// Only names and states that differ in common blocks are mapped, states without a java equivalent are left out
+// so tools use their defaults, blocks that are not from minecraft become air.
+func javaBlock(b blockState) (string, map[string]string) {
+	name := b.Name
+	if !strings.HasPrefix(name, "minecraft:") {
+		return "minecraft:air", nil
+	}
+	if n, ok := javaBlockNames[name]; ok {
+		name = n
+	}
+
+	states := make(map[string]string)
+	if lit, ok := javaLitBlocks[b.Name]; ok {
+		states["lit"] = strconv.FormatBool(lit)
+	}
+	if strings.HasSuffix(name, "_double_slab") {
+		name = strings.Replace(name, "_double_slab", "_slab", 1)
+		states["type"] = "double"
+	}
+	isSlab := strings.HasSuffix(name, "_slab")
+	isTrapdoor := strings.HasSuffix(name, "trapdoor")
+
+	for k, v := range b.States {
+		switch k {
+		case "pillar_axis":
+			states["axis"] = stateString(v)
+		case "minecraft:cardinal_direction", "minecraft:facing_direction", "minecraft:block_face":
+			states["facing"] = stateString(v)
+		case "facing_direction":
+			states["facing"] = stateIndex(javaFacing, v)
+		case "direction":
+			if isTrapdoor {
+				states["facing"] = stateIndex(directionEWSN, v)
+			} else {
+				states["facing"] = stateIndex(directionSWNE, v)
+			}
+		case "weirdo_direction":
+			states["facing"] = stateIndex(directionEWSN, v)
+		case "minecraft:vertical_half":
+			if isSlab {
+				if states["type"] == "" {
+					states["type"] = stateString(v)
+				}
+			} else {
+				states["half"] = stateString(v)
+			}
+		case "upside_down_bit":
+			states["half"] = map[bool]string{true: "top", false: "bottom"}[stateBool(v)]
+		case "upper_block_bit":
+			states["half"] = map[bool]string{true: "upper", false: "lower"}[stateBool(v)]
+		case "door_hinge_bit":
+			states["hinge"] = map[bool]string{true: "right", false: "left"}[stateBool(v)]
+		case "head_piece_bit":
+			states["part"] = map[bool]string{true: "head", false: "foot"}[stateBool(v)]
+		case "output_subtract_bit":
+			states["mode"] = map[bool]string{true: "subtract", false: "compare"}[stateBool(v)]
+		case "open_bit":
+			states["open"] = strconv.FormatBool(stateBool(v))
+		case "persistent_bit":
+			states["persistent"] = strconv.FormatBool(stateBool(v))
+		case "powered_bit", "button_pressed_bit", "rail_data_bit", "output_lit_bit":
+			states["powered"] = strconv.FormatBool(stateBool(v))
+		case "in_wall_bit":
+			states["in_wall"] = strconv.FormatBool(stateBool(v))
+		case "occupied_bit":
+			states["occupied"] = strconv.FormatBool(stateBool(v))
+		case "attached_bit":
+			states["attached"] = strconv.FormatBool(stateBool(v))
+		case "end_portal_eye_bit":
+			states["eye"] = strconv.FormatBool(stateBool(v))
+		case "hanging":
+			states["hanging"] = strconv.FormatBool(stateBool(v))
+		case "wall_post_bit":
+			states["up"] = strconv.FormatBool(stateBool(v))
+		case "extinguished":
+			states["lit"] = strconv.FormatBool(!stateBool(v))
+		case "age", "honey_level":
+			states[k] = stateString(v)
+		case "growth", "kelp_age":
+			states["age"] = stateString(v)
+		case "liquid_depth":
+			states["level"] = stateString(v)
+		case "redstone_signal":
+			states["power"] = stateString(v)
+		case "ground_sign_direction":
+			states["rotation"] = stateString(v)
+		case "moisturized_amount":
+			states["moisture"] = stateString(v)
+		case "bite_counter":
+			states["bites"] = stateString(v)
+		case "respawn_anchor_charge":
+			states["charges"] = stateString(v)
+		case "height":
+			if name == "minecraft:snow" {
+				states["layers"] = strconv.Itoa(stateInt(v) + 1)
+			}
+		case "candles":
+			states["candles"] = strconv.Itoa(stateInt(v) + 1)
+		case "repeater_delay":
+			states["delay"] = strconv.Itoa(stateInt(v) + 1)
+		case "rail_direction":
+			states["shape"] = stateIndex(javaRailShapes, v)
+		case "vine_direction_bits":
+			bits := stateInt(v)
+			for i, dir := range []string{"south", "west", "north", "east"} {
+				states[dir] = strconv.FormatBool(bits&(1<<i) != 0)
+			}
+		case "wall_connection_type_north", "wall_connection_type_east", "wall_connection_type_south", "wall_connection_type_west":
+			conn := stateString(v)
+			if conn == "short" {
+				conn = "low"
+			}
+			states[strings.TrimPrefix(k, "wall_connection_type_")] = conn
+		}
+	}
+	for k, v := range states {
+		if v == "" {
+			delete(states, k)
+		}
+	}
+	return name, states
+}
+
+func stateString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return strconv.Itoa(stateInt(v))
+}
+
+func stateInt(v any) int {
+	switch v := v.(type) {
+	case int32:
+		return int(v)
+	case uint8:
+		return int(v)
+	case int:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+	}
+	return 0
+}
+
+func stateBool(v any) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return stateInt(v) != 0
+}
+
+// stateIndex returns names[v], or "" if v is out of range
+func stateIndex(names []string, v any) string {
+	i := stateInt(v)
+	if i < 0 || i >= len(names) {
+		return ""
+	}
+	return names[i]
+}
@@ -0,0 +1,122 @@
+package structure
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// javaDataVersion is the data version written to schematics (1.20.4)
+const javaDataVersion = 3700
+
+// byteArray converts b to a fixed size array so the nbt encoder writes a TAG_Byte_Array instead of a list
+func byteArray(b []byte) any {
+	arr := reflect.New(reflect.ArrayOf(len(b), reflect.TypeOf(byte(0)))).Elem()
+	reflect.Copy(arr, reflect.ValueOf(b))
+	return arr.Interface()
+}
+
+// javaBlockState formats a palette entry as a java block state name[key=value,...]
+func javaBlockState(b blockState) string {
+	name, states := javaBlock(b)
+	if len(states) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(states))
+	for k := range states {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+states[k])
+	}
+	return name + "[" + strings.Join(parts, ",") + "]"
+}
+
+// WriteSchematic writes the structure as a gzipped sponge schematic (version 3).
+// Entities are not included since bedrock entity nbt has no java equivalent.
+func (s *Structure) WriteSchematic(w io.Writer) error {
+	// the size is stored as shorts
+	for i, side := range []string{"width", "height", "length"} {
+		if s.Size[i] > math.MaxInt16 {
+			return fmt.Errorf("structure %s %d is too big for a schematic, at most %d", side, s.Size[i], math.MaxInt16)
+		}
+	}
+
+	palette := make(map[string]any)
+	paletteIDs := make([]int32, len(s.palette))
+	for i, b := range s.palette {
+		name := javaBlockState(b)
+		id, ok := palette[name]
+		if !ok {
+			id = int32(len(palette))
+			palette[name] = id
+		}
+		paletteIDs[i] = id.(int32)
+	}
+	voidID, ok := palette["minecraft:structure_void"]
+	if !ok {
+		voidID = int32(len(palette))
+		palette["minecraft:structure_void"] = voidID
+	}
+
+	// sponge orders blocks x first, then z, then y and stores them as varints
+	var data []byte
+	for y := 0; y < s.Size[1]; y++ {
+		for z := 0; z < s.Size[2]; z++ {
+			for x := 0; x < s.Size[0]; x++ {
+				id := voidID.(int32)
+				if idx := s.indices[0][s.index(x, y, z)]; idx >= 0 {
+					id = paletteIDs[idx]
+				}
+				data = binary.AppendUvarint(data, uint64(id))
+			}
+		}
+	}
+
+	blockEntities := make([]map[string]any, 0, len(s.blockEntities))
+	for idx, be := range s.blockEntities {
+		x := int(idx) / (s.Size[1] * s.Size[2])
+		y := int(idx) / s.Size[2] % s.Size[1]
+		z := int(idx) % s.Size[2]
+		id, _ := be["id"].(string)
+		javaID, ok := javaBlockEntityIDs[id]
+		if !ok {
+			continue
+		}
+		blockEntities = append(blockEntities, map[string]any{
+			"Pos":  [3]int32{int32(x), int32(y), int32(z)},
+			"Id":   javaID,
+			"Data": be,
+		})
+	}
+
+	zw := gzip.NewWriter(w)
+	err := nbt.NewEncoderWithEncoding(zw, nbt.BigEndian).Encode(map[string]any{
+		"Schematic": map[string]any{
+			"Version":     int32(3),
+			"DataVersion": int32(javaDataVersion),
+			"Width":       int16(s.Size[0]),
+			"Height":      int16(s.Size[1]),
+			"Length":      int16(s.Size[2]),
+			"Offset":      [3]int32{int32(s.Origin[0]), int32(s.Origin[1]), int32(s.Origin[2])},
+			"Blocks": map[string]any{
+				"Palette":       palette,
+				"Data":          byteArray(data),
+				"BlockEntities": blockEntities,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,274 @@
+// Package structure exports a box of blocks from a world as a .mcstructure or sponge .schem file
+package structure
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// ChunkSource is anything that chunks can be loaded from, worldstate.World implements this
+type ChunkSource interface {
+	LoadChunk(pos world.ChunkPos) (*world.Column, bool, error)
+}
+
+type blockState struct {
+	Name    string         `nbt:"name"`
+	States  map[string]any `nbt:"states"`
+	Version int32          `nbt:"version"`
+}
+
+// Structure holds the blocks, block entities and entities inside of a box
+type Structure struct {
+	Origin cube.Pos
+	Size   [3]int
+
+	palette       []blockState
+	paletteLookup map[uint32]int32
+	// block indices into palette for layer 0 and 1, -1 is structure void
+	indices       [2][]int32
+	blockEntities map[int32]map[string]any
+	entities      []map[string]any
+}
+
+// ParsePos parses a position formatted as x,y,z
+func ParsePos(s string) (pos cube.Pos, err error) {
+	sp := strings.Split(s, ",")
+	if len(sp) != 3 {
+		return pos, fmt.Errorf("invalid position %q, expected x,y,z", s)
+	}
+	for i, v := range sp {
+		pos[i], err = strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return pos, err
+		}
+	}
+	return pos, nil
+}
+
+// Bounds returns the min and max corner of the box spanned by a and b
+func Bounds(a, b cube.Pos) (minPos, maxPos cube.Pos) {
+	for i := 0; i < 3; i++ {
+		minPos[i] = min(a[i], b[i])
+		maxPos[i] = max(a[i], b[i])
+	}
+	return
+}
+
+func newStructure(origin cube.Pos, size [3]int) *Structure {
+	s := &Structure{
+		Origin:        origin,
+		Size:          size,
+		paletteLookup: make(map[uint32]int32),
+		blockEntities: make(map[int32]map[string]any),
+	}
+	n := size[0] * size[1] * size[2]
+	for layer := range s.indices {
+		s.indices[layer] = make([]int32, n)
+		for i := range s.indices[layer] {
+			s.indices[layer][i] = -1
+		}
+	}
+	return s
+}
+
+// index returns the index of a position relative to the origin, z is incremented first
+func (s *Structure) index(x, y, z int) int32 {
+	return int32((x*s.Size[1]+y)*s.Size[2] + z)
+}
+
+func (s *Structure) contains(pos cube.Pos) bool {
+	for i := 0; i < 3; i++ {
+		if pos[i] < s.Origin[i] || pos[i] >= s.Origin[i]+s.Size[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Structure) paletteIndex(blocks world.BlockRegistry, rid uint32) int32 {
+	if idx, ok := s.paletteLookup[rid]; ok {
+		return idx
+	}
+	name, properties, found := blocks.RuntimeIDToState(rid)
+	if !found {
+		name = "minecraft:info_update"
+	}
+	if properties == nil {
+		properties = map[string]any{}
+	}
+	idx := int32(len(s.palette))
+	s.palette = append(s.palette, blockState{
+		Name:    name,
+		States:  properties,
+		Version: chunk.CurrentBlockVersion,
+	})
+	s.paletteLookup[rid] = idx
+	return idx
+}
+
+// Read copies the box between a and b out of src
+func Read(src ChunkSource, blocks world.BlockRegistry, a, b cube.Pos) (*Structure, error) {
+	minPos, maxPos := Bounds(a, b)
+	size := [3]int{
+		maxPos[0] - minPos[0] + 1,
+		maxPos[1] - minPos[1] + 1,
+		maxPos[2] - minPos[2] + 1,
+	}
+	if size[0]*size[1]*size[2] > 64*1024*1024 {
+		return nil, errors.New("structure too large")
+	}
+	s := newStructure(minPos, size)
+
+	airRID, ok := blocks.StateToRuntimeID("minecraft:air", nil)
+	if !ok {
+		return nil, errors.New("air missing from block registry")
+	}
+
+	for cx := minPos[0] >> 4; cx <= maxPos[0]>>4; cx++ {
+		for cz := minPos[2] >> 4; cz <= maxPos[2]>>4; cz++ {
+			col, ok, err := src.LoadChunk(world.ChunkPos{int32(cx), int32(cz)})
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				// missing chunks stay structure void
+				continue
+			}
+			s.readColumn(blocks, airRID, cx, cz, col)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *Structure) readColumn(blocks world.BlockRegistry, airRID uint32, cx, cz int, col *world.Column) {
+	r := col.Chunk.Range()
+	for x := max(s.Origin[0], cx<<4); x < min(s.Origin[0]+s.Size[0], (cx+1)<<4); x++ {
+		for z := max(s.Origin[2], cz<<4); z < min(s.Origin[2]+s.Size[2], (cz+1)<<4); z++ {
+			for y := max(s.Origin[1], r.Min()); y < min(s.Origin[1]+s.Size[1], r.Max()+1); y++ {
+				i := s.index(x-s.Origin[0], y-s.Origin[1], z-s.Origin[2])
+				s.indices[0][i] = s.paletteIndex(blocks, col.Chunk.Block(uint8(x&15), int16(y), uint8(z&15), 0))
+				if rid := col.Chunk.Block(uint8(x&15), int16(y), uint8(z&15), 1); rid != airRID {
+					s.indices[1][i] = s.paletteIndex(blocks, rid)
+				}
+			}
+		}
+	}
+
+	for pos, b := range col.BlockEntities {
+		if !s.contains(pos) {
+			continue
+		}
+		var data map[string]any
+		switch b := b.(type) {
+		case world.UnknownBlock:
+			data = b.Properties
+		case world.NBTer:
+			data = b.EncodeNBT()
+		}
+		if data == nil {
+			continue
+		}
+		s.blockEntities[s.index(pos[0]-s.Origin[0], pos[1]-s.Origin[1], pos[2]-s.Origin[2])] = data
+	}
+
+	for _, e := range col.Entities {
+		t, ok := e.Type().(world.SaveableEntityType)
+		if !ok {
+			continue
+		}
+		data := t.EncodeNBT(e)
+		if _, ok := data["identifier"]; !ok {
+			data["identifier"] = t.EncodeEntity()
+		}
+		s.AddEntity(data)
+	}
+}
+
+// AddEntity adds the nbt of an entity to the structure if its Pos is inside of the box
+func (s *Structure) AddEntity(data map[string]any) bool {
+	x, y, z, ok := entityPos(data["Pos"])
+	if !ok {
+		return false
+	}
+	if !s.contains(cube.Pos{int(math.Floor(x)), int(math.Floor(y)), int(math.Floor(z))}) {
+		return false
+	}
+	s.entities = append(s.entities, data)
+	return true
+}
+
+func entityPos(v any) (x, y, z float64, ok bool) {
+	switch p := v.(type) {
+	case []float32:
+		if len(p) != 3 {
+			return
+		}
+		return float64(p[0]), float64(p[1]), float64(p[2]), true
+	case []any:
+		if len(p) != 3 {
+			return
+		}
+		var out [3]float64
+		for i, c := range p {
+			f, ok := c.(float32)
+			if !ok {
+				return 0, 0, 0, false
+			}
+			out[i] = float64(f)
+		}
+		return out[0], out[1], out[2], true
+	}
+	return
+}
+
+// BlockCount returns how many non air blocks are in the structure
+func (s *Structure) BlockCount() (n int) {
+	for _, idx := range s.indices[0] {
+		if idx < 0 || s.palette[idx].Name == "minecraft:air" {
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// WriteMCStructure writes the structure in the bedrock .mcstructure format
+func (s *Structure) WriteMCStructure(w io.Writer) error {
+	blockPositionData := make(map[string]any, len(s.blockEntities))
+	for idx, data := range s.blockEntities {
+		blockPositionData[strconv.Itoa(int(idx))] = map[string]any{
+			"block_entity_data": data,
+		}
+	}
+
+	entities := s.entities
+	if entities == nil {
+		entities = []map[string]any{}
+	}
+
+	return nbt.NewEncoderWithEncoding(w, nbt.LittleEndian).Encode(map[string]any{
+		"format_version": int32(1),
+		"size":           []int32{int32(s.Size[0]), int32(s.Size[1]), int32(s.Size[2])},
+		"structure": map[string]any{
+			"block_indices": [][]int32{s.indices[0], s.indices[1]},
+			"entities":      entities,
+			"palette": map[string]any{
+				"default": map[string]any{
+					"block_palette":       s.palette,
+					"block_position_data": blockPositionData,
+				},
+			},
+		},
+		"structure_world_origin": []int32{int32(s.Origin[0]), int32(s.Origin[1]), int32(s.Origin[2])},
+	})
+}
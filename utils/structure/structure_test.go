@@ -0,0 +1,228 @@
+package structure
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+func TestParsePos(t *testing.T) {
+	pos, err := ParsePos("10, -64,3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != (cube.Pos{10, -64, 3}) {
+		t.Errorf("wrong pos %v", pos)
+	}
+
+	if _, err := ParsePos("1,2"); err == nil {
+		t.Error("expected error for 2 components")
+	}
+}
+
+func TestIndexOrder(t *testing.T) {
+	minPos, maxPos := Bounds(cube.Pos{3, 5, 1}, cube.Pos{0, 4, 2})
+	if minPos != (cube.Pos{0, 4, 1}) || maxPos != (cube.Pos{3, 5, 2}) {
+		t.Fatalf("wrong bounds %v %v", minPos, maxPos)
+	}
+
+	s := newStructure(minPos, [3]int{4, 2, 2})
+	// z is incremented first, then y, then x
+	if s.index(0, 0, 1) != 1 || s.index(0, 1, 0) != 2 || s.index(1, 0, 0) != 4 {
+		t.Error("wrong index order")
+	}
+	if len(s.indices[0]) != 16 || s.indices[1][15] != -1 {
+		t.Error("indices not initialized as structure void")
+	}
+}
+
+// testStructure is a 2x1x2 structure with a log, an unknown custom block, stone and one structure void
+func testStructure() *Structure {
+	s := newStructure(cube.Pos{5, 64, -3}, [3]int{2, 1, 2})
+	s.palette = []blockState{
+		{Name: "minecraft:oak_log", States: map[string]any{"pillar_axis": "y"}},
+		{Name: "custom:thing", States: map[string]any{}},
+		{Name: "minecraft:stone", States: map[string]any{}},
+	}
+	s.indices[0][s.index(0, 0, 0)] = 0
+	s.indices[0][s.index(0, 0, 1)] = 1
+	s.indices[0][s.index(1, 0, 0)] = 2
+	s.blockEntities[s.index(1, 0, 0)] = map[string]any{"id": "Chest"}
+	s.entities = append(s.entities, map[string]any{"identifier": "minecraft:pig"})
+	return s
+}
+
+func TestWriteMCStructure(t *testing.T) {
+	s := testStructure()
+	var buf bytes.Buffer
+	if err := s.WriteMCStructure(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		Size      []int32 `nbt:"size"`
+		Origin    []int32 `nbt:"structure_world_origin"`
+		Structure struct {
+			BlockIndices [][]int32        `nbt:"block_indices"`
+			Entities     []map[string]any `nbt:"entities"`
+			Palette      struct {
+				Default struct {
+					BlockPalette      []blockState   `nbt:"block_palette"`
+					BlockPositionData map[string]any `nbt:"block_position_data"`
+				} `nbt:"default"`
+			} `nbt:"palette"`
+		} `nbt:"structure"`
+	}
+	if err := nbt.NewDecoderWithEncoding(&buf, nbt.LittleEndian).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(out.Size, []int32{2, 1, 2}) || !slices.Equal(out.Origin, []int32{5, 64, -3}) {
+		t.Errorf("wrong size or origin %v %v", out.Size, out.Origin)
+	}
+	if !slices.Equal(out.Structure.BlockIndices[0], []int32{0, 1, 2, -1}) || !slices.Equal(out.Structure.BlockIndices[1], []int32{-1, -1, -1, -1}) {
+		t.Errorf("wrong block indices %v", out.Structure.BlockIndices)
+	}
+	palette := out.Structure.Palette.Default.BlockPalette
+	if len(palette) != 3 || palette[0].Name != "minecraft:oak_log" || palette[0].States["pillar_axis"] != "y" {
+		t.Errorf("wrong palette %v", palette)
+	}
+	if _, ok := out.Structure.Palette.Default.BlockPositionData["2"]; !ok {
+		t.Error("block entity missing")
+	}
+	if len(out.Structure.Entities) != 1 || out.Structure.Entities[0]["identifier"] != "minecraft:pig" {
+		t.Errorf("wrong entities %v", out.Structure.Entities)
+	}
+}
+
+func TestWriteSchematic(t *testing.T) {
+	s := testStructure()
+	var buf bytes.Buffer
+	if err := s.WriteSchematic(&buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		Schematic struct {
+			Width, Height, Length int16
+			Offset                [3]int32
+			Blocks                struct {
+				Palette       map[string]int32
+				Data          [4]byte
+				BlockEntities []map[string]any
+			}
+		}
+	}
+	if err := nbt.NewDecoderWithEncoding(zr, nbt.BigEndian).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	sch := out.Schematic
+	if sch.Width != 2 || sch.Height != 1 || sch.Length != 2 || sch.Offset != [3]int32{5, 64, -3} {
+		t.Errorf("wrong dimensions %+v", sch)
+	}
+
+	names := make(map[int32]string)
+	for name, id := range sch.Blocks.Palette {
+		names[id] = name
+	}
+	var blocks []string
+	for data := sch.Blocks.Data[:]; len(data) > 0; {
+		id, n := binary.Uvarint(data)
+		data = data[n:]
+		blocks = append(blocks, names[int32(id)])
+	}
+	// x first, then z
+	want := []string{"minecraft:oak_log[axis=y]", "minecraft:stone", "minecraft:air", "minecraft:structure_void"}
+	if !slices.Equal(blocks, want) {
+		t.Errorf("wrong blocks %v, want %v", blocks, want)
+	}
+	if len(sch.Blocks.BlockEntities) != 1 || sch.Blocks.BlockEntities[0]["Id"] != "minecraft:chest" {
+		t.Errorf("wrong block entities %v", sch.Blocks.BlockEntities)
+	}
+}
+
+func TestWriteSchematicBlockEntities(t *testing.T) {
+	s := testStructure()
+	s.blockEntities[s.index(0, 0, 0)] = map[string]any{"id": "EnchantTable"}
+	s.blockEntities[s.index(0, 0, 1)] = map[string]any{"id": "MobSpawner"}
+	// flower pots are plain blocks in java
+	s.blockEntities[s.index(1, 0, 1)] = map[string]any{"id": "FlowerPot"}
+	var buf bytes.Buffer
+	if err := s.WriteSchematic(&buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Schematic struct {
+			Blocks struct {
+				BlockEntities []struct {
+					Pos [3]int32
+					Id  string
+				}
+			}
+		}
+	}
+	if err := nbt.NewDecoderWithEncoding(zr, nbt.BigEndian).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[[3]int32]string)
+	for _, be := range out.Schematic.Blocks.BlockEntities {
+		got[be.Pos] = be.Id
+	}
+	want := map[[3]int32]string{
+		{0, 0, 0}: "minecraft:enchanting_table",
+		{0, 0, 1}: "minecraft:mob_spawner",
+		{1, 0, 0}: "minecraft:chest",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("block entities %v, want %v", got, want)
+	}
+}
+
+func TestWriteSchematicTooBig(t *testing.T) {
+	for _, size := range [][3]int{{32768, 1, 1}, {1, 32768, 1}, {1, 1, 32768}} {
+		s := newStructure(cube.Pos{}, size)
+		if err := s.WriteSchematic(io.Discard); err == nil {
+			t.Errorf("no error for size %v", size)
+		}
+	}
+	s := newStructure(cube.Pos{}, [3]int{32767, 1, 1})
+	if err := s.WriteSchematic(io.Discard); err != nil {
+		t.Errorf("size 32767: %s", err)
+	}
+}
+
+func TestJavaBlockState(t *testing.T) {
+	tests := []struct {
+		in   blockState
+		want string
+	}{
+		{blockState{Name: "minecraft:stone"}, "minecraft:stone"},
+		{blockState{Name: "minecraft:oak_log", States: map[string]any{"pillar_axis": "x"}}, "minecraft:oak_log[axis=x]"},
+		{blockState{Name: "minecraft:oak_stairs", States: map[string]any{"weirdo_direction": int32(3), "upside_down_bit": uint8(1)}}, "minecraft:oak_stairs[facing=north,half=top]"},
+		{blockState{Name: "minecraft:oak_slab", States: map[string]any{"minecraft:vertical_half": "top"}}, "minecraft:oak_slab[type=top]"},
+		{blockState{Name: "minecraft:oak_double_slab", States: map[string]any{"minecraft:vertical_half": "bottom"}}, "minecraft:oak_slab[type=double]"},
+		{blockState{Name: "minecraft:snow_layer", States: map[string]any{"height": int32(2), "covered_bit": uint8(0)}}, "minecraft:snow[layers=3]"},
+		{blockState{Name: "minecraft:lit_furnace", States: map[string]any{"minecraft:cardinal_direction": "west"}}, "minecraft:furnace[facing=west,lit=true]"},
+		{blockState{Name: "minecraft:wooden_door", States: map[string]any{"direction": int32(2), "upper_block_bit": true, "open_bit": false, "door_hinge_bit": false}}, "minecraft:oak_door[facing=north,half=upper,hinge=left,open=false]"},
+		{blockState{Name: "custom:thing"}, "minecraft:air"},
+	}
+	for _, tt := range tests {
+		if got := javaBlockState(tt.in); got != tt.want {
+			t.Errorf("javaBlockState(%v) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"io/fs"
+	"sync"
+
+	"github.com/df-mc/dragonfly/server/block"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/resource"
+)
+
+// TextureSize is how many pixels a block is wide in textured mode
+const TextureSize = 16
+
+// blocks that take the grass color of their biome
+var grassTinted = map[string]bool{
+	"minecraft:grass":       true,
+	"minecraft:grass_block": true,
+	"minecraft:short_grass": true,
+	"minecraft:tall_grass":  true,
+	"minecraft:fern":        true,
+	"minecraft:large_fern":  true,
+}
+
+// blocks that take the foliage color of their biome
+var foliageTinted = map[string]bool{
+	"minecraft:leaves":          true,
+	"minecraft:leaves2":         true,
+	"minecraft:oak_leaves":      true,
+	"minecraft:jungle_leaves":   true,
+	"minecraft:acacia_leaves":   true,
+	"minecraft:dark_oak_leaves": true,
+	"minecraft:mangrove_leaves": true,
+	"minecraft:vine":            true,
+}
+
+// leaves that dont change with the biome
+var fixedTints = map[string]color.RGBA{
+	"minecraft:birch_leaves":  {0x80, 0xa7, 0x55, 0xff},
+	"minecraft:spruce_leaves": {0x61, 0x99, 0x61, 0xff},
+}
+
+var (
+	defaultGrassColor   = color.RGBA{0x79, 0xc0, 0x5a, 0xff}
+	defaultFoliageColor = color.RGBA{0x59, 0xae, 0x30, 0xff}
+	waterTint           = color.RGBA{0x44, 0xaf, 0xf5, 0xff}
+)
+
+type blockTexture struct {
+	img    *image.RGBA
+	opaque bool
+}
+
+type tintKey struct {
+	name string
+	tint color.RGBA
+}
+
+// blockTextures are the top textures of blocks scaled to TextureSize
+type blockTextures struct {
+	textures        map[string]*blockTexture
+	grassColormap   image.Image
+	foliageColormap image.Image
+
+	l      sync.Mutex
+	tinted map[tintKey]*blockTexture
+}
+
+func newBlockTextures(entries []protocol.BlockEntry, packs []resource.Pack, face string) *blockTextures {
+	t := &blockTextures{
+		textures: make(map[string]*blockTexture),
+		tinted:   make(map[tintKey]*blockTexture),
+	}
+	merged := resolveBlockTextures(entries, packs, face, func(block string, img image.Image) {
+		t.textures[block] = toBlockTexture(img)
+	})
+	t.grassColormap = loadColormap(merged, "textures/colormap/grass")
+	t.foliageColormap = loadColormap(merged, "textures/colormap/foliage")
+	return t
+}
+
+func loadColormap(f fs.FS, name string) image.Image {
+	img, _ := openTexture(f, name)
+	return img
+}
+
+// toBlockTexture scales a texture to TextureSize with nearest neighbour
+func toBlockTexture(img image.Image) *blockTexture {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, TextureSize, TextureSize))
+	opaque := true
+	for y := 0; y < TextureSize; y++ {
+		for x := 0; x < TextureSize; x++ {
+			c := color.RGBAModel.Convert(img.At(b.Min.X+x*b.Dx()/TextureSize, b.Min.Y+y*b.Dy()/TextureSize)).(color.RGBA)
+			if c.A != 0xff {
+				opaque = false
+			}
+			out.SetRGBA(x, y, c)
+		}
+	}
+	return &blockTexture{img: out, opaque: opaque}
+}
+
+// colormapColor looks up a biome color the same way the game does
+func colormapColor(colormap image.Image, temperature, rainfall float64) (color.RGBA, bool) {
+	if colormap == nil {
+		return color.RGBA{}, false
+	}
+	temperature = min(max(temperature, 0), 1)
+	rainfall = min(max(rainfall, 0), 1) * temperature
+	b := colormap.Bounds()
+	x := int((1 - temperature) * float64(b.Dx()-1))
+	y := int((1 - rainfall) * float64(b.Dy()-1))
+	c := color.RGBAModel.Convert(colormap.At(b.Min.X+x, b.Min.Y+y)).(color.RGBA)
+	c.A = 0xff
+	return c, true
+}
+
+// tint multiplies a grey texture with a color, tinted textures are cached
+func (t *blockTextures) tint(name string, tex *blockTexture, tint color.RGBA) *blockTexture {
+	key := tintKey{name, tint}
+	t.l.Lock()
+	defer t.l.Unlock()
+	if tinted, ok := t.tinted[key]; ok {
+		return tinted
+	}
+	img := image.NewRGBA(tex.img.Rect)
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i] = uint8(uint16(tex.img.Pix[i]) * uint16(tint.R) / 0xff)
+		img.Pix[i+1] = uint8(uint16(tex.img.Pix[i+1]) * uint16(tint.G) / 0xff)
+		img.Pix[i+2] = uint8(uint16(tex.img.Pix[i+2]) * uint16(tint.B) / 0xff)
+		img.Pix[i+3] = tex.img.Pix[i+3]
+	}
+	tinted := &blockTexture{img: img, opaque: tex.opaque}
+	t.tinted[key] = tinted
+	return tinted
+}
+
+// biomeTint returns the grass or foliage color for the biome at the position
+func (cr *ChunkRenderer) biomeTint(c *chunk.Chunk, x uint8, y int16, z uint8, colormap image.Image, fallback color.RGBA) color.RGBA {
+	if cr.Biomes == nil {
+		return fallback
+	}
+	biome, ok := cr.Biomes.BiomeByID(int(c.Biome(x, y, z)))
+	if !ok {
+		return fallback
+	}
+	if col, ok := colormapColor(colormap, biome.Temperature(), biome.Rainfall()); ok {
+		return col
+	}
+	return fallback
+}
+
+// blockTexture returns the tinted top texture of the block, nil if it has none
+func (cr *ChunkRenderer) blockTexture(c *chunk.Chunk, b world.Block, x uint8, y int16, z uint8) *blockTexture {
+	name, _ := b.EncodeBlock()
+	tex, ok := cr.textures.textures[name]
+	if !ok {
+		return nil
+	}
+	switch {
+	case grassTinted[name]:
+		return cr.textures.tint(name, tex, cr.biomeTint(c, x, y, z, cr.textures.grassColormap, defaultGrassColor))
+	case foliageTinted[name]:
+		return cr.textures.tint(name, tex, cr.biomeTint(c, x, y, z, cr.textures.foliageColormap, defaultFoliageColor))
+	}
+	if tint, ok := fixedTints[name]; ok {
+		return cr.textures.tint(name, tex, tint)
+	}
+	return tex
+}
+
+// drawBlockTextured draws the block at the position into dst at px, see through blocks show what is under them
+func (cr *ChunkRenderer) drawBlockTextured(dst *image.RGBA, px image.Point, c *chunk.Chunk, x uint8, y int16, z uint8, depth int) {
+	if y <= int16(c.Range().Min()) || depth > 8 {
+		return
+	}
+	rect := image.Rect(px.X, px.Y, px.X+TextureSize, px.Y+TextureSize)
+
+	br := c.BlockRegistry.(world.BlockRegistry)
+	b, found := br.BlockByRuntimeID(c.Block(x, y, z, 0))
+	if !found {
+		draw.Draw(dst, rect, image.NewUniform(notFoundColor), image.Point{}, draw.Src)
+		return
+	}
+
+	if _, isWater := b.(block.Water); isWater {
+		heightBlock := c.HeightMap().At(x, z)
+		waterDepth := y - heightBlock
+		if waterDepth > 0 {
+			cr.drawBlockTextured(dst, px, c, x, heightBlock, z, depth+1)
+		}
+		var water image.Image = image.NewUniform(waterTint)
+		name, _ := b.EncodeBlock()
+		if tex, ok := cr.textures.textures[name]; ok {
+			water = cr.textures.tint(name, tex, waterTint).img
+		}
+		mask := image.NewUniform(color.Alpha{uint8(min(150+waterDepth*7, 230))})
+		draw.DrawMask(dst, rect, water, image.Point{}, mask, image.Point{}, draw.Over)
+		return
+	}
+
+	tex := cr.blockTexture(c, b, x, y, z)
+	if tex == nil {
+		// no texture, fall back to the flat color
+		draw.Draw(dst, rect, image.NewUniform(cr.blockColorAt(c, x, y, z)), image.Point{}, draw.Src)
+		return
+	}
+	if !tex.opaque {
+		cr.drawBlockTextured(dst, px, c, x, y-1, z, depth+1)
+	}
+	draw.Draw(dst, rect, tex.img, image.Point{}, draw.Over)
+}
+
+func (cr *ChunkRenderer) chunk2ImgTextured(c *chunk.Chunk, heights *[16][16]int16) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 16*TextureSize, 16*TextureSize))
+
+	for x := uint8(0); x < 16; x++ {
+		for z := uint8(0); z < 16; z++ {
+			px := image.Pt(int(x)*TextureSize, int(z)*TextureSize)
+			rect := image.Rect(px.X, px.Y, px.X+TextureSize, px.Y+TextureSize)
+			y := heights[x][z]
+			if cr.Mode == RenderBiomes {
+				draw.Draw(img, rect, image.NewUniform(cr.biomeColor(c, x, y, z)), image.Point{}, draw.Src)
+				continue
+			}
+			cr.drawBlockTextured(img, px, c, x, y, z, 0)
+			if cr.Mode == RenderHillshade {
+				// light or shadow over the texture
+				shade := hillshade(heights, int(x), int(z))
+				overlay := color.RGBA{A: uint8(-shade * 2)}
+				if shade > 0 {
+					overlay = color.RGBA{R: uint8(shade * 2), G: uint8(shade * 2), B: uint8(shade * 2), A: uint8(shade * 2)}
+				}
+				draw.Draw(img, rect, image.NewUniform(overlay), image.Point{}, draw.Over)
+			}
+		}
+	}
+	return img
+}
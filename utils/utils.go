@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"strings"
 	"unsafe"
 
@@ -207,3 +208,42 @@ func StackToItem(reg world.BlockRegistry, it protocol.ItemStack) item.Stack {
 	s := item.NewStack(t, int(it.Count))
 	return nbtconv.Item(it.NBTData, &s)
 }
+
+// keys of written books that have to be kept
+var bookNBTKeys = []string{"pages", "title", "author", "generation", "xuid"}
+
+// ItemToNBT encodes a network item for saving, returns nil for empty items,
+// keeps the map_ tags so filled maps still point at their map data and the pages of books
+func ItemToNBT(reg world.BlockRegistry, it protocol.ItemStack) map[string]any {
+	s := StackToItem(reg, it)
+	if s.Empty() {
+		return nil
+	}
+	data := nbtconv.WriteItem(s, true)
+	for k, v := range it.NBTData {
+		if !strings.HasPrefix(k, "map_") && !slices.Contains(bookNBTKeys, k) {
+			continue
+		}
+		tag, ok := data["tag"].(map[string]any)
+		if !ok {
+			tag = make(map[string]any)
+			data["tag"] = tag
+		}
+		tag[k] = v
+	}
+	return data
+}
+
+// ItemsToNBT encodes a list of network items with their slot for saving
+func ItemsToNBT(reg world.BlockRegistry, items []protocol.ItemInstance) []map[string]any {
+	var out []map[string]any
+	for i, ii := range items {
+		data := ItemToNBT(reg, ii.Stack)
+		if data == nil {
+			continue
+		}
+		data["Slot"] = byte(i)
+		out = append(out, data)
+	}
+	return out
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xa
+)
+
+// WebsocketConn is a minimal server side websocket that only sends text messages
+type WebsocketConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	l    sync.Mutex
+}
+
+// sameOrigin is true if the request comes from a page on the same host,
+// browsers always send the origin so other websites cant connect, requests without one arent from a browser
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// UpgradeWebsocket does the websocket handshake on an http request
+func UpgradeWebsocket(w http.ResponseWriter, r *http.Request) (*WebsocketConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "expected a websocket", http.StatusBadRequest)
+		return nil, errors.New("not a websocket request")
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return nil, errors.New("websocket from another origin")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "cant upgrade", http.StatusInternalServerError)
+		return nil, errors.New("response cant be hijacked")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	rw.WriteString(base64.StdEncoding.EncodeToString(h[:]))
+	rw.WriteString("\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &WebsocketConn{conn: conn, rw: rw}, nil
+}
+
+func (c *WebsocketConn) writeFrame(op byte, payload []byte) error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	header := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	c.rw.Write(header)
+	c.rw.Write(payload)
+	return c.rw.Flush()
+}
+
+// WriteText sends a text message
+func (c *WebsocketConn) WriteText(data []byte) error {
+	return c.writeFrame(wsOpText, data)
+}
+
+// ReadLoop reads until the connection closes, answering pings and dropping messages
+func (c *WebsocketConn) ReadLoop() error {
+	defer c.conn.Close()
+	var header [2]byte
+	for {
+		if _, err := io.ReadFull(c.rw, header[:]); err != nil {
+			return err
+		}
+		op := header[0] & 0xf
+		masked := header[1]&0x80 != 0
+		n := uint64(header[1] & 0x7f)
+		switch n {
+		case 126:
+			var b [2]byte
+			if _, err := io.ReadFull(c.rw, b[:]); err != nil {
+				return err
+			}
+			n = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			if _, err := io.ReadFull(c.rw, b[:]); err != nil {
+				return err
+			}
+			n = binary.BigEndian.Uint64(b[:])
+		}
+		if n > 1<<20 {
+			return errors.New("websocket message too large")
+		}
+		var mask [4]byte
+		if masked {
+			if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+				return err
+			}
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.rw, payload); err != nil {
+			return err
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch op {
+		case wsOpClose:
+			c.writeFrame(wsOpClose, nil)
+			return nil
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *WebsocketConn) Close() error {
+	c.writeFrame(wsOpClose, nil)
+	return c.conn.Close()
+}
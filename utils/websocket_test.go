@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testWebsocket() (*WebsocketConn, net.Conn) {
+	server, client := net.Pipe()
+	return &WebsocketConn{conn: server, rw: bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server))}, client
+}
+
+// readFrame reads an unmasked frame like the server sends them
+func readFrame(r io.Reader) (op byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	n := uint64(header[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	payload = make([]byte, n)
+	_, err = io.ReadFull(r, payload)
+	return header[0], payload, err
+}
+
+// maskedFrame builds a frame like a browser sends them
+func maskedFrame(op byte, payload []byte) []byte {
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+func TestWebsocketWriteText(t *testing.T) {
+	for _, size := range []int{0, 125, 126, 300, 0xffff, 0x10000} {
+		conn, client := testWebsocket()
+		payload := bytes.Repeat([]byte{'a'}, size)
+		go conn.WriteText(payload)
+		op, got, err := readFrame(client)
+		if err != nil {
+			t.Fatalf("size %d: %s", size, err)
+		}
+		if op != 0x80|wsOpText {
+			t.Errorf("size %d: first byte %#x, want a final text frame", size, op)
+		}
+		if !bytes.Equal(got, payload) {
+			t.Errorf("size %d: payload of %d bytes, want %d", size, len(got), size)
+		}
+		client.Close()
+	}
+}
+
+func TestWebsocketReadLoop(t *testing.T) {
+	conn, client := testWebsocket()
+	done := make(chan error, 1)
+	go func() { done <- conn.ReadLoop() }()
+
+	// text messages are dropped, pings are answered with the same payload
+	go func() {
+		client.Write(maskedFrame(wsOpText, bytes.Repeat([]byte{'x'}, 200)))
+		client.Write(maskedFrame(wsOpPing, []byte("hello")))
+	}()
+	op, payload, err := readFrame(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != 0x80|wsOpPong || string(payload) != "hello" {
+		t.Errorf("got op %#x payload %q, want a pong with hello", op, payload)
+	}
+
+	go client.Write(maskedFrame(wsOpClose, nil))
+	if op, _, err := readFrame(client); err != nil || op != 0x80|wsOpClose {
+		t.Errorf("got op %#x %v, want a close frame", op, err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("ReadLoop returned %s after a close frame", err)
+	}
+}
+
+func TestWebsocketTooLarge(t *testing.T) {
+	conn, client := testWebsocket()
+	done := make(chan error, 1)
+	go func() { done <- conn.ReadLoop() }()
+	frame := []byte{0x80 | wsOpText, 0x80 | 127}
+	frame = binary.BigEndian.AppendUint64(frame, 1<<21)
+	go client.Write(frame)
+	if err := <-done; err == nil {
+		t.Error("expected an error for a frame over the limit")
+	}
+}
+
+func TestUpgradeWebsocketOrigin(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := UpgradeWebsocket(w, r)
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		origin string
+		want   int
+	}{
+		{"", http.StatusSwitchingProtocols},
+		{srv.URL, http.StatusSwitchingProtocols},
+		{"http://example.com", http.StatusForbidden},
+		{"http://" + srv.Listener.Addr().String() + ".example.com", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", srv.URL, nil)
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tt.want {
+			t.Errorf("origin %q: status %d, want %d", tt.origin, res.StatusCode, tt.want)
+		}
+		if tt.want == http.StatusSwitchingProtocols && res.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+			t.Errorf("origin %q: wrong accept key %q", tt.origin, res.Header.Get("Sec-WebSocket-Accept"))
+		}
+	}
+}